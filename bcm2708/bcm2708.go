@@ -1,13 +1,15 @@
 package bcm2708
 
 import (
+	"errors"
+	"fmt"
 	"github.com/e-asphyx/gpio"
 	"golang.org/x/sys/unix"
-	"log"
 	"os"
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 )
@@ -38,25 +40,45 @@ type bcm2835Driver struct {
 
 type Pin int
 
+var ErrAltFunction = errors.New("Pin is assigned to alternate function")
+
+// Group of 32 pins sharing SET/CLR registers
+type Bank int
+
 type bcm2708Trigger struct {
 	pin     *gpio.Pin
 	trigger gpio.PinTrigger
 }
 
-var drv *bcm2835Driver
+var (
+	drv     *bcm2835Driver
+	drvErr  error
+	drvOnce sync.Once
+)
 
-func newBcm2835Driver() (drv *bcm2835Driver, err error) {
+// Map GPIO registers. This is done implicitly on first use but calling Open explicitly
+// lets application handle missing hardware up front: methods without error result
+// silently do nothing if mapping has failed.
+func Open() error {
+	drvOnce.Do(func() {
+		drv, drvErr = newBcm2835Driver()
+	})
+	return drvErr
+}
+
+// Map single page of peripheral registers
+func mapRegisters(base int64) ([]byte, []uint32, error) {
 	fd, err := os.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, 0666)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	defer fd.Close()
 
 	pageSize := unix.Getpagesize() // 4096 is hardcoded
-	mapping, err := unix.Mmap(int(fd.Fd()), gpioBase, pageSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	mapping, err := unix.Mmap(int(fd.Fd()), base, pageSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	fd.Close()
 
 	// construct []uint32 by hands
 	sh := reflect.SliceHeader{
@@ -65,7 +87,15 @@ func newBcm2835Driver() (drv *bcm2835Driver, err error) {
 		Cap:  cap(mapping) / 4,
 	}
 
-	reg := *(*[]uint32)(unsafe.Pointer(&sh))
+	return mapping, *(*[]uint32)(unsafe.Pointer(&sh)), nil
+}
+
+func newBcm2835Driver() (drv *bcm2835Driver, err error) {
+	mapping, reg, err := mapRegisters(gpioBase)
+	if err != nil {
+		return nil, err
+	}
+
 	drv = &bcm2835Driver{
 		mapping: mapping,
 		reg:     reg,
@@ -81,11 +111,61 @@ func (drv *bcm2835Driver) close() error {
 }
 
 func (pin Pin) Read() (int, error) {
+	if err := Open(); err != nil {
+		return 0, err
+	}
+
 	offset := pinLevelOffset + int(pin)/32
 	return int((drv.reg[offset] >> (uint(pin) & 31)) & 1), nil
 }
 
 func (pin Pin) Write(value int) error {
+	if err := Open(); err != nil {
+		return err
+	}
+
+	// peripheral pins are never turned into plain outputs
+	fsel := pin.function()
+	if fsel > 1 {
+		return ErrAltFunction
+	}
+
+	input := fsel == 0
+	if input && !pin.autoDirection() {
+		return gpio.ErrDirIn
+	}
+
+	if gpio.DryRun() {
+		gpio.RecordDryRunWrite(fmt.Sprintf("GPIO%d", pin), value)
+		return nil
+	}
+
+	// level is latched first so switching to output doesn't glitch
+	pin.setLatch(value)
+	if input {
+		pin.setFunction(1)
+	}
+	return nil
+}
+
+// Preset output level of a pin still configured as input, it appears on the pin once
+// switched to output
+func (pin Pin) SetLatch(value int) error {
+	if err := Open(); err != nil {
+		return err
+	}
+
+	if gpio.DryRun() {
+		gpio.RecordDryRunWrite(fmt.Sprintf("GPIO%d", pin), value)
+		return nil
+	}
+
+	pin.setLatch(value)
+	return nil
+}
+
+// drv must be open
+func (pin Pin) setLatch(value int) {
 	var offset int
 
 	if value != 0 {
@@ -95,23 +175,123 @@ func (pin Pin) Write(value int) error {
 	}
 
 	drv.reg[offset] = 1 << (uint(pin) & 31)
+}
+
+// Per pin auto direction flags, bit n of bank n/32
+var autoDir [2]uint32
+
+// Switch pin to output on Write instead of returning ErrDirIn
+func (pin Pin) SetAutoDirection(auto bool) {
+	bank, bit := &autoDir[int(pin)/32], uint32(1)<<(uint(pin)&31)
+	for {
+		old := atomic.LoadUint32(bank)
+		v := old &^ bit
+		if auto {
+			v |= bit
+		}
+		if atomic.CompareAndSwapUint32(bank, old, v) {
+			return
+		}
+	}
+}
+
+func (pin Pin) autoDirection() bool {
+	return atomic.LoadUint32(&autoDir[int(pin)/32])&(1<<(uint(pin)&31)) != 0
+}
+
+func (pin Pin) Bank() (gpio.BankWriter, uint) {
+	return Bank(int(pin) / 32), uint(pin) & 31
+}
+
+// Set masked pins to value bits
+func (bank Bank) WriteBank(mask, value uint32) error {
+	if err := Open(); err != nil {
+		return err
+	}
+
+	if gpio.DryRun() {
+		for bit := uint(0); bit < 32; bit++ {
+			if mask&(1<<bit) != 0 {
+				gpio.RecordDryRunWrite(fmt.Sprintf("GPIO%d", int(bank)*32+int(bit)), int(value>>bit)&1)
+			}
+		}
+		return nil
+	}
+
+	if set := mask & value; set != 0 {
+		drv.reg[setOffset+int(bank)] = set
+	}
+	if clr := mask &^ value; clr != 0 {
+		drv.reg[clrOffset+int(bank)] = clr
+	}
 	return nil
 }
 
+// Levels of all pins in bank
+func (bank Bank) ReadBank() (uint32, error) {
+	if err := Open(); err != nil {
+		return 0, err
+	}
+	return drv.reg[pinLevelOffset+int(bank)], nil
+}
+
+func (pin Pin) ReadLevel() (gpio.Level, error) {
+	return gpio.ReadLevel(pin)
+}
+
+func (pin Pin) WriteLevel(l gpio.Level) error {
+	return gpio.WriteLevel(pin, l)
+}
+
+func (pin Pin) ReadBool() (bool, error) {
+	return gpio.ReadBool(pin)
+}
+
+func (pin Pin) WriteBool(value bool) error {
+	return gpio.WriteBool(pin, value)
+}
+
+func (pin Pin) SetHigh() error {
+	return pin.Write(1)
+}
+
+func (pin Pin) SetLow() error {
+	return pin.Write(0)
+}
+
+// Alternate functions are reported as DirOut, see Function
 func (pin Pin) Direction() gpio.Direction {
-	offset := fselOffset + int(pin)/10
-	shift := (uint(pin) % 10) * 3
-	val := (drv.reg[offset] >> shift) & 7
-	if val == 0 {
+	if Open() != nil {
+		return gpio.DirIn
+	}
+
+	if pin.function() == 0 {
 		return gpio.DirIn
 	} else {
 		return gpio.DirOut
 	}
 }
 
-func (pin Pin) SetDirection(dir gpio.Direction) {
+// Raw FSEL value: 0 input, 1 output, others select alternate functions
+func (pin Pin) Function() (uint32, error) {
+	if err := Open(); err != nil {
+		return 0, err
+	}
+	return pin.function(), nil
+}
+
+// drv must be open
+func (pin Pin) function() uint32 {
 	offset := fselOffset + int(pin)/10
 	shift := (uint(pin) % 10) * 3
+	return (drv.reg[offset] >> shift) & 7
+}
+
+func (pin Pin) SetDirection(dir gpio.Direction) {
+	if Open() != nil {
+		return
+	}
+
 	var mode uint32
 	if dir == gpio.DirIn {
 		mode = 0
@@ -119,12 +299,33 @@ func (pin Pin) SetDirection(dir gpio.Direction) {
 		mode = 1
 	}
 
+	pin.setFunction(mode)
+}
+
+// Raw FSEL value, drv must be open
+func (pin Pin) setFunction(mode uint32) {
+	offset := fselOffset + int(pin)/10
+	shift := (uint(pin) % 10) * 3
+
 	drv.mutex.Lock()
 	drv.reg[offset] = (drv.reg[offset] & ^(7 << shift)) | (mode << shift)
 	drv.mutex.Unlock()
 }
 
+// Portable variant of SetPullUpDown
+func (pin Pin) SetPull(pull gpio.Pull) error {
+	if err := Open(); err != nil {
+		return err
+	}
+	pin.SetPullUpDown(pull)
+	return nil
+}
+
 func (pin Pin) SetPullUpDown(pull gpio.Pull) {
+	if Open() != nil {
+		return
+	}
+
 	var val uint32
 	switch pull {
 	case gpio.PullOff:
@@ -178,6 +379,35 @@ func (tr *bcm2708Trigger) Ch() <-chan int {
 	return tr.trigger.Ch()
 }
 
+func (tr *bcm2708Trigger) EventCh() <-chan gpio.Event {
+	return tr.trigger.EventCh()
+}
+
+func (tr *bcm2708Trigger) ReadEvents(buf []gpio.Event) (int, error) {
+	return gpio.ReadEvents(tr.trigger, buf)
+}
+
+func (tr *bcm2708Trigger) SetHistory(n int) {
+	if h, ok := tr.trigger.(gpio.HistoryKeeper); ok {
+		h.SetHistory(n)
+	}
+}
+
+func (tr *bcm2708Trigger) History() []gpio.Event {
+	if h, ok := tr.trigger.(gpio.HistoryKeeper); ok {
+		return h.History()
+	}
+	return nil
+}
+
+func (tr *bcm2708Trigger) SetPriority(prio int) {
+	if p, ok := tr.trigger.(interface {
+		SetPriority(prio int)
+	}); ok {
+		p.SetPriority(prio)
+	}
+}
+
 func (tr *bcm2708Trigger) Close() error {
 	err := tr.trigger.Close()
 	if err != nil {
@@ -190,10 +420,42 @@ func (tr *bcm2708Trigger) Trigger() gpio.Trigger {
 	return tr.trigger.Trigger()
 }
 
-func init() {
-	var err error
-	drv, err = newBcm2835Driver()
-	if err != nil {
-		log.Fatal(err)
+// Open drain or open source output emulated by switching function to input when released
+type DrivePin struct {
+	Pin  Pin
+	Mode gpio.OutputMode
+}
+
+func (pin Pin) Drive(mode gpio.OutputMode) DrivePin {
+	return DrivePin{Pin: pin, Mode: mode}
+}
+
+func (p DrivePin) Read() (int, error) {
+	return p.Pin.Read()
+}
+
+func (p DrivePin) Write(value int) error {
+	if err := Open(); err != nil {
+		return err
 	}
+
+	if p.Pin.function() > 1 {
+		return ErrAltFunction
+	}
+
+	if gpio.DryRun() {
+		gpio.RecordDryRunWrite(fmt.Sprintf("GPIO%d", p.Pin), value)
+		return nil
+	}
+
+	released := (p.Mode == gpio.OpenDrain && value != 0) || (p.Mode == gpio.OpenSource && value == 0)
+	if released {
+		p.Pin.setFunction(0)
+		return nil
+	}
+
+	// latch level before enabling the driver
+	p.Pin.setLatch(value)
+	p.Pin.setFunction(1)
+	return nil
 }
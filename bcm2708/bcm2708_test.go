@@ -0,0 +1,110 @@
+package bcm2708
+
+import (
+	"github.com/e-asphyx/gpio"
+	"testing"
+)
+
+// Plain memory in place of GPIO registers
+func fakeRegisters(t *testing.T) {
+	drvOnce.Do(func() {
+		drv = &bcm2835Driver{reg: make([]uint32, 64)}
+	})
+	if drvErr != nil || drv.mapping != nil {
+		t.Skip("real registers mapped")
+	}
+	for i := range drv.reg {
+		drv.reg[i] = 0
+	}
+}
+
+func TestPinWrite(t *testing.T) {
+	fakeRegisters(t)
+
+	const pin = Pin(14)
+
+	tests := []struct {
+		name    string
+		fsel    uint32
+		auto    bool
+		dryRun  bool
+		value   int
+		err     error
+		fselOut uint32
+		set     uint32 // SET register written
+		clr     uint32 // CLR register written
+		records int
+	}{
+		{"output high", 1, false, false, 1, nil, 1, 1 << 14, 0, 0},
+		{"output low", 1, false, false, 0, nil, 1, 0, 1 << 14, 0},
+		{"input", 0, false, false, 1, gpio.ErrDirIn, 0, 0, 0, 0},
+		{"input auto direction", 0, true, false, 1, nil, 1, 1 << 14, 0, 0},
+		{"alt0", 4, false, false, 1, ErrAltFunction, 4, 0, 0, 0},
+		{"alt5 auto direction", 2, true, false, 1, ErrAltFunction, 2, 0, 0, 0},
+		{"dry run", 1, false, true, 1, nil, 1, 0, 0, 1},
+		{"dry run auto direction", 0, true, true, 1, nil, 0, 0, 0, 1},
+		{"dry run alt", 4, true, true, 1, ErrAltFunction, 4, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		for i := range drv.reg {
+			drv.reg[i] = 0
+		}
+		pin.setFunction(tt.fsel)
+		pin.SetAutoDirection(tt.auto)
+		gpio.ClearDryRunWrites()
+		gpio.SetDryRun(tt.dryRun)
+
+		err := pin.Write(tt.value)
+		gpio.SetDryRun(false)
+
+		if err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+		if f := pin.function(); f != tt.fselOut {
+			t.Errorf("%s: got FSEL %d, want %d", tt.name, f, tt.fselOut)
+		}
+		if drv.reg[setOffset] != tt.set || drv.reg[clrOffset] != tt.clr {
+			t.Errorf("%s: got SET %#x CLR %#x, want %#x %#x", tt.name, drv.reg[setOffset], drv.reg[clrOffset], tt.set, tt.clr)
+		}
+		if n := len(gpio.DryRunWrites()); n != tt.records {
+			t.Errorf("%s: got %d dry run records, want %d", tt.name, n, tt.records)
+		}
+	}
+	pin.SetAutoDirection(false)
+	gpio.ClearDryRunWrites()
+}
+
+func TestDrivePinAlt(t *testing.T) {
+	fakeRegisters(t)
+
+	p := Pin(2).Drive(gpio.OpenDrain)
+	p.Pin.setFunction(4)
+	if err := p.Write(0); err != ErrAltFunction {
+		t.Errorf("got %v, want %v", err, ErrAltFunction)
+	}
+	if f := p.Pin.function(); f != 4 {
+		t.Errorf("got FSEL %d, want 4", f)
+	}
+}
+
+func TestWriteBankDryRun(t *testing.T) {
+	fakeRegisters(t)
+
+	gpio.ClearDryRunWrites()
+	gpio.SetDryRun(true)
+	err := Bank(0).WriteBank(1<<3|1<<5, 1<<5)
+	gpio.SetDryRun(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := gpio.DryRunWrites()
+	gpio.ClearDryRunWrites()
+	if len(w) != 2 || w[0].Pin != "GPIO3" || w[0].Value != 0 || w[1].Pin != "GPIO5" || w[1].Value != 1 {
+		t.Errorf("got %+v", w)
+	}
+	if drv.reg[setOffset] != 0 || drv.reg[clrOffset] != 0 {
+		t.Error("registers written in dry run")
+	}
+}
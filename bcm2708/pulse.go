@@ -0,0 +1,62 @@
+package bcm2708
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"runtime"
+	"time"
+)
+
+var ErrPulseTimeout = errors.New("Timeout waiting for pulse")
+
+// Spin until pin leaves level, returns the time of change
+func (pin Pin) waitChange(level uint32, deadline time.Time) (time.Time, error) {
+	offset := pinLevelOffset + int(pin)/32
+	shift := uint(pin) & 31
+
+	for {
+		now := time.Now()
+		if (drv.reg[offset]>>shift)&1 != level {
+			return now, nil
+		}
+		if now.After(deadline) {
+			return time.Time{}, ErrPulseTimeout
+		}
+	}
+}
+
+// Busy-poll one full cycle of input signal. Works for signals way faster than edge triggers allow
+// but occupies a CPU for up to two periods.
+func (pin Pin) MeasurePulse(timeout time.Duration) (gpio.PulseMeasurement, error) {
+	if err := Open(); err != nil {
+		return gpio.PulseMeasurement{}, err
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	deadline := time.Now().Add(timeout)
+
+	// skip the pulse in progress
+	if _, err := pin.waitChange(1, deadline); err != nil {
+		return gpio.PulseMeasurement{}, err
+	}
+	rise, err := pin.waitChange(0, deadline)
+	if err != nil {
+		return gpio.PulseMeasurement{}, err
+	}
+	fall, err := pin.waitChange(1, deadline)
+	if err != nil {
+		return gpio.PulseMeasurement{}, err
+	}
+	next, err := pin.waitChange(0, deadline)
+	if err != nil {
+		return gpio.PulseMeasurement{}, err
+	}
+
+	return gpio.PulseMeasurement{
+		Width:     fall.Sub(rise),
+		Period:    next.Sub(rise),
+		Timestamp: next,
+	}, nil
+}
@@ -0,0 +1,291 @@
+package bcm2708
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"time"
+)
+
+const (
+	pwmBase   = bcm2835PeriBase + 0x20c000
+	clockBase = bcm2835PeriBase + 0x101000
+
+	pwmCtlOffset = 0 // 0x00 / 4
+	pwmRng1      = 4 // 0x10 / 4
+	pwmDat1      = 5 // 0x14 / 4
+	pwmRng2      = 8 // 0x20 / 4
+	pwmDat2      = 9 // 0x24 / 4
+
+	pwmCtlPWEN = 1 << 0
+	pwmCtlMSEN = 1 << 7
+
+	cmPWMCtlOffset = 40 // 0xa0 / 4
+	cmPWMDivOffset = 41 // 0xa4 / 4
+	cmPasswd       = 0x5a << 24
+	cmCtlSrcOsc    = 1
+	cmCtlEnab      = 1 << 4
+	cmCtlBusy      = 1 << 7
+
+	oscillatorFreq = 19200000
+	pwmClockDiv    = 2
+	pwmMaxDiv      = 4095
+	// Default PWM clock shared by both channels
+	PWMClock = oscillatorFreq / pwmClockDiv
+
+	fselAlt0 = 4
+	fselAlt5 = 2
+)
+
+var ErrPWMPin = errors.New("Pin has no PWM function")
+
+type pwmDriver struct {
+	pwmMapping []byte
+	pwm        []uint32
+	clkMapping []byte
+	clk        []uint32
+	div        uint32
+	mutex      sync.Mutex
+}
+
+var (
+	pwmDrv     *pwmDriver
+	pwmDrvErr  error
+	pwmDrvOnce sync.Once
+)
+
+func openPWM() error {
+	pwmDrvOnce.Do(func() {
+		if pwmDrvErr = Open(); pwmDrvErr != nil {
+			return
+		}
+		pwmDrv, pwmDrvErr = newPWMDriver()
+	})
+	return pwmDrvErr
+}
+
+func newPWMDriver() (*pwmDriver, error) {
+	pwmMapping, pwm, err := mapRegisters(pwmBase)
+	if err != nil {
+		return nil, err
+	}
+
+	clkMapping, clk, err := mapRegisters(clockBase)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &pwmDriver{
+		pwmMapping: pwmMapping,
+		pwm:        pwm,
+		clkMapping: clkMapping,
+		clk:        clk,
+	}
+
+	d.setClock(pwmClockDiv)
+	return d, nil
+}
+
+// Must be called with mutex held unless driver is being created
+func (d *pwmDriver) setClock(div uint32) {
+	// PWM must be stopped while clock is reconfigured
+	ctl := d.pwm[pwmCtlOffset]
+	d.pwm[pwmCtlOffset] = 0
+
+	d.clk[cmPWMCtlOffset] = cmPasswd | cmCtlSrcOsc
+	time.Sleep(110 * time.Microsecond)
+	for d.clk[cmPWMCtlOffset]&cmCtlBusy != 0 {
+		time.Sleep(time.Microsecond)
+	}
+
+	d.clk[cmPWMDivOffset] = cmPasswd | div<<12
+	d.clk[cmPWMCtlOffset] = cmPasswd | cmCtlSrcOsc | cmCtlEnab
+
+	d.pwm[pwmCtlOffset] = ctl
+	d.div = div
+}
+
+func (d *pwmDriver) clock() float64 {
+	return oscillatorFreq / float64(d.div)
+}
+
+// Channel of BCM283x PWM peripheral routed to GPIO12/18 (channel 0) or GPIO13/19 (channel 1).
+// Runs in mark/space mode without CPU involvement.
+type HardwarePWM struct {
+	pin     Pin
+	channel uint
+	fsel    uint32
+	rng     uint32
+	duty    float64
+}
+
+func NewHardwarePWM(pin Pin) (*HardwarePWM, error) {
+	p := &HardwarePWM{pin: pin}
+
+	switch pin {
+	case 12:
+		p.channel, p.fsel = 0, fselAlt0
+	case 13:
+		p.channel, p.fsel = 1, fselAlt0
+	case 18:
+		p.channel, p.fsel = 0, fselAlt5
+	case 19:
+		p.channel, p.fsel = 1, fselAlt5
+	default:
+		return nil, ErrPWMPin
+	}
+
+	if err := openPWM(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *HardwarePWM) rngOffset() int {
+	if p.channel == 0 {
+		return pwmRng1
+	}
+	return pwmRng2
+}
+
+func (p *HardwarePWM) datOffset() int {
+	if p.channel == 0 {
+		return pwmDat1
+	}
+	return pwmDat2
+}
+
+func (p *HardwarePWM) update() {
+	pwmDrv.pwm[p.datOffset()] = uint32(p.duty*float64(p.rng) + 0.5)
+}
+
+// Period is rounded to PWM clock ticks
+func (p *HardwarePWM) SetFrequency(freq float64) error {
+	pwmDrv.mutex.Lock()
+	defer pwmDrv.mutex.Unlock()
+
+	if freq <= 0 || freq > pwmDrv.clock()/2 {
+		return gpio.ErrFrequency
+	}
+
+	p.setRange(uint32(pwmDrv.clock()/freq + 0.5))
+	return nil
+}
+
+// Must be called with mutex held
+func (p *HardwarePWM) setRange(rng uint32) {
+	p.rng = rng
+	pwmDrv.pwm[p.rngOffset()] = p.rng
+	p.update()
+}
+
+// Actual frequency after rounding
+func (p *HardwarePWM) Frequency() float64 {
+	pwmDrv.mutex.Lock()
+	defer pwmDrv.mutex.Unlock()
+
+	if p.rng == 0 {
+		return 0
+	}
+	return pwmDrv.clock() / float64(p.rng)
+}
+
+// Picks clock divisor giving at least want.Steps ticks per period if possible.
+// The divisor is shared, so this changes frequency of the other channel as well.
+func (p *HardwarePWM) ConfigurePWM(want gpio.PWMConfig) (gpio.PWMConfig, error) {
+	if want.Frequency <= 0 {
+		return gpio.PWMConfig{}, gpio.ErrFrequency
+	}
+
+	steps := want.Steps
+	if steps < 2 {
+		steps = 2
+	}
+
+	div := uint32(oscillatorFreq / (want.Frequency * float64(steps)))
+	if div < pwmClockDiv {
+		div = pwmClockDiv
+	} else if div > pwmMaxDiv {
+		div = pwmMaxDiv
+	}
+
+	rng := uint32(oscillatorFreq/float64(div)/want.Frequency + 0.5)
+	if rng < 2 {
+		return gpio.PWMConfig{}, gpio.ErrFrequency
+	}
+
+	pwmDrv.mutex.Lock()
+	defer pwmDrv.mutex.Unlock()
+
+	if div != pwmDrv.div {
+		pwmDrv.setClock(div)
+	}
+	p.setRange(rng)
+
+	return gpio.PWMConfig{Frequency: pwmDrv.clock() / float64(rng), Steps: int(rng)}, nil
+}
+
+// 0.0 to 1.0
+func (p *HardwarePWM) SetDutyCycle(duty float64) error {
+	if duty < 0 {
+		duty = 0
+	} else if duty > 1 {
+		duty = 1
+	}
+
+	pwmDrv.mutex.Lock()
+	defer pwmDrv.mutex.Unlock()
+
+	p.duty = duty
+	p.update()
+
+	return nil
+}
+
+// Same as SetDutyCycle, implements gpio.DutyWriter
+func (p *HardwarePWM) SetDuty(duty float64) error {
+	return p.SetDutyCycle(duty)
+}
+
+// Start output and route it to the pin
+func (p *HardwarePWM) Enable() error {
+	pwmDrv.mutex.Lock()
+	pwmDrv.pwm[pwmCtlOffset] |= (pwmCtlPWEN | pwmCtlMSEN) << (8 * p.channel)
+	pwmDrv.mutex.Unlock()
+
+	p.pin.setFunction(p.fsel)
+	return nil
+}
+
+// Stop output and turn the pin into low output
+func (p *HardwarePWM) Disable() error {
+	p.pin.setFunction(1)
+	drv.reg[clrOffset+int(p.pin)/32] = 1 << (uint(p.pin) & 31)
+
+	pwmDrv.mutex.Lock()
+	pwmDrv.pwm[pwmCtlOffset] &^= pwmCtlPWEN << (8 * p.channel)
+	pwmDrv.mutex.Unlock()
+
+	return nil
+}
+
+// Implements gpio.PWMPin
+func (pin Pin) PWM(freq float64) (gpio.DutyWriter, error) {
+	p, err := NewHardwarePWM(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.SetFrequency(freq); err != nil {
+		return nil, err
+	}
+	if err = p.SetDutyCycle(0); err != nil {
+		return nil, err
+	}
+	if err = p.Enable(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
@@ -0,0 +1,129 @@
+package bcm2708
+
+import (
+	"math"
+	"runtime"
+	"sync/atomic"
+)
+
+// Quadrature decoder sampling GPLEV in a busy loop. Meant for encoders too fast
+// for per-edge interrupts; keeps one CPU core busy while running.
+type Quadrature struct {
+	a, b Pin
+
+	pos     int32
+	invalid uint32
+
+	overflow func(up bool)
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Position order of (A<<1 | B) states when A leads: 00 10 11 01
+var quadPhase = [4]int{0, 3, 1, 2}
+
+// onOverflow (can be nil) is called from the sampling loop when the counter wraps
+func NewQuadrature(a, b Pin, onOverflow func(up bool)) *Quadrature {
+	return &Quadrature{
+		a:        a,
+		b:        b,
+		overflow: onOverflow,
+	}
+}
+
+func (q *Quadrature) sample() int {
+	la := drv.reg[pinLevelOffset+int(q.a)/32]
+	lb := la
+	if int(q.a)/32 != int(q.b)/32 {
+		lb = drv.reg[pinLevelOffset+int(q.b)/32]
+	}
+	return int((la>>(uint(q.a)&31))&1)<<1 | int((lb>>(uint(q.b)&31))&1)
+}
+
+func (q *Quadrature) run(stop, done chan struct{}) {
+	defer close(done)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	prev := q.sample()
+	for i := 0; ; i++ {
+		// check for stop request once in a while only
+		if i&0xfff == 0 {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+
+		cur := q.sample()
+		if cur == prev {
+			continue
+		}
+
+		var delta int32
+		switch (quadPhase[cur] - quadPhase[prev] + 4) % 4 {
+		case 1:
+			delta = 1
+		case 3:
+			delta = -1
+		default:
+			// both inputs changed, step was missed
+			atomic.AddUint32(&q.invalid, 1)
+			prev = cur
+			continue
+		}
+		prev = cur
+
+		pos := atomic.AddInt32(&q.pos, delta)
+		if q.overflow != nil {
+			if delta > 0 && pos == math.MinInt32 {
+				q.overflow(true)
+			} else if delta < 0 && pos == math.MaxInt32 {
+				q.overflow(false)
+			}
+		}
+	}
+}
+
+// Start sampling. Pins must be configured as inputs.
+func (q *Quadrature) Start() error {
+	if err := Open(); err != nil {
+		return err
+	}
+	if q.stop != nil {
+		return nil
+	}
+
+	q.stop = make(chan struct{})
+	q.done = make(chan struct{})
+	go q.run(q.stop, q.done)
+
+	return nil
+}
+
+func (q *Quadrature) Stop() {
+	if q.stop == nil {
+		return
+	}
+
+	close(q.stop)
+	<-q.done
+	q.stop = nil
+	q.done = nil
+}
+
+// Counts, four per encoder cycle
+func (q *Quadrature) Position() int32 {
+	return atomic.LoadInt32(&q.pos)
+}
+
+func (q *Quadrature) SetPosition(pos int32) {
+	atomic.StoreInt32(&q.pos, pos)
+}
+
+// Number of invalid transitions seen, non zero means the loop is too slow for the encoder
+func (q *Quadrature) Invalid() uint32 {
+	return atomic.LoadUint32(&q.invalid)
+}
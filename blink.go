@@ -0,0 +1,95 @@
+package gpio
+
+import "time"
+
+// Blink code timing
+type BlinkTiming struct {
+	Long  time.Duration // long blink on time
+	Short time.Duration // short blink on time
+	Gap   time.Duration // off time between blinks
+	Pause time.Duration // off time between code repetitions
+}
+
+var DefaultBlinkTiming = BlinkTiming{
+	Long:  600 * time.Millisecond,
+	Short: 150 * time.Millisecond,
+	Gap:   300 * time.Millisecond,
+	Pause: 2 * time.Second,
+}
+
+// Shows codes received from the channel on status output, repeating the current code until a new one
+// arrives. Code is shown as code/10 long blinks followed by code%10 short ones, zero code keeps output
+// low. Returns when the channel is closed or on write error.
+func BlinkCodes(pin PinWriter, codes <-chan int, timing BlinkTiming) error {
+	code := 0
+
+	// wait returns false if the channel was closed
+	wait := func(d time.Duration) (bool, bool) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case c, ok := <-codes:
+			if !ok {
+				return false, false
+			}
+			code = c
+			return true, true
+		case <-timer.C:
+			return true, false
+		}
+	}
+
+	if err := pin.Write(0); err != nil {
+		return err
+	}
+
+	for {
+		if code <= 0 {
+			c, ok := <-codes
+			if !ok {
+				return pin.Write(0)
+			}
+			code = c
+			continue
+		}
+
+		blinks := make([]time.Duration, 0, code/10+code%10)
+		for i := 0; i < code/10; i++ {
+			blinks = append(blinks, timing.Long)
+		}
+		for i := 0; i < code%10; i++ {
+			blinks = append(blinks, timing.Short)
+		}
+
+	blinking:
+		for i, on := range blinks {
+			if err := pin.Write(1); err != nil {
+				return err
+			}
+			ok, changed := wait(on)
+			if err := pin.Write(0); err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			if changed {
+				break
+			}
+
+			if i != len(blinks)-1 {
+				if ok, changed = wait(timing.Gap); !ok {
+					return nil
+				} else if changed {
+					break blinking
+				}
+			}
+		}
+
+		// new code is shown after the pause too
+		if ok, _ := wait(timing.Pause); !ok {
+			return nil
+		}
+	}
+}
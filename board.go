@@ -0,0 +1,259 @@
+package gpio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Peripheral function supported by a pin
+type Capability uint
+
+const (
+	CapPWM Capability = 1 << iota
+	CapI2C
+	CapSPI
+	CapUART
+)
+
+// Physical pin header. Pins[i] holds BCM GPIO number of the physical pin i+1
+// or -1 for power and ground pins.
+type Header struct {
+	Name string
+	Pins []int
+}
+
+// Board description decoded from the revision code
+type Board struct {
+	Code         uint32
+	Model        string
+	Revision     string
+	Processor    string
+	Manufacturer string
+	Memory       int // MB
+	Header       Header
+	Caps         map[int]Capability // BCM GPIO number -> capabilities
+}
+
+var ErrUnknownBoard = errors.New("Unknown board revision")
+
+var (
+	header26Rev1 = Header{
+		Name: "P1 (26 pin, rev 1)",
+		Pins: []int{
+			-1, -1, 0, -1, 1, -1, 4, 14, -1, 15,
+			17, 18, 21, -1, 22, 23, -1, 24, 10, -1,
+			9, 25, 11, 8, -1, 7,
+		},
+	}
+
+	header26Rev2 = Header{
+		Name: "P1 (26 pin, rev 2)",
+		Pins: []int{
+			-1, -1, 2, -1, 3, -1, 4, 14, -1, 15,
+			17, 18, 27, -1, 22, 23, -1, 24, 10, -1,
+			9, 25, 11, 8, -1, 7,
+		},
+	}
+
+	header40 = Header{
+		Name: "J8 (40 pin)",
+		Pins: []int{
+			-1, -1, 2, -1, 3, -1, 4, 14, -1, 15,
+			17, 18, 27, -1, 22, 23, -1, 24, 10, -1,
+			9, 25, 11, 8, -1, 7, 0, 1, 5, -1,
+			6, 12, 13, -1, 19, 16, 26, 20, -1, 21,
+		},
+	}
+)
+
+type oldRevision struct {
+	model    string
+	revision string
+	memory   int
+	header   *Header
+}
+
+var oldRevisions = map[uint32]oldRevision{
+	0x0002: {"B", "1.0", 256, &header26Rev1},
+	0x0003: {"B", "1.0", 256, &header26Rev1},
+	0x0004: {"B", "2.0", 256, &header26Rev2},
+	0x0005: {"B", "2.0", 256, &header26Rev2},
+	0x0006: {"B", "2.0", 256, &header26Rev2},
+	0x0007: {"A", "2.0", 256, &header26Rev2},
+	0x0008: {"A", "2.0", 256, &header26Rev2},
+	0x0009: {"A", "2.0", 256, &header26Rev2},
+	0x000d: {"B", "2.0", 512, &header26Rev2},
+	0x000e: {"B", "2.0", 512, &header26Rev2},
+	0x000f: {"B", "2.0", 512, &header26Rev2},
+	0x0010: {"B+", "1.2", 512, &header40},
+	0x0011: {"CM1", "1.0", 512, nil},
+	0x0012: {"A+", "1.1", 256, &header40},
+	0x0013: {"B+", "1.2", 512, &header40},
+	0x0014: {"CM1", "1.0", 512, nil},
+	0x0015: {"A+", "1.1", 256, &header40},
+}
+
+var boardTypes = map[uint32]string{
+	0x00: "A",
+	0x01: "B",
+	0x02: "A+",
+	0x03: "B+",
+	0x04: "2B",
+	0x05: "Alpha",
+	0x06: "CM1",
+	0x08: "3B",
+	0x09: "Zero",
+	0x0a: "CM3",
+	0x0c: "Zero W",
+	0x0d: "3B+",
+	0x0e: "3A+",
+	0x10: "CM3+",
+	0x11: "4B",
+	0x12: "Zero 2 W",
+	0x13: "400",
+	0x14: "CM4",
+	0x15: "CM4S",
+	0x17: "5",
+	0x18: "CM5",
+	0x19: "500",
+	0x1a: "CM5 Lite",
+}
+
+var processors = []string{"BCM2835", "BCM2836", "BCM2837", "BCM2711", "BCM2712"}
+
+var manufacturers = []string{"Sony UK", "Egoman", "Embest", "Sony Japan", "Embest", "Stadium"}
+
+// Decode revision code
+func ParseRevision(code uint32) (*Board, error) {
+	b := &Board{Code: code}
+
+	if code&(1<<23) == 0 {
+		// old style code, ignore overvoltage bit
+		r, ok := oldRevisions[code&0xffffff]
+		if !ok {
+			return nil, ErrUnknownBoard
+		}
+
+		b.Model = r.model
+		b.Revision = r.revision
+		b.Processor = processors[0]
+		b.Memory = r.memory
+		if r.header != nil {
+			b.Header = *r.header
+		}
+	} else {
+		model, ok := boardTypes[(code>>4)&0xff]
+		if !ok {
+			return nil, ErrUnknownBoard
+		}
+
+		b.Model = model
+		b.Revision = fmt.Sprintf("1.%d", code&0xf)
+		if p := (code >> 12) & 0xf; int(p) < len(processors) {
+			b.Processor = processors[p]
+		}
+		if m := (code >> 16) & 0xf; int(m) < len(manufacturers) {
+			b.Manufacturer = manufacturers[m]
+		}
+		b.Memory = 256 << ((code >> 20) & 7)
+
+		if !strings.HasPrefix(model, "CM") {
+			b.Header = header40
+		}
+	}
+
+	b.Caps = make(map[int]Capability)
+	for _, p := range b.Header.Pins {
+		if p < 0 {
+			continue
+		}
+
+		var c Capability
+		switch p {
+		case 12, 13, 18, 19:
+			c |= CapPWM
+		}
+		switch p {
+		case 0, 1, 2, 3:
+			c |= CapI2C
+		case 7, 8, 9, 10, 11:
+			c |= CapSPI
+		case 14, 15:
+			c |= CapUART
+		}
+		switch p {
+		case 16, 17, 18, 19, 20, 21:
+			// SPI1 is available on 40 pin header only
+			if len(b.Header.Pins) == 40 {
+				c |= CapSPI
+			}
+		}
+		b.Caps[p] = c
+	}
+
+	return b, nil
+}
+
+// GPIO number of physical header pin or -1
+func (b *Board) HeaderPin(num int) int {
+	if num < 1 || num > len(b.Header.Pins) {
+		return -1
+	}
+	return b.Header.Pins[num-1]
+}
+
+// GPIO numbers which have all of given capabilities
+func (b *Board) Pins(c Capability) []int {
+	var res []int
+	for _, p := range b.Header.Pins {
+		if p >= 0 && b.Caps[p]&c == c {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func readRevision() (uint32, error) {
+	fd, err := os.Open("/proc/cpuinfo")
+	if err == nil {
+		defer fd.Close()
+
+		s := bufio.NewScanner(fd)
+		for s.Scan() {
+			f := strings.SplitN(s.Text(), ":", 2)
+			if len(f) == 2 && strings.TrimSpace(f[0]) == "Revision" {
+				v, err := strconv.ParseUint(strings.TrimSpace(f[1]), 16, 32)
+				if err != nil {
+					return 0, err
+				}
+				return uint32(v), nil
+			}
+		}
+	}
+
+	// arm64 kernels don't report revision in cpuinfo
+	buf, err := ioutil.ReadFile("/proc/device-tree/system/linux,revision")
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) < 4 {
+		return 0, ErrUnknownBoard
+	}
+
+	return binary.BigEndian.Uint32(buf), nil
+}
+
+// Returns description of the board we're running on
+func BoardInfo() (*Board, error) {
+	code, err := readRevision()
+	if err != nil {
+		return nil, err
+	}
+	return ParseRevision(code)
+}
@@ -0,0 +1,90 @@
+package gpio
+
+import (
+	"testing"
+)
+
+func TestParseRevision(t *testing.T) {
+	tests := []struct {
+		code         uint32
+		model        string
+		revision     string
+		processor    string
+		manufacturer string
+		memory       int
+		headerLen    int
+	}{
+		{0x0002, "B", "1.0", "BCM2835", "", 256, 26},
+		{0x1000002, "B", "1.0", "BCM2835", "", 256, 26}, // overvoltage bit set
+		{0x000e, "B", "2.0", "BCM2835", "", 512, 26},
+		{0x0010, "B+", "1.2", "BCM2835", "", 512, 40},
+		{0x0011, "CM1", "1.0", "BCM2835", "", 512, 0},
+		{0x9000c1, "Zero W", "1.1", "BCM2835", "Sony UK", 512, 40},
+		{0xa02082, "3B", "1.2", "BCM2837", "Sony UK", 1024, 40},
+		{0xa22082, "3B", "1.2", "BCM2837", "Embest", 1024, 40},
+		{0xa020a0, "CM3", "1.0", "BCM2837", "Sony UK", 1024, 0},
+		{0xc03111, "4B", "1.1", "BCM2711", "Sony UK", 4096, 40},
+		{0xd04170, "5", "1.0", "BCM2712", "Sony UK", 8192, 40},
+	}
+
+	for _, tt := range tests {
+		b, err := ParseRevision(tt.code)
+		if err != nil {
+			t.Errorf("%#x: %v", tt.code, err)
+			continue
+		}
+		if b.Model != tt.model || b.Revision != tt.revision || b.Processor != tt.processor ||
+			b.Manufacturer != tt.manufacturer || b.Memory != tt.memory || len(b.Header.Pins) != tt.headerLen {
+			t.Errorf("%#x: got %s %s %s %q %dMB %d pins, want %s %s %s %q %dMB %d pins", tt.code,
+				b.Model, b.Revision, b.Processor, b.Manufacturer, b.Memory, len(b.Header.Pins),
+				tt.model, tt.revision, tt.processor, tt.manufacturer, tt.memory, tt.headerLen)
+		}
+	}
+}
+
+func TestParseRevisionUnknown(t *testing.T) {
+	for _, code := range []uint32{0x0000, 0x0001, 0x0016, 0xa021f0} {
+		if _, err := ParseRevision(code); err != ErrUnknownBoard {
+			t.Errorf("%#x: got %v, want %v", code, err, ErrUnknownBoard)
+		}
+	}
+}
+
+func TestBoardPins(t *testing.T) {
+	tests := []struct {
+		code uint32
+		pin  int // physical
+		gpio int
+		caps Capability
+	}{
+		{0x0002, 3, 0, CapI2C},
+		{0x0004, 3, 2, CapI2C},
+		{0x0004, 13, 27, 0},
+		{0xa02082, 12, 18, CapPWM | CapSPI},
+		{0xa02082, 8, 14, CapUART},
+		{0xa02082, 19, 10, CapSPI},
+		{0xa02082, 1, -1, 0},
+		{0xa02082, 41, -1, 0},
+	}
+
+	for _, tt := range tests {
+		b, err := ParseRevision(tt.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := b.HeaderPin(tt.pin)
+		if g != tt.gpio {
+			t.Errorf("%#x pin %d: got GPIO%d, want GPIO%d", tt.code, tt.pin, g, tt.gpio)
+			continue
+		}
+		if g >= 0 && b.Caps[g] != tt.caps {
+			t.Errorf("%#x GPIO%d: got caps %#x, want %#x", tt.code, g, b.Caps[g], tt.caps)
+		}
+	}
+
+	b, _ := ParseRevision(0xa02082)
+	pwm := b.Pins(CapPWM)
+	if len(pwm) != 4 {
+		t.Errorf("got PWM pins %v, want 4", pwm)
+	}
+}
@@ -0,0 +1,58 @@
+package gpio
+
+// Adds boolean accessors to any PinReader
+type BoolReader struct {
+	PinReader
+}
+
+// Adds boolean accessors to any PinWriter
+type BoolWriter struct {
+	PinWriter
+}
+
+func ReadBool(pin PinReader) (bool, error) {
+	val, err := pin.Read()
+	if err != nil {
+		return false, err
+	}
+	return val != 0, nil
+}
+
+func WriteBool(pin PinWriter, value bool) error {
+	if value {
+		return pin.Write(1)
+	}
+	return pin.Write(0)
+}
+
+func (r BoolReader) ReadBool() (bool, error) {
+	return ReadBool(r.PinReader)
+}
+
+func (w BoolWriter) WriteBool(value bool) error {
+	return WriteBool(w.PinWriter, value)
+}
+
+func (w BoolWriter) SetHigh() error {
+	return w.Write(1)
+}
+
+func (w BoolWriter) SetLow() error {
+	return w.Write(0)
+}
+
+func (pin *Pin) ReadBool() (bool, error) {
+	return ReadBool(pin)
+}
+
+func (pin *Pin) WriteBool(value bool) error {
+	return WriteBool(pin, value)
+}
+
+func (pin *Pin) SetHigh() error {
+	return pin.Write(1)
+}
+
+func (pin *Pin) SetLow() error {
+	return pin.Write(0)
+}
@@ -0,0 +1,165 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+//go:generate stringer -type=ButtonEvent
+
+type ButtonEvent int
+
+const (
+	ButtonPressed ButtonEvent = iota
+	ButtonReleased
+	ButtonLongPress   // held for Hold, sent once per press
+	ButtonDoubleClick // second press within DoubleClick after release, follows ButtonPressed
+)
+
+const (
+	DefaultButtonDebounce    = 20 * time.Millisecond
+	DefaultButtonHold        = time.Second
+	DefaultButtonDoubleClick = 300 * time.Millisecond
+)
+
+// Push button delivering semantic events. Set fields before calling Watch.
+type Button struct {
+	ActiveLow   bool
+	Debounce    time.Duration // zero disables debouncing
+	Hold        time.Duration // zero disables ButtonLongPress
+	DoubleClick time.Duration // zero disables ButtonDoubleClick
+
+	pin   PinReadTrigger
+	mutex sync.Mutex
+	tr    PinTrigger
+	ch    chan ButtonEvent
+	done  chan struct{}
+}
+
+func NewButton(pin PinReadTrigger) *Button {
+	return &Button{
+		Debounce:    DefaultButtonDebounce,
+		Hold:        DefaultButtonHold,
+		DoubleClick: DefaultButtonDoubleClick,
+		pin:         pin,
+	}
+}
+
+// Start delivering events
+func (b *Button) Watch() (<-chan ButtonEvent, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.tr != nil {
+		return nil, ErrTrigger
+	}
+
+	src := b.pin
+	if b.ActiveLow {
+		src = Invert(src)
+	}
+
+	pressed, err := ReadBool(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var tr PinTrigger
+	if b.Debounce > 0 {
+		tr, err = src.TriggerWithDebounce(EdgeBoth, b.Debounce)
+	} else {
+		tr, err = src.Trigger(EdgeBoth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b.tr = tr
+	b.ch = make(chan ButtonEvent, 16)
+	b.done = make(chan struct{})
+
+	go b.run(tr, pressed)
+	return b.ch, nil
+}
+
+func (b *Button) send(ev ButtonEvent) {
+	if len(b.ch) != cap(b.ch) {
+		b.ch <- ev
+	}
+}
+
+func (b *Button) run(tr PinTrigger, pressed bool) {
+	defer close(b.done)
+	defer close(b.ch)
+
+	var (
+		hold         *time.Timer
+		holdCh       <-chan time.Time
+		released     time.Time
+		secondPress  bool
+		afterRelease bool
+	)
+
+	for {
+		select {
+		case ev, ok := <-tr.EventCh():
+			if !ok {
+				if hold != nil {
+					hold.Stop()
+				}
+				return
+			}
+
+			if (ev.Value != 0) == pressed {
+				continue
+			}
+			pressed = ev.Value != 0
+
+			if pressed {
+				b.send(ButtonPressed)
+
+				// a long press doesn't count as the first click
+				if afterRelease && !secondPress && b.DoubleClick > 0 && ev.Timestamp.Sub(released) <= b.DoubleClick {
+					b.send(ButtonDoubleClick)
+					secondPress = true
+				} else {
+					secondPress = false
+				}
+
+				if b.Hold > 0 {
+					hold = time.NewTimer(b.Hold)
+					holdCh = hold.C
+				}
+			} else {
+				b.send(ButtonReleased)
+
+				afterRelease = holdCh != nil || b.Hold == 0
+				released = ev.Timestamp
+				if hold != nil {
+					hold.Stop()
+					hold, holdCh = nil, nil
+				}
+			}
+
+		case <-holdCh:
+			b.send(ButtonLongPress)
+			hold, holdCh = nil, nil
+		}
+	}
+}
+
+// Stop watching
+func (b *Button) Close() error {
+	b.mutex.Lock()
+	tr, done := b.tr, b.done
+	b.tr = nil
+	b.mutex.Unlock()
+
+	if tr == nil {
+		return nil
+	}
+
+	err := tr.Close()
+	<-done
+	return err
+}
@@ -0,0 +1,309 @@
+package gpio
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// GPIO character device uAPI v2
+const (
+	gpioMaxNameSize       = 32
+	gpioV2LinesMax        = 64
+	gpioV2LineNumAttrsMax = 10
+
+	gpioGetChipInfoIoctl        = 0x8044b401
+	gpioGetLineInfoUnwatchIoctl = 0xc004b40c
+	gpioV2GetLineInfoIoctl      = 0xc100b405
+	gpioV2GetLineInfoWatchIoctl = 0xc100b406
+
+	gpioV2LineAttrIdFlags        = 1
+	gpioV2LineAttrIdOutputValues = 2
+	gpioV2LineAttrIdDebounce     = 3
+)
+
+type gpioChipInfo struct {
+	name  [gpioMaxNameSize]byte
+	label [gpioMaxNameSize]byte
+	lines uint32
+}
+
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64 // flags, values or debounce_period_us
+}
+
+type gpioV2LineInfo struct {
+	name     [gpioMaxNameSize]byte
+	consumer [gpioMaxNameSize]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [gpioV2LineNumAttrsMax]gpioV2LineAttribute
+	padding  [4]uint32
+}
+
+type gpioV2LineInfoChanged struct {
+	info        gpioV2LineInfo
+	timestampNs uint64
+	eventType   uint32
+	padding     [5]uint32
+}
+
+// Line configuration flags
+type LineFlags uint64
+
+const (
+	LineUsed LineFlags = 1 << iota
+	LineActiveLow
+	LineInput
+	LineOutput
+	LineEdgeRising
+	LineEdgeFalling
+	LineOpenDrain
+	LineOpenSource
+	LinePullUp
+	LinePullDown
+	LineBiasDisabled
+	LineClockRealtime
+	LineClockHTE
+)
+
+// Kind of line info change
+type LineChangeType int
+
+const (
+	LineRequested LineChangeType = iota + 1
+	LineReleased
+	LineReconfigured
+)
+
+// Line description as reported by the kernel
+type LineInfo struct {
+	Offset   int
+	Name     string
+	Consumer string
+	Flags    LineFlags
+	Debounce time.Duration
+}
+
+// Line info change notification
+type LineChange struct {
+	Info LineInfo
+	Type LineChangeType
+	// CLOCK_MONOTONIC
+	Timestamp time.Duration
+}
+
+// GPIO character device
+type Chip struct {
+	Name     string
+	Label    string
+	NumLines int
+
+	fd      *os.File
+	mutex   sync.Mutex
+	watched []int
+	watch   chan LineChange
+}
+
+var ErrWatch = errors.New("Watch already active")
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func ioctlPtr(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Opens chip by name ("gpiochip0") or device path
+func OpenChip(name string) (*Chip, error) {
+	path := name
+	if !strings.ContainsRune(name, '/') {
+		path = filepath.Join("/dev", name)
+	}
+
+	fd, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var info gpioChipInfo
+	err = ioctlPtr(fd.Fd(), gpioGetChipInfoIoctl, unsafe.Pointer(&info))
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	chip := &Chip{
+		Name:     cString(info.name[:]),
+		Label:    cString(info.label[:]),
+		NumLines: int(info.lines),
+		fd:       fd,
+	}
+
+	return chip, nil
+}
+
+func (info *gpioV2LineInfo) decode() LineInfo {
+	li := LineInfo{
+		Offset:   int(info.offset),
+		Name:     cString(info.name[:]),
+		Consumer: cString(info.consumer[:]),
+		Flags:    LineFlags(info.flags),
+	}
+
+	for i := 0; i < int(info.numAttrs) && i < gpioV2LineNumAttrsMax; i++ {
+		if info.attrs[i].id == gpioV2LineAttrIdDebounce {
+			li.Debounce = time.Duration(uint32(info.attrs[i].value)) * time.Microsecond
+		}
+	}
+
+	return li
+}
+
+func (li *LineInfo) Direction() Direction {
+	if li.Flags&LineOutput != 0 {
+		return DirOut
+	}
+	return DirIn
+}
+
+// Open all GPIO character devices sorted by name. Chips must be closed by caller.
+func Chips() ([]*Chip, error) {
+	names, err := filepath.Glob("/dev/gpiochip*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	chips := make([]*Chip, 0, len(names))
+	for _, name := range names {
+		chip, err := OpenChip(name)
+		if err != nil {
+			for _, c := range chips {
+				c.Close()
+			}
+			return nil, err
+		}
+		chips = append(chips, chip)
+	}
+
+	return chips, nil
+}
+
+// Info of all lines
+func (chip *Chip) Lines() ([]LineInfo, error) {
+	lines := make([]LineInfo, chip.NumLines)
+	for i := range lines {
+		li, err := chip.LineInfo(i)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = *li
+	}
+	return lines, nil
+}
+
+// Current line info
+func (chip *Chip) LineInfo(offset int) (*LineInfo, error) {
+	info := gpioV2LineInfo{offset: uint32(offset)}
+	err := ioctlPtr(chip.fd.Fd(), gpioV2GetLineInfoIoctl, unsafe.Pointer(&info))
+	if err != nil {
+		return nil, err
+	}
+
+	li := info.decode()
+	return &li, nil
+}
+
+// Watch request, release and reconfiguration of given lines (all lines if none given) by any process.
+// There is no way to stop a watch, the channel is closed when the chip is closed.
+func (chip *Chip) WatchConfig(offsets ...int) (<-chan LineChange, error) {
+	chip.mutex.Lock()
+	defer chip.mutex.Unlock()
+
+	if chip.watch != nil {
+		return nil, ErrWatch
+	}
+
+	if len(offsets) == 0 {
+		for i := 0; i < chip.NumLines; i++ {
+			offsets = append(offsets, i)
+		}
+	}
+
+	for i, off := range offsets {
+		info := gpioV2LineInfo{offset: uint32(off)}
+		err := ioctlPtr(chip.fd.Fd(), gpioV2GetLineInfoWatchIoctl, unsafe.Pointer(&info))
+		if err != nil {
+			chip.unwatch(offsets[:i])
+			return nil, err
+		}
+	}
+
+	chip.watched = offsets
+	chip.watch = make(chan LineChange, 64)
+	go chip.readChanges(chip.watch)
+
+	return chip.watch, nil
+}
+
+func (chip *Chip) unwatch(offsets []int) {
+	for _, off := range offsets {
+		o := uint32(off)
+		ioctlPtr(chip.fd.Fd(), gpioGetLineInfoUnwatchIoctl, unsafe.Pointer(&o))
+	}
+}
+
+func (chip *Chip) readChanges(ch chan LineChange) {
+	defer close(ch)
+
+	var ev gpioV2LineInfoChanged
+	buf := (*[unsafe.Sizeof(ev)]byte)(unsafe.Pointer(&ev))[:]
+
+	for {
+		_, err := io.ReadFull(chip.fd, buf)
+		if err != nil {
+			return
+		}
+
+		change := LineChange{
+			Info:      ev.info.decode(),
+			Type:      LineChangeType(ev.eventType),
+			Timestamp: time.Duration(ev.timestampNs),
+		}
+
+		if len(ch) != cap(ch) {
+			ch <- change
+		}
+	}
+}
+
+func (chip *Chip) Close() error {
+	chip.mutex.Lock()
+	if chip.watch != nil {
+		chip.unwatch(chip.watched)
+		chip.watched, chip.watch = nil, nil
+	}
+	chip.mutex.Unlock()
+
+	return chip.fd.Close()
+}
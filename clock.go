@@ -0,0 +1,71 @@
+package gpio
+
+import (
+	"golang.org/x/sys/unix"
+	"sync"
+	"time"
+)
+
+const (
+	correlationSamples         = 8
+	DefaultCorrelationInterval = time.Minute
+)
+
+// Relates CLOCK_MONOTONIC stamps (kernel event timestamps) to wall clock. The offset is measured
+// from the tightest of several bracketed clock readings and refreshed periodically to follow
+// NTP adjustments.
+type ClockCorrelator struct {
+	Interval time.Duration
+
+	mutex    sync.Mutex
+	offset   time.Duration
+	measured time.Duration // monotonic time of the last measurement
+}
+
+var defaultCorrelator = NewClockCorrelator()
+
+func NewClockCorrelator() *ClockCorrelator {
+	return &ClockCorrelator{Interval: DefaultCorrelationInterval}
+}
+
+// Measure offset now
+func (c *ClockCorrelator) Calibrate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calibrate()
+}
+
+// must be called with mutex held
+func (c *ClockCorrelator) calibrate() {
+	best := time.Duration(-1)
+
+	for i := 0; i < correlationSamples; i++ {
+		var ts unix.Timespec
+
+		m1 := monotonicNow()
+		unix.ClockGettime(unix.CLOCK_REALTIME, &ts)
+		m2 := monotonicNow()
+
+		if d := m2 - m1; best < 0 || d < best {
+			best = d
+			c.offset = time.Duration(ts.Nano()) - (m1 + d/2)
+			c.measured = m2
+		}
+	}
+}
+
+// Offset to add to monotonic time to get Unix time
+func (c *ClockCorrelator) Offset() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.measured == 0 || monotonicNow()-c.measured > c.Interval {
+		c.calibrate()
+	}
+	return c.offset
+}
+
+// Wall clock time of monotonic stamp
+func (c *ClockCorrelator) Time(mono time.Duration) time.Time {
+	return time.Unix(0, int64(mono+c.Offset()))
+}
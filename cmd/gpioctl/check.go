@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// "GPIO22=0 GPIO17=1" -> spec and level
+func parseAssignment(s string) (string, int, error) {
+	i := strings.LastIndexByte(s, '=')
+	if i < 0 {
+		return "", 0, fmt.Errorf("Invalid assignment: %s", s)
+	}
+
+	v, err := strconv.Atoi(s[i+1:])
+	if err != nil || v < 0 || v > 1 {
+		return "", 0, fmt.Errorf("Invalid level: %s", s)
+	}
+	return s[:i], v, nil
+}
+
+type pinCache map[string]gpio.PinReader
+
+func (c pinCache) open(spec string, dir gpio.Direction) (gpio.PinReader, error) {
+	if p, ok := c[spec]; ok {
+		return p, nil
+	}
+
+	p, err := gpio.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	c[spec] = p
+
+	if ds, ok := p.(gpio.DirectionSetter); ok {
+		if err := ds.SetDirection(dir); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (c pinCache) close() {
+	for _, p := range c {
+		if cl, ok := p.(io.Closer); ok {
+			cl.Close()
+		}
+	}
+}
+
+// Expectation file lines: <driven pin>=<level> <sensed pin>=<expected level>
+func check(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Expectation file expected")
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pins := make(pinCache)
+	defer pins.close()
+
+	var checks []gpio.WiringCheck
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("Invalid line: %s", line)
+		}
+
+		dspec, level, err := parseAssignment(fields[0])
+		if err != nil {
+			return err
+		}
+		sspec, expect, err := parseAssignment(fields[1])
+		if err != nil {
+			return err
+		}
+
+		drive, err := pins.open(dspec, gpio.DirOut)
+		if err != nil {
+			return err
+		}
+		w, ok := drive.(gpio.PinWriter)
+		if !ok {
+			return fmt.Errorf("%s is not writable", dspec)
+		}
+
+		sense, err := pins.open(sspec, gpio.DirIn)
+		if err != nil {
+			return err
+		}
+
+		checks = append(checks, gpio.WiringCheck{
+			Name:   line,
+			Drive:  w,
+			Level:  level,
+			Sense:  sense,
+			Expect: expect,
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	res, ok := gpio.CheckWiring(checks)
+	if err := gpio.WriteWiringReport(os.Stdout, res); err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("Wiring check failed")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/e-asphyx/gpio"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var edges = map[string]gpio.Trigger{
+	"rising":  gpio.EdgeRising,
+	"falling": gpio.EdgeFalling,
+	"both":    gpio.EdgeBoth,
+}
+
+func execHook(args []string) error {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	edgeName := fs.String("edge", "both", "rising, falling or both")
+	debounce := fs.Duration("debounce", 0, "debounce interval")
+	timeout := fs.Duration("timeout", 0, "kill command after this time")
+	max := fs.Int("max", 1, "max concurrently running commands")
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		return errors.New("Pin and command expected")
+	}
+
+	edge, ok := edges[*edgeName]
+	if !ok {
+		return errors.New("Invalid edge")
+	}
+
+	p, err := gpio.Parse(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if c, ok := p.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	pin, ok := p.(gpio.PinReadTrigger)
+	if !ok {
+		return errors.New("Pin doesn't support triggers")
+	}
+
+	hook := gpio.NewExecHook(pin, edge, fs.Arg(1), fs.Args()[2:]...)
+	hook.Label = fs.Arg(0)
+	hook.Debounce = *debounce
+	hook.Timeout = *timeout
+	hook.MaxConcurrent = *max
+
+	if err := hook.Start(); err != nil {
+		return err
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	return hook.Close()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"strings"
+)
+
+var flagNames = []struct {
+	flag gpio.LineFlags
+	name string
+}{
+	{gpio.LineActiveLow, "active-low"},
+	{gpio.LineEdgeRising, "rising-edge"},
+	{gpio.LineEdgeFalling, "falling-edge"},
+	{gpio.LineOpenDrain, "open-drain"},
+	{gpio.LineOpenSource, "open-source"},
+	{gpio.LinePullUp, "pull-up"},
+	{gpio.LinePullDown, "pull-down"},
+	{gpio.LineBiasDisabled, "bias-disabled"},
+	{gpio.LineClockRealtime, "clock-realtime"},
+}
+
+func printChip(chip *gpio.Chip) error {
+	lines, err := chip.Lines()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s - %d lines (%s):\n", chip.Name, chip.NumLines, chip.Label)
+
+	for _, l := range lines {
+		name := "unnamed"
+		if l.Name != "" {
+			name = fmt.Sprintf("%q", l.Name)
+		}
+
+		consumer := "unused"
+		if l.Flags&gpio.LineUsed != 0 {
+			consumer = "kernel"
+			if l.Consumer != "" {
+				consumer = fmt.Sprintf("%q", l.Consumer)
+			}
+		}
+
+		dir := "input"
+		if l.Direction() == gpio.DirOut {
+			dir = "output"
+		}
+
+		var flags []string
+		for _, f := range flagNames {
+			if l.Flags&f.flag != 0 {
+				flags = append(flags, f.name)
+			}
+		}
+		if l.Debounce != 0 {
+			flags = append(flags, fmt.Sprintf("debounce=%v", l.Debounce))
+		}
+
+		fmt.Printf("\tline %3d: %-16s %-16s %-6s %s\n", l.Offset, name, consumer, dir, strings.Join(flags, " "))
+	}
+
+	return nil
+}
+
+func info(args []string) error {
+	if len(args) != 0 {
+		for _, name := range args {
+			chip, err := gpio.OpenChip(name)
+			if err != nil {
+				return err
+			}
+			err = printChip(chip)
+			chip.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	chips, err := gpio.Chips()
+	if err != nil {
+		return err
+	}
+
+	for _, chip := range chips {
+		if err == nil {
+			err = printChip(chip)
+		}
+		chip.Close()
+	}
+
+	return err
+}
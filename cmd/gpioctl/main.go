@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"check": {"check <expectation file>", check},
+	"exec":  {"exec [-edge both] [-debounce d] [-timeout d] [-max n] <pin> <command> [args...]", execHook},
+	"info":  {"info [chip...]", info},
+	"scan":  {"scan <i2c bus>", scan},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [args]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", c.usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+	}
+
+	if err := cmd.run(flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
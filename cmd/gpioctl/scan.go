@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/e-asphyx/gpio/i2c"
+	"strconv"
+	"strings"
+)
+
+func scan(args []string) error {
+	if len(args) != 1 {
+		return errors.New("I2C bus number expected")
+	}
+
+	bus, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	a, err := i2c.Open(bus)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	res, err := a.Scan()
+	for _, r := range res {
+		guess := "unknown"
+		if len(r.Guesses) != 0 {
+			guess = strings.Join(r.Guesses, ", ")
+		}
+		if r.Busy {
+			guess += " (in use by driver)"
+		}
+		fmt.Printf("0x%02x: %s\n", r.Addr, guess)
+	}
+	if err == nil && len(res) == 0 {
+		fmt.Println("no devices found")
+	}
+
+	return err
+}
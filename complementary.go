@@ -0,0 +1,232 @@
+package gpio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrDeadTime = errors.New("Dead time exceeds half period")
+
+// Pair of complementary software PWM outputs for driving half-bridge. Both switches are kept
+// off for dead time around every transition. Optional fault input latches both outputs off.
+type ComplementaryPWM struct {
+	high, low PinWriter
+	fault     PinTrigger
+
+	mutex    sync.Mutex
+	period   time.Duration
+	dead     time.Duration
+	duty     float64
+	faulted  bool
+	hi, lo   bool
+	stop     chan struct{}
+	done     chan struct{}
+	faultEnd chan struct{}
+}
+
+// Start generator with zero duty, i.e. low side switch on
+func NewComplementaryPWM(high, low PinWriter, freq float64, deadTime time.Duration) (*ComplementaryPWM, error) {
+	period, err := freqPeriod(freq)
+	if err != nil {
+		return nil, err
+	}
+	if deadTime < 0 || 2*deadTime >= period {
+		return nil, ErrDeadTime
+	}
+
+	if err = high.Write(0); err != nil {
+		return nil, err
+	}
+	if err = low.Write(0); err != nil {
+		return nil, err
+	}
+
+	p := &ComplementaryPWM{
+		high:   high,
+		low:    low,
+		period: period,
+		dead:   deadTime,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go p.run()
+	return p, nil
+}
+
+// Shut both outputs down on fault pin edge until ClearFault is called
+func (p *ComplementaryPWM) SetFaultInput(pin PinReadTrigger, edge Trigger) error {
+	tr, err := pin.Trigger(edge)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	old, oldEnd := p.fault, p.faultEnd
+	p.fault = tr
+	p.faultEnd = make(chan struct{})
+	end := p.faultEnd
+	p.mutex.Unlock()
+
+	if old != nil {
+		old.Close()
+		<-oldEnd
+	}
+
+	go func() {
+		defer close(end)
+		for range tr.Ch() {
+			p.mutex.Lock()
+			p.faulted = true
+			p.setOutputs(false, false)
+			p.mutex.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+func (p *ComplementaryPWM) Faulted() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.faulted
+}
+
+// Resume switching after fault
+func (p *ComplementaryPWM) ClearFault() {
+	p.mutex.Lock()
+	p.faulted = false
+	p.mutex.Unlock()
+}
+
+// Must be called with mutex held. Outputs stay off while faulted.
+func (p *ComplementaryPWM) setOutputs(hi, lo bool) {
+	if p.faulted {
+		hi, lo = false, false
+	}
+
+	// turn off first so both are never on
+	if p.hi && !hi {
+		p.high.Write(0)
+	}
+	if p.lo && !lo {
+		p.low.Write(0)
+	}
+	if !p.hi && hi {
+		p.high.Write(1)
+	}
+	if !p.lo && lo {
+		p.low.Write(1)
+	}
+	p.hi, p.lo = hi, lo
+}
+
+// Switch to a new state inserting dead time if one side is being turned off
+func (p *ComplementaryPWM) switchTo(hi, lo bool) {
+	p.mutex.Lock()
+	if (p.hi && !hi) || (p.lo && !lo) {
+		p.setOutputs(p.hi && hi, p.lo && lo)
+		dead := p.dead
+		p.mutex.Unlock()
+
+		sleepUntil(time.Now().Add(dead))
+		p.mutex.Lock()
+	}
+	p.setOutputs(hi, lo)
+	p.mutex.Unlock()
+}
+
+func (p *ComplementaryPWM) run() {
+	defer close(p.done)
+
+	next := time.Now()
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		p.mutex.Lock()
+		period := p.period
+		dead := p.dead
+		on := time.Duration(float64(period) * p.duty)
+		p.mutex.Unlock()
+
+		start := next
+		next = start.Add(period)
+
+		switch {
+		case on <= 0:
+			p.switchTo(false, true)
+		case on >= period:
+			p.switchTo(true, false)
+		default:
+			p.switchTo(true, false)
+			sleepUntil(start.Add(on))
+			p.switchTo(false, false)
+			if lowOn := period - on - 2*dead; lowOn > 0 {
+				sleepUntil(start.Add(on + dead))
+				p.switchTo(false, true)
+				sleepUntil(next.Add(-dead))
+				p.switchTo(false, false)
+			}
+		}
+
+		sleepUntil(next)
+
+		// don't try to catch up after being preempted
+		if now := time.Now(); now.Sub(next) > period {
+			next = now
+		}
+	}
+}
+
+// High side duty, 0.0 to 1.0. Low side gets the rest of period minus dead time.
+func (p *ComplementaryPWM) SetDuty(duty float64) error {
+	if duty < 0 {
+		duty = 0
+	} else if duty > 1 {
+		duty = 1
+	}
+
+	p.mutex.Lock()
+	p.duty = duty
+	p.mutex.Unlock()
+
+	return nil
+}
+
+func (p *ComplementaryPWM) SetDeadTime(deadTime time.Duration) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if deadTime < 0 || 2*deadTime >= p.period {
+		return ErrDeadTime
+	}
+	p.dead = deadTime
+	return nil
+}
+
+// Stop generator, drive both outputs low and release fault input
+func (p *ComplementaryPWM) Close() error {
+	close(p.stop)
+	<-p.done
+
+	p.mutex.Lock()
+	tr, end := p.fault, p.faultEnd
+	p.fault = nil
+	p.mutex.Unlock()
+
+	if tr != nil {
+		tr.Close()
+		<-end
+	}
+
+	err := p.high.Write(0)
+	if e := p.low.Write(0); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
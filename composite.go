@@ -0,0 +1,204 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// Logic function of several inputs
+type CompositePin struct {
+	pins  []PinReader
+	fn    func(values []int) int
+	mutex sync.Mutex
+	tr    *compositeTrigger
+}
+
+type compositeTrigger struct {
+	eventPipe
+	pin     *CompositePin
+	src     []PinTrigger
+	values  []int
+	value   int
+	trigger Trigger
+	wg      sync.WaitGroup
+}
+
+func newComposite(fn func(values []int) int, pins []PinReader) *CompositePin {
+	return &CompositePin{pins: pins, fn: fn}
+}
+
+// High if all inputs are high
+func And(pins ...PinReader) *CompositePin {
+	return newComposite(func(values []int) int {
+		for _, v := range values {
+			if v == 0 {
+				return 0
+			}
+		}
+		return 1
+	}, pins)
+}
+
+// High if any input is high
+func Or(pins ...PinReader) *CompositePin {
+	return newComposite(func(values []int) int {
+		for _, v := range values {
+			if v != 0 {
+				return 1
+			}
+		}
+		return 0
+	}, pins)
+}
+
+// High if odd number of inputs are high
+func Xor(pins ...PinReader) *CompositePin {
+	return newComposite(func(values []int) int {
+		var res int
+		for _, v := range values {
+			if v != 0 {
+				res ^= 1
+			}
+		}
+		return res
+	}, pins)
+}
+
+// Returns the last known value while trigger is active
+func (c *CompositePin) Read() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.tr != nil {
+		return c.tr.value, nil
+	}
+
+	values := make([]int, len(c.pins))
+	for i, p := range c.pins {
+		v, err := p.Read()
+		if err != nil {
+			return 0, err
+		}
+		values[i] = v
+	}
+	return c.fn(values), nil
+}
+
+// All inputs must implement PinReadTrigger
+func (c *CompositePin) Trigger(edge Trigger) (PinTrigger, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.tr != nil {
+		return nil, ErrTrigger
+	}
+
+	tr := &compositeTrigger{
+		eventPipe: newEventPipe(64),
+		pin:       c,
+		values:    make([]int, len(c.pins)),
+		trigger:   edge,
+	}
+
+	fail := func(err error) (PinTrigger, error) {
+		for _, t := range tr.src {
+			t.Close()
+		}
+		return nil, err
+	}
+
+	for i, p := range c.pins {
+		t, ok := p.(PinReadTrigger)
+		if !ok {
+			return fail(ErrUnsupported)
+		}
+
+		v, err := t.Read()
+		if err != nil {
+			return fail(err)
+		}
+		tr.values[i] = v
+
+		src, err := t.Trigger(EdgeBoth)
+		if err != nil {
+			return fail(err)
+		}
+		tr.src = append(tr.src, src)
+	}
+	tr.value = c.fn(tr.values)
+
+	type inputEvent struct {
+		input int
+		ev    Event
+	}
+
+	events := make(chan inputEvent)
+	for i, src := range tr.src {
+		tr.wg.Add(1)
+		go func(i int, src PinTrigger) {
+			for ev := range src.EventCh() {
+				events <- inputEvent{i, ev}
+			}
+			tr.wg.Done()
+		}(i, src)
+	}
+
+	go func() {
+		tr.wg.Wait()
+		close(events)
+	}()
+
+	go func() {
+		for ie := range events {
+			c.mutex.Lock()
+			tr.values[ie.input] = ie.ev.Value
+			val := c.fn(tr.values)
+			changed := val != tr.value
+			tr.value = val
+			c.mutex.Unlock()
+
+			if changed && (edge == EdgeBoth ||
+				(edge == EdgeRising && val != 0) ||
+				(edge == EdgeFalling && val == 0)) {
+				tr.send(Event{Value: val, Timestamp: ie.ev.Timestamp})
+			}
+		}
+		tr.close()
+	}()
+
+	c.tr = tr
+	return tr, nil
+}
+
+func (c *CompositePin) TriggerWithDebounce(edge Trigger, interval time.Duration) (PinTrigger, error) {
+	return NewDebounceWithInterval(c, edge, interval)
+}
+
+func (tr *compositeTrigger) Ch() <-chan int {
+	return tr.ch
+}
+
+func (tr *compositeTrigger) Close() error {
+	var err error
+	for _, t := range tr.src {
+		if e := t.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	tr.drain()
+
+	tr.pin.mutex.Lock()
+	tr.pin.tr = nil
+	tr.pin.mutex.Unlock()
+
+	return err
+}
+
+func (tr *compositeTrigger) EventCh() <-chan Event {
+	return tr.events
+}
+
+func (tr *compositeTrigger) Trigger() Trigger {
+	return tr.trigger
+}
@@ -0,0 +1,100 @@
+package gpio
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Trigger closed automatically when context is done
+type ctxTrigger struct {
+	PinTrigger
+	stop chan struct{}
+	once sync.Once
+	err  error
+}
+
+func withContext(ctx context.Context, tr PinTrigger, err error) (PinTrigger, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ctxTrigger{
+		PinTrigger: tr,
+		stop:       make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			t.Close()
+		case <-t.stop:
+		}
+	}()
+
+	return t, nil
+}
+
+// Trigger which stops delivering events and closes its channel once ctx is done
+func TriggerContext(ctx context.Context, pin PinReadTrigger, edge Trigger) (PinTrigger, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tr, err := pin.Trigger(edge)
+	return withContext(ctx, tr, err)
+}
+
+// Debounced variant of TriggerContext
+func TriggerWithDebounceContext(ctx context.Context, pin PinReadTrigger, edge Trigger, interval time.Duration) (PinTrigger, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tr, err := pin.TriggerWithDebounce(edge, interval)
+	return withContext(ctx, tr, err)
+}
+
+func (pin *Pin) TriggerCtx(ctx context.Context, edge Trigger) (PinTrigger, error) {
+	return TriggerContext(ctx, pin, edge)
+}
+
+func (l *Line) TriggerCtx(ctx context.Context, edge Trigger) (PinTrigger, error) {
+	return TriggerContext(ctx, l, edge)
+}
+
+// Safe to call more than once
+func (t *ctxTrigger) Close() error {
+	t.once.Do(func() {
+		close(t.stop)
+		t.err = t.PinTrigger.Close()
+	})
+	return t.err
+}
+
+func (t *ctxTrigger) Err() <-chan error {
+	return TriggerErr(t.PinTrigger)
+}
+
+func (t *ctxTrigger) ReadEvents(buf []Event) (int, error) {
+	return ReadEvents(t.PinTrigger, buf)
+}
+
+// Context cancelled on the first matching edge or when parent is done
+func EdgeContext(parent context.Context, pin PinReadTrigger, edge Trigger) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(parent)
+	tr, err := TriggerContext(ctx, pin, edge)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	go func() {
+		select {
+		case <-tr.Ch():
+		case <-ctx.Done():
+		}
+		cancel()
+		tr.Close()
+	}()
+
+	return ctx, cancel, nil
+}
@@ -0,0 +1,155 @@
+package gpio
+
+import "time"
+
+// Pin derived from another one. Methods not supported by the source pin return ErrUnsupported.
+type DerivedPin struct {
+	pin    interface{}
+	name   string
+	invert bool
+}
+
+type invertedTrigger struct {
+	eventPipe
+	src PinTrigger
+}
+
+// Active low view of pin. Values and edges are inverted both ways.
+func Invert(pin interface{}) *DerivedPin {
+	p := &DerivedPin{pin: pin, invert: true}
+	if n, ok := pin.(interface {
+		Name() string
+	}); ok {
+		p.name = n.Name()
+	}
+	return p
+}
+
+// Attach name to pin
+func Named(name string, pin interface{}) *DerivedPin {
+	return &DerivedPin{pin: pin, name: name}
+}
+
+func (p *DerivedPin) Name() string {
+	return p.name
+}
+
+// Source pin
+func (p *DerivedPin) Unwrap() interface{} {
+	return p.pin
+}
+
+func (p *DerivedPin) value(v int) int {
+	if p.invert {
+		if v != 0 {
+			return 0
+		}
+		return 1
+	}
+	return v
+}
+
+func invertEdge(edge Trigger) Trigger {
+	switch edge {
+	case EdgeRising:
+		return EdgeFalling
+	case EdgeFalling:
+		return EdgeRising
+	}
+	return edge
+}
+
+func (p *DerivedPin) Read() (int, error) {
+	r, ok := p.pin.(PinReader)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+
+	val, err := r.Read()
+	if err != nil {
+		return 0, err
+	}
+	return p.value(val), nil
+}
+
+func (p *DerivedPin) Write(value int) error {
+	w, ok := p.pin.(PinWriter)
+	if !ok {
+		return ErrUnsupported
+	}
+	return w.Write(p.value(value))
+}
+
+func (p *DerivedPin) Direction() (Direction, error) {
+	if rw, ok := p.pin.(PinReadWriter); ok {
+		return rw.Direction()
+	}
+	return DirIn, ErrUnsupported
+}
+
+func (p *DerivedPin) wrapTrigger(tr PinTrigger, err error) (PinTrigger, error) {
+	if err != nil || !p.invert {
+		return tr, err
+	}
+
+	it := &invertedTrigger{
+		eventPipe: newEventPipe(cap(tr.EventCh())),
+		src:       tr,
+	}
+
+	go func() {
+		for ev := range tr.EventCh() {
+			ev.Value ^= 1
+			it.forward(ev)
+		}
+		it.close()
+	}()
+
+	return it, nil
+}
+
+func (p *DerivedPin) Trigger(edge Trigger) (PinTrigger, error) {
+	t, ok := p.pin.(PinReadTrigger)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	if p.invert {
+		edge = invertEdge(edge)
+	}
+	return p.wrapTrigger(t.Trigger(edge))
+}
+
+func (p *DerivedPin) TriggerWithDebounce(edge Trigger, interval time.Duration) (PinTrigger, error) {
+	t, ok := p.pin.(PinReadTrigger)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	if p.invert {
+		edge = invertEdge(edge)
+	}
+	return p.wrapTrigger(t.TriggerWithDebounce(edge, interval))
+}
+
+func (it *invertedTrigger) Ch() <-chan int {
+	return it.ch
+}
+
+func (it *invertedTrigger) Close() error {
+	err := it.src.Close()
+	if err != nil {
+		return err
+	}
+
+	it.drain()
+	return nil
+}
+
+func (it *invertedTrigger) EventCh() <-chan Event {
+	return it.events
+}
+
+func (it *invertedTrigger) Trigger() Trigger {
+	return invertEdge(it.src.Trigger())
+}
@@ -0,0 +1,205 @@
+package dht
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type Model int
+
+const (
+	DHT11 Model = iota
+	DHT22       // also AM2302
+)
+
+const (
+	stateTimeout   = 200 * time.Microsecond // longest level in the reply is 80us
+	DefaultRetries = 3
+)
+
+var (
+	ErrTimeout  = errors.New("No response from sensor")
+	ErrChecksum = errors.New("Checksum mismatch")
+)
+
+// Data line, needs an external pull-up. Memory mapped pins like bcm2708.Pin are
+// recommended as sysfs reads may be too slow to sample the reply.
+type Pin interface {
+	gpio.PinReader
+	gpio.PinWriter
+}
+
+// sysfs and character device pins
+type dirSetter interface {
+	SetDirection(dir gpio.Direction) error
+}
+
+// bcm2708.Pin
+type fastDirSetter interface {
+	SetDirection(dir gpio.Direction)
+}
+
+type Reading struct {
+	Temperature float64 // degrees Celsius
+	Humidity    float64 // percent
+}
+
+type Sensor struct {
+	pin   Pin
+	model Model
+
+	// Attempts made by Read, each one waiting for the sensor's minimal sampling interval
+	Retries int
+
+	mutex sync.Mutex
+	last  time.Time
+}
+
+func New(pin Pin, model Model) *Sensor {
+	return &Sensor{
+		pin:     pin,
+		model:   model,
+		Retries: DefaultRetries,
+	}
+}
+
+func (s *Sensor) setDirection(dir gpio.Direction) error {
+	switch p := s.pin.(type) {
+	case dirSetter:
+		return p.SetDirection(dir)
+	case fastDirSetter:
+		p.SetDirection(dir)
+	}
+	return nil
+}
+
+// Shortest interval between two transfers
+func (s *Sensor) interval() time.Duration {
+	if s.model == DHT11 {
+		return time.Second
+	}
+	return 2 * time.Second
+}
+
+func (s *Sensor) Read() (Reading, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	retries := s.Retries
+	if retries < 1 {
+		retries = 1
+	}
+
+	var err error
+	for i := 0; i < retries; i++ {
+		if wait := s.last.Add(s.interval()).Sub(time.Now()); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		var data [5]byte
+		data, err = s.transfer()
+		s.last = time.Now()
+		if err != nil {
+			continue
+		}
+
+		if data[0]+data[1]+data[2]+data[3] != data[4] {
+			err = ErrChecksum
+			continue
+		}
+
+		return s.decode(data), nil
+	}
+
+	return Reading{}, err
+}
+
+func (s *Sensor) decode(data [5]byte) Reading {
+	var r Reading
+	if s.model == DHT11 {
+		r.Humidity = float64(data[0]) + float64(data[1])/10
+		r.Temperature = float64(data[2]) + float64(data[3]&0x7f)/10
+		if data[3]&0x80 != 0 {
+			r.Temperature = -r.Temperature
+		}
+	} else {
+		r.Humidity = float64(uint(data[0])<<8|uint(data[1])) / 10
+		r.Temperature = float64(uint(data[2]&0x7f)<<8|uint(data[3])) / 10
+		if data[2]&0x80 != 0 {
+			r.Temperature = -r.Temperature
+		}
+	}
+	return r
+}
+
+// Time spent at level
+func (s *Sensor) expect(level int) (time.Duration, error) {
+	start := time.Now()
+	for {
+		v, err := s.pin.Read()
+		if err != nil {
+			return 0, err
+		}
+
+		d := time.Now().Sub(start)
+		if v != level {
+			return d, nil
+		}
+		if d > stateTimeout {
+			return 0, ErrTimeout
+		}
+	}
+}
+
+func (s *Sensor) transfer() (data [5]byte, err error) {
+	start := 18 * time.Millisecond
+	if s.model == DHT22 {
+		start = 1100 * time.Microsecond
+	}
+
+	if err = s.setDirection(gpio.DirOut); err != nil {
+		return
+	}
+	if err = s.pin.Write(0); err != nil {
+		return
+	}
+	time.Sleep(start)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// pull-up takes the line high, sensor answers with 80us low and 80us high
+	if err = s.setDirection(gpio.DirIn); err != nil {
+		return
+	}
+	if _, err = s.expect(1); err != nil {
+		return
+	}
+	if _, err = s.expect(0); err != nil {
+		return
+	}
+	if _, err = s.expect(1); err != nil {
+		return
+	}
+
+	// each bit is 50us low followed by 26-28us (0) or 70us (1) high
+	for i := 0; i < 40; i++ {
+		var low, high time.Duration
+		if low, err = s.expect(0); err != nil {
+			return
+		}
+		if high, err = s.expect(1); err != nil {
+			return
+		}
+
+		data[i/8] <<= 1
+		if high > low {
+			data[i/8] |= 1
+		}
+	}
+
+	return
+}
@@ -0,0 +1,141 @@
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Setting of a managed pin found changed behind our back
+type DriftEvent struct {
+	Pin      *Pin
+	Setting  string // "direction" or "edge"
+	Expected string
+	Actual   string
+	Restored bool
+	Err      error // restore error
+}
+
+func (e DriftEvent) String() string {
+	return fmt.Sprintf("%v: %s changed from %s to %s", e.Pin, e.Setting, e.Expected, e.Actual)
+}
+
+// Periodically verifies direction and edge of managed sysfs pins since other processes and
+// drivers are free to change them. Pull resistors aren't visible through sysfs and can't be checked.
+type DriftGuard struct {
+	interval time.Duration
+	restore  bool
+	ch       chan DriftEvent
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Settings are put back if restore is set, drift is reported on channel either way
+func NewDriftGuard(interval time.Duration, restore bool) *DriftGuard {
+	g := &DriftGuard{
+		interval: interval,
+		restore:  restore,
+		ch:       make(chan DriftEvent, 64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go g.run()
+	return g
+}
+
+func (g *DriftGuard) run() {
+	defer close(g.done)
+	defer close(g.ch)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, ev := range CheckDrift(g.restore) {
+				if len(g.ch) != cap(g.ch) {
+					g.ch <- ev
+				}
+			}
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+func (g *DriftGuard) Ch() <-chan DriftEvent {
+	return g.ch
+}
+
+func (g *DriftGuard) Close() error {
+	close(g.stop)
+	<-g.done
+	return nil
+}
+
+func (pin *Pin) readEdge() (string, error) {
+	fd, err := os.Open(fmt.Sprintf("/sys/class/gpio/gpio%d/edge", pin.idx))
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+
+	var val string
+	_, err = fmt.Fscanln(fd, &val)
+	return val, err
+}
+
+// Compare all managed pins against cached settings once
+func CheckDrift(restore bool) []DriftEvent {
+	var events []DriftEvent
+
+	for _, pin := range ManagedPins() {
+		dir, err := pin.Direction()
+		if err != nil {
+			// unexported or closed meanwhile
+			continue
+		}
+
+		if dir != pin.dir {
+			ev := DriftEvent{
+				Pin:      pin,
+				Setting:  "direction",
+				Expected: dirNames[pin.dir],
+				Actual:   dirNames[dir],
+			}
+			if restore {
+				ev.Err = openWriteCloseFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx), dirNames[pin.dir])
+				ev.Restored = ev.Err == nil
+			}
+			events = append(events, ev)
+		}
+
+		// edge only matters while triggered
+		if pin.ch == nil {
+			continue
+		}
+
+		edge, err := pin.readEdge()
+		if err != nil {
+			continue
+		}
+
+		if expected := edgeNames[pin.trigger]; edge != expected {
+			ev := DriftEvent{
+				Pin:      pin,
+				Setting:  "edge",
+				Expected: expected,
+				Actual:   edge,
+			}
+			if restore {
+				ev.Err = pin.setEdge(pin.trigger)
+				ev.Restored = ev.Err == nil
+			}
+			events = append(events, ev)
+		}
+	}
+
+	return events
+}
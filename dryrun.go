@@ -0,0 +1,101 @@
+package gpio
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of dry run writes kept
+const DryRunMaxRecords = 1024
+
+// Write suppressed by dry run mode
+type DryRunRecord struct {
+	Pin   string
+	Value int
+	Time  time.Time
+}
+
+var (
+	dryRun       int32
+	dryRunMutex  sync.Mutex
+	dryRunWrites []DryRunRecord
+
+	// Suppressed writes are logged here if not nil
+	DryRunLogger *log.Logger
+)
+
+// Globally suppress hardware writes. Reads still work.
+func SetDryRun(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&dryRun, v)
+}
+
+func DryRun() bool {
+	return atomic.LoadInt32(&dryRun) != 0
+}
+
+// Record suppressed write. Used by backends.
+func RecordDryRunWrite(pin string, value int) {
+	rec := DryRunRecord{
+		Pin:   pin,
+		Value: value,
+		Time:  time.Now(),
+	}
+
+	dryRunMutex.Lock()
+	dryRunWrites = append(dryRunWrites, rec)
+	if len(dryRunWrites) > DryRunMaxRecords {
+		dryRunWrites = dryRunWrites[len(dryRunWrites)-DryRunMaxRecords:]
+	}
+	dryRunMutex.Unlock()
+
+	if DryRunLogger != nil {
+		DryRunLogger.Printf("dry run: %s <- %d", pin, value)
+	}
+}
+
+// Recorded writes, oldest first
+func DryRunWrites() []DryRunRecord {
+	dryRunMutex.Lock()
+	defer dryRunMutex.Unlock()
+	return append([]DryRunRecord(nil), dryRunWrites...)
+}
+
+func ClearDryRunWrites() {
+	dryRunMutex.Lock()
+	dryRunWrites = nil
+	dryRunMutex.Unlock()
+}
+
+// Per pin dry run for any writer
+func DryRunWriter(name string) Middleware {
+	return Middleware{
+		Write: func(next WriteFunc) WriteFunc {
+			return func(value int) error {
+				RecordDryRunWrite(name, value)
+				return nil
+			}
+		},
+	}
+}
+
+func (pin *Pin) SetDryRun(enable bool) {
+	pin.dryRun = enable
+}
+
+func (pin *Pin) String() string {
+	if pin.name != "" {
+		return pin.name
+	}
+	return fmt.Sprintf("GPIO%d", pin.idx)
+}
+
+func (l *Line) String() string {
+	return fmt.Sprintf("%s:%d", l.chip.Name, l.offset)
+}
@@ -0,0 +1,101 @@
+package gpio
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestRecordDryRunWrite(t *testing.T) {
+	ClearDryRunWrites()
+	defer ClearDryRunWrites()
+
+	var buf bytes.Buffer
+	DryRunLogger = log.New(&buf, "", 0)
+	defer func() { DryRunLogger = nil }()
+
+	for i := 0; i < DryRunMaxRecords+10; i++ {
+		RecordDryRunWrite(fmt.Sprintf("p%d", i), i&1)
+	}
+
+	w := DryRunWrites()
+	if len(w) != DryRunMaxRecords {
+		t.Fatalf("got %d records, want %d", len(w), DryRunMaxRecords)
+	}
+	if w[0].Pin != "p10" || w[len(w)-1].Pin != fmt.Sprintf("p%d", DryRunMaxRecords+9) {
+		t.Errorf("kept %s..%s", w[0].Pin, w[len(w)-1].Pin)
+	}
+	if !strings.HasPrefix(buf.String(), "dry run: p0 <- 0\n") {
+		t.Errorf("got log %q", buf.String()[:40])
+	}
+}
+
+func TestDryRunWriter(t *testing.T) {
+	ClearDryRunWrites()
+	defer ClearDryRunWrites()
+
+	var pin fakePin
+	w := Wrap(&pin, DryRunWriter("relay"))
+	if err := w.Write(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if pin.value != 0 || len(pin.writes) != 0 {
+		t.Errorf("pin written: %v", pin.writes)
+	}
+	if r := DryRunWrites(); len(r) != 1 || r[0].Pin != "relay" || r[0].Value != 1 {
+		t.Errorf("got %+v", r)
+	}
+}
+
+// Pins without sysfs behind them, any hardware access would panic on nil fd
+func TestPinWriteDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     Direction
+		autoDir bool
+		global  bool
+		perPin  bool
+		err     error
+		records int
+	}{
+		{"global", DirOut, false, true, false, nil, 1},
+		{"per pin", DirOut, false, false, true, nil, 1},
+		{"input", DirIn, false, true, false, ErrDirIn, 0},
+		{"input auto direction", DirIn, true, true, false, nil, 1},
+	}
+
+	for _, tt := range tests {
+		ClearDryRunWrites()
+		pin := &Pin{idx: 904, name: "pump", dir: tt.dir}
+		pin.SetAutoDirection(tt.autoDir)
+		pin.SetDryRun(tt.perPin)
+		SetDryRun(tt.global)
+
+		err := pin.Write(1)
+		SetDryRun(false)
+
+		if err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+		if pin.dir != tt.dir {
+			t.Errorf("%s: direction changed", tt.name)
+		}
+		r := DryRunWrites()
+		if len(r) != tt.records || (len(r) != 0 && (r[0].Pin != "pump" || r[0].Value != 1)) {
+			t.Errorf("%s: got %+v", tt.name, r)
+		}
+	}
+	ClearDryRunWrites()
+}
+
+func TestPinString(t *testing.T) {
+	if s := (&Pin{idx: 17}).String(); s != "GPIO17" {
+		t.Errorf("got %q", s)
+	}
+	if s := (&Pin{idx: 17, name: "led"}).String(); s != "led" {
+		t.Errorf("got %q", s)
+	}
+}
@@ -0,0 +1,289 @@
+package gpio
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Position order of (A<<1 | B) states when A leads
+var quadraturePhase = [4]int{0, 3, 1, 2}
+
+// Clicks further apart than this restart velocity estimation
+const encoderVelocityTimeout = 300 * time.Millisecond
+
+// Quadrature rotary encoder delivering +1/-1 steps. Both inputs are decoded with full state
+// machine so contact bounce cancels out instead of producing extra steps. Turning speed is
+// estimated for accelerated scrolling and an integrated push button can be watched alongside.
+type RotaryEncoder struct {
+	trA, trB PinTrigger
+	ch       chan int
+
+	mutex    sync.Mutex
+	state    int
+	raw      int // quarter steps
+	rest     int // raw count at the last detent
+	restPh   int
+	detent   int
+	position int
+
+	velocity float64 // clicks per second, signed
+	lastStep time.Time
+	lastDir  int
+	accRate  float64
+	accMax   int
+
+	button *Button
+}
+
+type encoderInput struct {
+	input int
+	ev    Event
+}
+
+func NewRotaryEncoder(pinA, pinB PinReadTrigger) (*RotaryEncoder, error) {
+	a, err := pinA.Read()
+	if err != nil {
+		return nil, err
+	}
+	b, err := pinB.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &RotaryEncoder{
+		ch:     make(chan int, 64),
+		state:  a<<1 | b,
+		detent: 1,
+	}
+	e.restPh = quadraturePhase[e.state]
+
+	if e.trA, err = pinA.Trigger(EdgeBoth); err != nil {
+		return nil, err
+	}
+	if e.trB, err = pinB.Trigger(EdgeBoth); err != nil {
+		e.trA.Close()
+		return nil, err
+	}
+
+	go e.run()
+
+	return e, nil
+}
+
+// Edges of both inputs are applied in timestamp order, arrival order of near simultaneous
+// edges on two triggers is arbitrary
+func (e *RotaryEncoder) run() {
+	defer close(e.ch)
+
+	chA, chB := e.trA.EventCh(), e.trB.EventCh()
+	var batch []encoderInput
+
+	recv := func(input int, ev Event, ok bool) {
+		if !ok {
+			if input == 0 {
+				chA = nil
+			} else {
+				chB = nil
+			}
+			return
+		}
+		batch = append(batch, encoderInput{input, ev})
+	}
+
+	for chA != nil || chB != nil {
+		select {
+		case ev, ok := <-chA:
+			recv(0, ev, ok)
+		case ev, ok := <-chB:
+			recv(1, ev, ok)
+		}
+
+		// take what is already pending on both inputs
+	pending:
+		for {
+			select {
+			case ev, ok := <-chA:
+				recv(0, ev, ok)
+			case ev, ok := <-chB:
+				recv(1, ev, ok)
+			default:
+				break pending
+			}
+		}
+
+		sort.SliceStable(batch, func(i, j int) bool {
+			return batch[i].ev.Timestamp.Before(batch[j].ev.Timestamp)
+		})
+		for _, in := range batch {
+			e.update(in)
+		}
+		batch = batch[:0]
+	}
+}
+
+// Emit one step per detent. n is the number of quarter steps per click: 1 (default, every
+// transition), 2 or 4 for most mechanical encoders. Steps are counted when the encoder settles
+// in a detent position so partial turns returning back produce nothing.
+func (e *RotaryEncoder) SetDetent(n int) {
+	if n != 2 && n != 4 {
+		n = 1
+	}
+
+	e.mutex.Lock()
+	e.detent = n
+	e.rest = e.raw
+	e.restPh = quadraturePhase[e.state]
+	e.mutex.Unlock()
+}
+
+func (e *RotaryEncoder) update(in encoderInput) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	state := e.state
+	if in.input == 0 {
+		state = state&1 | (in.ev.Value&1)<<1
+	} else {
+		state = state&2 | in.ev.Value&1
+	}
+	if state == e.state {
+		return
+	}
+
+	var delta int
+	switch (quadraturePhase[state] - quadraturePhase[e.state] + 4) % 4 {
+	case 1:
+		delta = 1
+	case 3:
+		delta = -1
+	default:
+		// both inputs changed, direction is unknown
+	}
+	e.state = state
+	e.raw += delta
+
+	if e.detent == 1 {
+		if delta != 0 {
+			e.emit(delta, in.ev.Timestamp)
+		}
+		return
+	}
+
+	if (quadraturePhase[state]-e.restPh+4)%e.detent != 0 {
+		return
+	}
+
+	// round to whole detents so a missed transition doesn't lose the click
+	acc := e.raw - e.rest
+	steps := (acc + e.detent/2) / e.detent
+	if acc < 0 {
+		steps = -((-acc + e.detent/2) / e.detent)
+	}
+	for ; steps > 0; steps-- {
+		e.emit(1, in.ev.Timestamp)
+	}
+	for ; steps < 0; steps++ {
+		e.emit(-1, in.ev.Timestamp)
+	}
+	e.rest = e.raw
+}
+
+// must be called with mutex held
+func (e *RotaryEncoder) emit(step int, ts time.Time) {
+	dt := ts.Sub(e.lastStep)
+	switch {
+	case e.lastStep.IsZero() || dt <= 0 || dt > encoderVelocityTimeout || step != e.lastDir:
+		// a single click has no speed
+		e.velocity = 0
+	case e.velocity == 0:
+		e.velocity = float64(step) / dt.Seconds()
+	default:
+		// smooth out uneven clicks
+		e.velocity = (e.velocity + float64(step)/dt.Seconds()) / 2
+	}
+	e.lastStep, e.lastDir = ts, step
+
+	if e.accMax > 1 && e.accRate > 0 {
+		if m := 1 + int(math.Abs(e.velocity)/e.accRate); m < e.accMax {
+			step *= m
+		} else {
+			step *= e.accMax
+		}
+	}
+
+	e.position += step
+	if len(e.ch) != cap(e.ch) {
+		e.ch <- step
+	}
+}
+
+func (e *RotaryEncoder) Ch() <-chan int {
+	return e.ch
+}
+
+// Sum of emitted steps
+func (e *RotaryEncoder) Position() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.position
+}
+
+// Turning speed in clicks per second, negative in reverse. Zero once the encoder stops.
+func (e *RotaryEncoder) Velocity() float64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.lastStep.IsZero() || time.Since(e.lastStep) > encoderVelocityTimeout {
+		return 0
+	}
+	return e.velocity
+}
+
+// Scale clicks for fast scrolling: each click sent to Ch counts 1 + |velocity|/rate steps,
+// at most max. Max of 1 or less disables acceleration.
+func (e *RotaryEncoder) SetAcceleration(rate float64, max int) {
+	e.mutex.Lock()
+	e.accRate, e.accMax = rate, max
+	e.mutex.Unlock()
+}
+
+// Start watching integrated push button, it's closed together with the encoder
+func (e *RotaryEncoder) WatchButton(b *Button) (<-chan ButtonEvent, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.button != nil {
+		return nil, ErrTrigger
+	}
+
+	ch, err := b.Watch()
+	if err != nil {
+		return nil, err
+	}
+	e.button = b
+	return ch, nil
+}
+
+func (e *RotaryEncoder) Close() error {
+	err := e.trA.Close()
+	if cerr := e.trB.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+
+	e.mutex.Lock()
+	b := e.button
+	e.button = nil
+	e.mutex.Unlock()
+
+	if b != nil {
+		if cerr := b.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	for range e.ch {
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package gpio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type quadEdge struct {
+	input int // 0 for A, 1 for B
+	value int
+}
+
+var (
+	quadCW  = []quadEdge{{0, 1}, {1, 1}, {0, 0}, {1, 0}}
+	quadCCW = []quadEdge{{1, 1}, {0, 1}, {1, 0}, {0, 0}}
+)
+
+func quadRepeat(seq []quadEdge, n int) []quadEdge {
+	var res []quadEdge
+	for i := 0; i < n; i++ {
+		res = append(res, seq...)
+	}
+	return res
+}
+
+func TestRotaryEncoderUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    int // initial A<<1 | B
+		detent   int
+		edges    []quadEdge
+		position int
+		steps    int // number of emitted steps
+	}{
+		{"cw quarter steps", 0, 1, quadCW, 4, 4},
+		{"ccw quarter steps", 0, 1, quadCCW, -4, 4},
+		{"cw full detent", 0, 4, quadCW, 1, 1},
+		{"ccw full detent", 0, 4, quadCCW, -1, 1},
+		{"cw half detents", 0, 2, quadCW, 2, 2},
+		{"ccw half detents", 0, 2, quadCCW, -2, 2},
+		{"several turns", 0, 4, quadRepeat(quadCW, 3), 3, 3},
+		{"start mid cycle", 3, 4, []quadEdge{{0, 0}, {1, 0}, {0, 1}, {1, 1}}, 1, 1},
+		{"repeated level ignored", 0, 1, []quadEdge{{0, 1}, {0, 1}, {1, 1}, {1, 1}}, 2, 2},
+		{"bounce on A", 0, 4, []quadEdge{{0, 1}, {0, 0}, {0, 1}, {0, 0}, {0, 1}, {1, 1}, {0, 0}, {1, 0}}, 1, 1},
+		{"bounce quarter steps", 0, 1, []quadEdge{{0, 1}, {0, 0}, {0, 1}}, 1, 3},
+		{"partial turn back", 0, 4, []quadEdge{{0, 1}, {1, 1}, {1, 0}, {0, 0}}, 0, 0},
+		{"partial turn back half detent", 0, 2, []quadEdge{{0, 1}, {0, 0}}, 0, 0},
+		{"turn and back", 0, 4, append(append([]quadEdge(nil), quadCW...), quadCCW...), 0, 2},
+		{"bounce mid turn", 0, 4, []quadEdge{{0, 1}, {1, 1}, {0, 0}, {0, 1}, {0, 0}, {1, 0}}, 1, 1},
+	}
+
+	for _, tt := range tests {
+		e := &RotaryEncoder{
+			ch:     make(chan int, 64),
+			state:  tt.state,
+			detent: tt.detent,
+			restPh: quadraturePhase[tt.state],
+		}
+		for _, in := range tt.edges {
+			e.update(encoderInput{input: in.input, ev: Event{Value: in.value}})
+		}
+
+		if e.Position() != tt.position || len(e.ch) != tt.steps {
+			t.Errorf("%s: got position %d after %d steps, want %d after %d", tt.name, e.Position(), len(e.ch), tt.position, tt.steps)
+		}
+	}
+}
+
+func TestRotaryEncoderVelocity(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []time.Duration // between clicks, negative for reverse clicks
+		velocity  float64
+	}{
+		{"single click", []time.Duration{0}, 0},
+		{"steady", []time.Duration{0, 100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}, 10},
+		{"steady reverse", []time.Duration{0, -50 * time.Millisecond, -50 * time.Millisecond}, -20},
+		{"speeding up", []time.Duration{0, 100 * time.Millisecond, 50 * time.Millisecond}, 15},
+		{"pause restarts", []time.Duration{0, 10 * time.Millisecond, time.Second}, 0},
+		{"direction change restarts", []time.Duration{0, 10 * time.Millisecond, -10 * time.Millisecond}, 0},
+	}
+
+	for _, tt := range tests {
+		e := &RotaryEncoder{ch: make(chan int, 64), detent: 1}
+		ts := time.Now().Add(-time.Duration(len(tt.intervals)) * 10 * time.Millisecond)
+		for _, d := range tt.intervals {
+			step := 1
+			if d < 0 {
+				step, d = -1, -d
+			}
+			ts = ts.Add(d)
+			e.emit(step, ts)
+		}
+		// estimate is taken at the last click
+		e.lastStep = time.Now()
+
+		if v := e.Velocity(); math.Abs(v-tt.velocity) > 1e-6 {
+			t.Errorf("%s: got %v, want %v", tt.name, v, tt.velocity)
+		}
+	}
+
+	e := &RotaryEncoder{ch: make(chan int, 64), detent: 1}
+	e.emit(1, time.Now().Add(-time.Second))
+	e.emit(1, time.Now().Add(-time.Second+10*time.Millisecond))
+	if v := e.Velocity(); v != 0 {
+		t.Errorf("stopped encoder: got %v, want 0", v)
+	}
+}
+
+func TestRotaryEncoderAcceleration(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		max      int
+		interval time.Duration
+		clicks   int
+		position int
+	}{
+		{"disabled", 10, 1, 10 * time.Millisecond, 5, 5},
+		{"slow", 10, 5, 200 * time.Millisecond, 5, 5},
+		// 20 clicks/s gives 3 steps per click after the first one
+		{"fast", 10, 5, 50 * time.Millisecond, 5, 1 + 4*3},
+		// 100 clicks/s is capped at max
+		{"capped", 10, 5, 10 * time.Millisecond, 5, 1 + 4*5},
+	}
+
+	for _, tt := range tests {
+		e := &RotaryEncoder{ch: make(chan int, 64), detent: 1}
+		e.SetAcceleration(tt.rate, tt.max)
+
+		ts := time.Now()
+		for i := 0; i < tt.clicks; i++ {
+			e.emit(1, ts)
+			ts = ts.Add(tt.interval)
+		}
+
+		sum := 0
+		for len(e.ch) != 0 {
+			sum += <-e.ch
+		}
+		if e.Position() != tt.position || sum != tt.position {
+			t.Errorf("%s: got position %d, sent %d, want %d", tt.name, e.Position(), sum, tt.position)
+		}
+	}
+}
+
+func TestRotaryEncoderButton(t *testing.T) {
+	var a, b, btn fakePin
+
+	e, err := NewRotaryEncoder(&a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	button := NewButton(&btn)
+	button.Debounce, button.Hold, button.DoubleClick = 0, 0, 0
+
+	ch, err := e.WatchButton(button)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.WatchButton(NewButton(&btn)); err != ErrTrigger {
+		t.Errorf("second button: got %v, want %v", err, ErrTrigger)
+	}
+
+	btn.set(1, time.Now())
+	if ev := <-ch; ev != ButtonPressed {
+		t.Errorf("got %v, want %v", ev, ButtonPressed)
+	}
+
+	a.set(1, time.Now())
+	if step := <-e.Ch(); step != 1 {
+		t.Errorf("got step %d, want 1", step)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// button is closed with the encoder
+	for range ch {
+	}
+}
@@ -0,0 +1,136 @@
+package gpio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Emergency stop notification
+type EStopEvent struct {
+	Input int // index of asserted input
+	Time  time.Time
+}
+
+// Drives outputs to safe states as soon as any input is asserted. For *Pin inputs this happens
+// right in the event loop before any event is delivered to application.
+type EStop struct {
+	inputs   []PinReadTrigger
+	triggers []PinTrigger
+	active   int
+	safe     map[PinWriter]int
+	ch       chan EStopEvent
+	mutex    sync.Mutex
+	tripped  bool
+	asserted []bool
+	wg       sync.WaitGroup
+}
+
+var ErrEStopAsserted = errors.New("Emergency stop input still asserted")
+
+// active is the asserted input level
+func NewEStop(inputs []PinReadTrigger, active int, safe map[PinWriter]int) (*EStop, error) {
+	e := &EStop{
+		inputs:   inputs,
+		active:   active,
+		safe:     safe,
+		ch:       make(chan EStopEvent, len(inputs)),
+		asserted: make([]bool, len(inputs)),
+	}
+
+	for i, in := range inputs {
+		val, err := in.Read()
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+
+		i := i
+		hook := func(val int) { e.handle(i, val) }
+
+		var tr PinTrigger
+		pin, direct := in.(*Pin)
+		if direct {
+			tr, err = pin.triggerWithHook(EdgeBoth, hook)
+		} else {
+			tr, err = in.Trigger(EdgeBoth)
+		}
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+		e.triggers = append(e.triggers, tr)
+
+		e.wg.Add(1)
+		go func(tr PinTrigger, direct bool) {
+			defer e.wg.Done()
+			for val := range tr.Ch() {
+				if !direct {
+					hook(val)
+				}
+			}
+		}(tr, direct)
+
+		e.handle(i, val)
+	}
+
+	return e, nil
+}
+
+func (e *EStop) handle(input int, val int) {
+	asserted := (val != 0) == (e.active != 0)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.asserted[input] = asserted
+	if !asserted || e.tripped {
+		return
+	}
+
+	e.tripped = true
+	for pin, v := range e.safe {
+		pin.Write(v)
+	}
+
+	select {
+	case e.ch <- EStopEvent{Input: input, Time: time.Now()}:
+	default:
+	}
+}
+
+// Trip notifications
+func (e *EStop) Ch() <-chan EStopEvent {
+	return e.ch
+}
+
+func (e *EStop) Tripped() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.tripped
+}
+
+// Rearm after all inputs are released
+func (e *EStop) Reset() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, a := range e.asserted {
+		if a {
+			return ErrEStopAsserted
+		}
+	}
+	e.tripped = false
+	return nil
+}
+
+func (e *EStop) Close() error {
+	var err error
+	for _, tr := range e.triggers {
+		if e := tr.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	e.wg.Wait()
+	return err
+}
@@ -0,0 +1,187 @@
+package gpio
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Edge event. Seq is counted per trigger including events dropped because the consumer
+// didn't keep up, so gaps reveal lost edges.
+type Event struct {
+	Value     int
+	Timestamp time.Time
+	Seq       uint64
+}
+
+// Trigger able to deliver events in batches
+type EventReader interface {
+	// Blocks until at least one event is available, then drains pending events up to len(buf).
+	// Returns io.EOF after trigger is closed.
+	ReadEvents(buf []Event) (int, error)
+}
+
+// Trigger keeping recent events
+type HistoryKeeper interface {
+	// Keep last n events, zero disables history
+	SetHistory(n int)
+	// Recorded events, oldest first
+	History() []Event
+}
+
+// Channels of a wrapping trigger. Events are dropped if the consumer doesn't keep up.
+type eventPipe struct {
+	ch     chan int
+	events chan Event
+	seq    uint64
+}
+
+func newEventPipe(n int) eventPipe {
+	return eventPipe{
+		ch:     make(chan int, n),
+		events: make(chan Event, n),
+	}
+}
+
+// Renumber and deliver
+func (p *eventPipe) send(ev Event) {
+	p.seq++
+	ev.Seq = p.seq
+	p.forward(ev)
+}
+
+// Deliver keeping source sequence number
+func (p *eventPipe) forward(ev Event) {
+	if len(p.ch) != cap(p.ch) {
+		p.ch <- ev.Value
+	}
+	if len(p.events) != cap(p.events) {
+		p.events <- ev
+	}
+}
+
+func (p *eventPipe) close() {
+	close(p.ch)
+	close(p.events)
+}
+
+// Wait for the producer to close channels
+func (p *eventPipe) drain() {
+	for range p.ch {
+	}
+	for range p.events {
+	}
+}
+
+// Fixed size ring of events
+type eventRing struct {
+	buf   []Event
+	pos   int
+	n     int
+	mutex sync.Mutex
+}
+
+func (r *eventRing) resize(n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old := r.snapshot()
+	if len(old) > n {
+		old = old[len(old)-n:]
+	}
+
+	r.buf = make([]Event, n)
+	r.n = copy(r.buf, old)
+	r.pos = 0
+	if n != 0 {
+		r.pos = r.n % n
+	}
+}
+
+func (r *eventRing) push(ev Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.buf) == 0 {
+		return
+	}
+
+	r.buf[r.pos] = ev
+	r.pos = (r.pos + 1) % len(r.buf)
+	if r.n < len(r.buf) {
+		r.n++
+	}
+}
+
+// must be called with mutex held
+func (r *eventRing) snapshot() []Event {
+	res := make([]Event, 0, r.n)
+	if r.n == 0 {
+		return res
+	}
+
+	start := (r.pos - r.n + len(r.buf)) % len(r.buf)
+	for i := 0; i < r.n; i++ {
+		res = append(res, r.buf[(start+i)%len(r.buf)])
+	}
+	return res
+}
+
+func (r *eventRing) events() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.snapshot()
+}
+
+func (pin *gpioTrigger) SetHistory(n int) {
+	pin.history.resize(n)
+}
+
+func (pin *gpioTrigger) History() []Event {
+	return pin.history.events()
+}
+
+func (pin *gpioTrigger) EventCh() <-chan Event {
+	return pin.events
+}
+
+func (pin *gpioTrigger) ReadEvents(buf []Event) (int, error) {
+	return readEvents(pin.events, buf)
+}
+
+func readEvents(ch <-chan Event, buf []Event) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	ev, ok := <-ch
+	if !ok {
+		return 0, io.EOF
+	}
+	buf[0] = ev
+
+	n := 1
+	for n < len(buf) {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return n, nil
+			}
+			buf[n] = ev
+			n++
+
+		default:
+			return n, nil
+		}
+	}
+
+	return n, nil
+}
+
+// Batch read from any trigger
+func ReadEvents(tr PinTrigger, buf []Event) (int, error) {
+	if r, ok := tr.(EventReader); ok {
+		return r.ReadEvents(buf)
+	}
+	return readEvents(tr.EventCh(), buf)
+}
@@ -0,0 +1,103 @@
+package gpio
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReadEvents(t *testing.T) {
+	var pin fakePin
+	tr, _ := pin.Trigger(EdgeBoth)
+
+	buf := make([]Event, 2)
+	if n, err := ReadEvents(tr, buf[:0]); n != 0 || err != nil {
+		t.Errorf("empty buffer: %d, %v", n, err)
+	}
+
+	// blocks until the first event
+	go pin.set(1, time.Unix(1, 0))
+	if n, err := ReadEvents(tr, buf); n != 1 || err != nil || buf[0].Value != 1 {
+		t.Fatalf("got %d %v, %v", n, buf[:n], err)
+	}
+
+	for i := 0; i < 3; i++ {
+		pin.set(i&1, time.Unix(int64(i+2), 0))
+	}
+	if n, _ := ReadEvents(tr, buf); n != 2 || buf[0].Value != 0 || buf[1].Value != 1 {
+		t.Errorf("got %v", buf[:n])
+	}
+
+	tr.Close()
+	if n, err := ReadEvents(tr, buf); n != 1 || err != nil || buf[0].Timestamp != time.Unix(4, 0) {
+		t.Errorf("pending after close: %d %v, %v", n, buf[:n], err)
+	}
+	if n, err := ReadEvents(tr, buf); n != 0 || err != io.EOF {
+		t.Errorf("got %d, %v, want io.EOF", n, err)
+	}
+}
+
+func TestEventRing(t *testing.T) {
+	var r eventRing
+	r.push(Event{Seq: 1})
+	if h := r.events(); len(h) != 0 {
+		t.Errorf("history disabled, got %v", h)
+	}
+
+	r.resize(3)
+	for i := uint64(1); i <= 5; i++ {
+		r.push(Event{Seq: i})
+	}
+	checkSeq := func(want ...uint64) {
+		h := r.events()
+		if len(h) != len(want) {
+			t.Fatalf("got %v, want seq %v", h, want)
+		}
+		for i := range want {
+			if h[i].Seq != want[i] {
+				t.Fatalf("got %v, want seq %v", h, want)
+			}
+		}
+	}
+	checkSeq(3, 4, 5)
+
+	// growing keeps order, shrinking keeps the newest
+	r.resize(5)
+	r.push(Event{Seq: 6})
+	checkSeq(3, 4, 5, 6)
+	r.resize(2)
+	checkSeq(5, 6)
+	r.push(Event{Seq: 7})
+	checkSeq(6, 7)
+
+	r.resize(0)
+	checkSeq()
+}
+
+func TestEventPipe(t *testing.T) {
+	p := newEventPipe(2)
+
+	// sequence keeps counting over dropped events
+	for i := 0; i < 3; i++ {
+		p.send(Event{Value: i & 1, Seq: 100})
+	}
+	if len(p.ch) != 2 || len(p.events) != 2 {
+		t.Fatalf("queued %d values, %d events", len(p.ch), len(p.events))
+	}
+	if ev := <-p.events; ev.Seq != 1 {
+		t.Errorf("got seq %d, want 1", ev.Seq)
+	}
+	<-p.events
+	p.send(Event{})
+	if ev := <-p.events; ev.Seq != 4 {
+		t.Errorf("got seq %d after drop, want 4", ev.Seq)
+	}
+
+	p.forward(Event{Seq: 100})
+	if ev := <-p.events; ev.Seq != 100 {
+		t.Errorf("forwarded seq %d", ev.Seq)
+	}
+
+	p.close()
+	p.drain()
+}
@@ -0,0 +1,184 @@
+package gpio
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Command run for pin events with PIN, VALUE and TIMESTAMP added to its environment
+type ExecCommand struct {
+	Timeout       time.Duration // command is killed after this time, zero means no limit
+	MaxConcurrent int           // at least one
+
+	name string
+	args []string
+
+	once sync.Once
+	sem  chan struct{}
+}
+
+func NewExecCommand(name string, args ...string) *ExecCommand {
+	return &ExecCommand{
+		MaxConcurrent: 1,
+		name:          name,
+		args:          args,
+	}
+}
+
+// Concurrency limit is fixed on first run
+func (c *ExecCommand) slots() chan struct{} {
+	c.once.Do(func() {
+		n := c.MaxConcurrent
+		if n < 1 {
+			n = 1
+		}
+		c.sem = make(chan struct{}, n)
+	})
+	return c.sem
+}
+
+// Runs command for event waiting for a free slot if MaxConcurrent commands are running
+func (c *ExecCommand) Run(ctx context.Context, pin string, ev Event) error {
+	sem := c.slots()
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-sem }()
+
+	return c.run(ctx, pin, ev)
+}
+
+// Starts command in background, returns false if all slots are busy
+func (c *ExecCommand) tryRun(ctx context.Context, pin string, ev Event, wg *sync.WaitGroup) bool {
+	sem := c.slots()
+	select {
+	case sem <- struct{}{}:
+	default:
+		return false
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() { <-sem }()
+
+		if err := c.run(ctx, pin, ev); err != nil {
+			log.Println(err)
+		}
+	}()
+	return true
+}
+
+func (c *ExecCommand) run(ctx context.Context, pin string, ev Event) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, c.name, c.args...)
+	cmd.Env = append(os.Environ(),
+		"PIN="+pin,
+		"VALUE="+strconv.Itoa(ev.Value),
+		"TIMESTAMP="+ev.Timestamp.Format(time.RFC3339Nano))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// Runs a command on pin edges. Edges arriving while MaxConcurrent commands are still
+// running are dropped.
+type ExecHook struct {
+	ExecCommand
+	Label    string        // PIN value, pin name by default
+	Debounce time.Duration // zero disables debouncing
+
+	pin  PinReadTrigger
+	edge Trigger
+
+	mutex sync.Mutex
+	tr    PinTrigger
+	wg    sync.WaitGroup
+	stop  context.CancelFunc
+}
+
+func NewExecHook(pin PinReadTrigger, edge Trigger, name string, args ...string) *ExecHook {
+	h := &ExecHook{
+		ExecCommand: ExecCommand{
+			MaxConcurrent: 1,
+			name:          name,
+			args:          args,
+		},
+		pin:  pin,
+		edge: edge,
+	}
+	if n, ok := pin.(interface {
+		Name() string
+	}); ok {
+		h.Label = n.Name()
+	}
+	return h
+}
+
+func (h *ExecHook) Start() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.tr != nil {
+		return ErrTrigger
+	}
+
+	var (
+		tr  PinTrigger
+		err error
+	)
+	if h.Debounce > 0 {
+		tr, err = h.pin.TriggerWithDebounce(h.edge, h.Debounce)
+	} else {
+		tr, err = h.pin.Trigger(h.edge)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.tr, h.stop = tr, cancel
+
+	h.wg.Add(1)
+	go h.serve(ctx, tr)
+
+	return nil
+}
+
+func (h *ExecHook) serve(ctx context.Context, tr PinTrigger) {
+	defer h.wg.Done()
+
+	for ev := range tr.EventCh() {
+		h.tryRun(ctx, h.Label, ev, &h.wg)
+	}
+}
+
+// Stops watching the pin and kills running commands
+func (h *ExecHook) Close() error {
+	h.mutex.Lock()
+	tr, stop := h.tr, h.stop
+	h.tr, h.stop = nil, nil
+	h.mutex.Unlock()
+
+	if tr == nil {
+		return nil
+	}
+
+	err := tr.Close()
+	stop()
+	h.wg.Wait()
+	return err
+}
@@ -0,0 +1,506 @@
+package expander
+
+import (
+	"errors"
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"github.com/e-asphyx/gpio/i2c"
+	"sync"
+	"time"
+)
+
+const (
+	max7300RegConfig     = 0x04
+	max7300RegTransMask  = 0x06
+	max7300RegPortConfig = 0x08 // + port/4
+	max7300RegPort       = 0x20 // + port
+	max7300RegPorts      = 0x40 // + first port, 8 ports
+
+	max7300ConfigRun   = 0x01
+	max7300ConfigTrans = 0x80
+
+	max7300Output   = 1
+	max7300Input    = 2
+	max7300InputPup = 3
+
+	// Ports monitored by transition detection, P31 acts as INT output
+	max7300FirstTrans = 24
+	max7300LastTrans  = 30
+)
+
+var (
+	ErrPort      = errors.New("Invalid port number")
+	ErrInterrupt = errors.New("Interrupt pin not set")
+)
+
+// MAX7300 20/28 port I2C expander. Ports are numbered as in the datasheet,
+// P4-P31 on the 28 port part and P12-P31 on the 20 port one.
+type MAX7300 struct {
+	dev   i2c.Device
+	first int
+
+	mutex    sync.Mutex
+	irq      gpio.PinReadTrigger
+	irqTr    gpio.PinTrigger
+	triggers map[int]*max7300Trigger
+	last     byte // P24-P31 snapshot
+
+	// shadow copy of port configuration and output registers
+	config      [8]byte
+	configValid [8]bool
+	out         uint32
+	outValid    uint32
+}
+
+// MAX7300 port
+type MAX7300Port struct {
+	m   *MAX7300
+	num int
+}
+
+type max7300Trigger struct {
+	port   *MAX7300Port
+	edge   gpio.Trigger
+	ch     chan int
+	events chan gpio.Event
+	seq    uint64
+}
+
+// Initialize the expander and take it out of shutdown. ports is 20 or 28.
+func NewMAX7300(bus i2c.Bus, addr uint16, ports int) (*MAX7300, error) {
+	if ports != 20 && ports != 28 {
+		return nil, ErrPort
+	}
+
+	m := &MAX7300{
+		dev:      i2c.Device{Bus: bus, Addr: addr},
+		first:    32 - ports,
+		triggers: make(map[int]*max7300Trigger),
+	}
+
+	if err := m.dev.WriteReg(max7300RegConfig, max7300ConfigRun); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Host input wired to P31 (INT). Required for triggers.
+func (m *MAX7300) SetInterrupt(pin gpio.PinReadTrigger) {
+	m.mutex.Lock()
+	m.irq = pin
+	m.mutex.Unlock()
+}
+
+func (m *MAX7300) Pin(num int) (*MAX7300Port, error) {
+	if num < m.first || num > 31 {
+		return nil, ErrPort
+	}
+	return &MAX7300Port{m: m, num: num}, nil
+}
+
+// Read 8 consecutive ports starting from first. Bit 0 is the first port.
+func (m *MAX7300) ReadPorts(first int) (byte, error) {
+	if first < m.first || first > 31 {
+		return 0, ErrPort
+	}
+	return m.dev.ReadByteReg(byte(max7300RegPorts + first))
+}
+
+// Write 8 consecutive ports starting from first. Ports configured as inputs are unaffected.
+func (m *MAX7300) WritePorts(first int, value byte) error {
+	if first < m.first || first > 31 {
+		return ErrPort
+	}
+
+	if gpio.DryRun() {
+		for i := first; i < first+8 && i <= 31; i++ {
+			gpio.RecordDryRunWrite(m.portName(i), int(value>>uint(i-first))&1)
+		}
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.dev.WriteReg(byte(max7300RegPorts+first), value); err != nil {
+		return err
+	}
+
+	// ports past P31 don't exist
+	mask := uint32(0xff) << uint(first)
+	m.out = m.out&^mask | uint32(value)<<uint(first)
+	m.outValid |= mask
+	return nil
+}
+
+// Drop cached registers, e.g. after the chip was power cycled
+func (m *MAX7300) Invalidate() {
+	m.mutex.Lock()
+	m.configValid = [8]bool{}
+	m.outValid = 0
+	m.mutex.Unlock()
+}
+
+// Configuration register reads back with shutdown bit clear after the chip was reset.
+// Port configuration, outputs and transition detection are restored then. Returns true if reset was detected.
+func (m *MAX7300) CheckReset() (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	v, err := m.dev.ReadByteReg(max7300RegConfig)
+	if err != nil {
+		return false, err
+	}
+	if v&max7300ConfigRun != 0 {
+		return false, nil
+	}
+
+	// outputs first so they come up at the right level
+	for num := m.first; num < 32; num++ {
+		bit := uint32(1) << uint(num)
+		if m.outValid&bit == 0 {
+			continue
+		}
+		if err = m.dev.WriteReg(byte(max7300RegPort+num), byte(m.out>>uint(num))&1); err != nil {
+			return true, err
+		}
+	}
+
+	for i, valid := range m.configValid {
+		if !valid {
+			continue
+		}
+		if err = m.dev.WriteReg(byte(max7300RegPortConfig+i), m.config[i]); err != nil {
+			return true, err
+		}
+	}
+
+	if err = m.armTransition(); err != nil {
+		return true, err
+	}
+
+	cur, err := m.dev.ReadByteReg(byte(max7300RegPorts + max7300FirstTrans))
+	if err != nil {
+		return true, err
+	}
+	m.last = cur
+	return true, nil
+}
+
+// must be called with mutex held
+func (m *MAX7300) configReg(num int) (byte, error) {
+	i := num / 4
+	if !m.configValid[i] {
+		v, err := m.dev.ReadByteReg(byte(max7300RegPortConfig + i))
+		if err != nil {
+			return 0, err
+		}
+		m.config[i], m.configValid[i] = v, true
+	}
+	return m.config[i], nil
+}
+
+// must be called with mutex held
+func (m *MAX7300) portConfigLocked(num int) (byte, error) {
+	v, err := m.configReg(num)
+	if err != nil {
+		return 0, err
+	}
+	return (v >> uint(num%4*2)) & 3, nil
+}
+
+func (m *MAX7300) portConfig(num int) (byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.portConfigLocked(num)
+}
+
+func (m *MAX7300) setPortConfig(num int, cfg byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	v, err := m.configReg(num)
+	if err != nil {
+		return err
+	}
+
+	shift := uint(num % 4 * 2)
+	nv := v&^(3<<shift) | cfg<<shift
+	if nv == v {
+		return nil
+	}
+
+	i := num / 4
+	if err = m.dev.WriteReg(byte(max7300RegPortConfig+i), nv); err != nil {
+		m.configValid[i] = false
+		return err
+	}
+	m.config[i] = nv
+	return nil
+}
+
+// must be called with mutex held
+func (m *MAX7300) armTransition() error {
+	var mask byte
+	for num := range m.triggers {
+		mask |= 1 << uint(num-max7300FirstTrans)
+	}
+
+	if err := m.dev.WriteReg(max7300RegTransMask, mask); err != nil {
+		return err
+	}
+
+	if mask == 0 {
+		return m.dev.WriteReg(max7300RegConfig, max7300ConfigRun)
+	}
+	// takes new snapshot and clears INT
+	return m.dev.WriteReg(max7300RegConfig, max7300ConfigRun|max7300ConfigTrans)
+}
+
+func (m *MAX7300) serve(tr gpio.PinTrigger) {
+	for range tr.Ch() {
+		now := time.Now()
+		m.mutex.Lock()
+
+		// rearm first so changes happening after the read aren't lost
+		if err := m.armTransition(); err != nil {
+			m.mutex.Unlock()
+			continue
+		}
+
+		cur, err := m.dev.ReadByteReg(byte(max7300RegPorts + max7300FirstTrans))
+		if err != nil {
+			m.mutex.Unlock()
+			continue
+		}
+
+		changed := cur ^ m.last
+		m.last = cur
+
+		for num, t := range m.triggers {
+			bit := uint(num - max7300FirstTrans)
+			if changed&(1<<bit) == 0 {
+				continue
+			}
+
+			val := int(cur>>bit) & 1
+			if (t.edge == gpio.EdgeRising && val == 0) || (t.edge == gpio.EdgeFalling && val == 1) {
+				continue
+			}
+
+			t.seq++
+			if len(t.ch) != cap(t.ch) {
+				t.ch <- val
+			}
+			if len(t.events) != cap(t.events) {
+				t.events <- gpio.Event{Value: val, Timestamp: now, Seq: t.seq}
+			}
+		}
+
+		m.mutex.Unlock()
+	}
+}
+
+func (p *MAX7300Port) Num() int {
+	return p.num
+}
+
+// Outputs are read from cache without bus access once written
+func (p *MAX7300Port) Read() (int, error) {
+	m := p.m
+	bit := uint32(1) << uint(p.num)
+
+	m.mutex.Lock()
+	cfg, err := m.portConfigLocked(p.num)
+	if err == nil && cfg == max7300Output && m.outValid&bit != 0 {
+		v := m.out & bit
+		m.mutex.Unlock()
+		return int(v >> uint(p.num)), nil
+	}
+	m.mutex.Unlock()
+
+	return p.ReadBack()
+}
+
+// Forget cached output level. Implements gpio.Invalidator.
+func (p *MAX7300Port) Invalidate() {
+	p.m.mutex.Lock()
+	p.m.outValid &^= 1 << uint(p.num)
+	p.m.mutex.Unlock()
+}
+
+// Port register read bypassing the cache. Implements gpio.ReadBacker.
+func (p *MAX7300Port) ReadBack() (int, error) {
+	v, err := p.m.dev.ReadByteReg(byte(max7300RegPort + p.num))
+	if err != nil {
+		return 0, err
+	}
+	return int(v & 1), nil
+}
+
+func (m *MAX7300) portName(num int) string {
+	return fmt.Sprintf("max7300@0x%02x:%d", m.dev.Addr, num)
+}
+
+func (p *MAX7300Port) String() string {
+	return p.m.portName(p.num)
+}
+
+func (p *MAX7300Port) Write(value int) error {
+	if gpio.DryRun() {
+		gpio.RecordDryRunWrite(p.String(), value)
+		return nil
+	}
+
+	m := p.m
+	bit := uint32(1) << uint(p.num)
+
+	var v byte
+	if value != 0 {
+		v = 1
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.outValid&bit != 0 && (m.out&bit != 0) == (v != 0) {
+		return nil
+	}
+
+	if err := m.dev.WriteReg(byte(max7300RegPort+p.num), v); err != nil {
+		m.outValid &^= bit
+		return err
+	}
+
+	if v != 0 {
+		m.out |= bit
+	} else {
+		m.out &^= bit
+	}
+	m.outValid |= bit
+	return nil
+}
+
+func (p *MAX7300Port) Direction() (gpio.Direction, error) {
+	cfg, err := p.m.portConfig(p.num)
+	if err != nil {
+		return gpio.DirIn, err
+	}
+	if cfg == max7300Output {
+		return gpio.DirOut, nil
+	}
+	return gpio.DirIn, nil
+}
+
+// Inputs keep their pull-up setting when switched back from output
+func (p *MAX7300Port) SetDirection(dir gpio.Direction) error {
+	if dir == gpio.DirOut {
+		return p.m.setPortConfig(p.num, max7300Output)
+	}
+	return p.m.setPortConfig(p.num, max7300Input)
+}
+
+// Switch port to input with or without internal pull-up
+func (p *MAX7300Port) SetPullUp(pullUp bool) error {
+	if pullUp {
+		return p.m.setPortConfig(p.num, max7300InputPup)
+	}
+	return p.m.setPortConfig(p.num, max7300Input)
+}
+
+// Transition detection is available on P24-P30 only and requires the interrupt pin
+func (p *MAX7300Port) Trigger(edge gpio.Trigger) (gpio.PinTrigger, error) {
+	if p.num < max7300FirstTrans || p.num > max7300LastTrans {
+		return nil, gpio.ErrUnsupported
+	}
+
+	m := p.m
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.irq == nil {
+		return nil, ErrInterrupt
+	}
+	if _, ok := m.triggers[p.num]; ok {
+		return nil, gpio.ErrTrigger
+	}
+
+	if m.irqTr == nil {
+		tr, err := m.irq.Trigger(gpio.EdgeRising)
+		if err != nil {
+			return nil, err
+		}
+		m.irqTr = tr
+		go m.serve(tr)
+	}
+
+	t := &max7300Trigger{
+		port:   p,
+		edge:   edge,
+		ch:     make(chan int, 64),
+		events: make(chan gpio.Event, 64),
+	}
+	m.triggers[p.num] = t
+
+	if err := m.armTransition(); err != nil {
+		delete(m.triggers, p.num)
+		return nil, err
+	}
+
+	cur, err := m.dev.ReadByteReg(byte(max7300RegPorts + max7300FirstTrans))
+	if err != nil {
+		delete(m.triggers, p.num)
+		return nil, err
+	}
+	m.last = cur
+
+	return t, nil
+}
+
+func (p *MAX7300Port) TriggerWithDebounce(edge gpio.Trigger, interval time.Duration) (gpio.PinTrigger, error) {
+	return gpio.NewDebounceWithInterval(p, edge, interval)
+}
+
+func (t *max7300Trigger) Ch() <-chan int {
+	return t.ch
+}
+
+func (t *max7300Trigger) EventCh() <-chan gpio.Event {
+	return t.events
+}
+
+func (t *max7300Trigger) Trigger() gpio.Trigger {
+	return t.edge
+}
+
+func (t *max7300Trigger) Close() error {
+	m := t.port.m
+	m.mutex.Lock()
+
+	if m.triggers[t.port.num] != t {
+		m.mutex.Unlock()
+		return gpio.ErrInvalid
+	}
+
+	delete(m.triggers, t.port.num)
+	close(t.ch)
+	close(t.events)
+	err := m.armTransition()
+
+	var irqTr gpio.PinTrigger
+	if len(m.triggers) == 0 {
+		irqTr = m.irqTr
+		m.irqTr = nil
+	}
+	m.mutex.Unlock()
+
+	// serve exits once the interrupt channel is closed
+	if irqTr != nil {
+		if e := irqTr.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
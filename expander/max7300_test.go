@@ -0,0 +1,205 @@
+package expander
+
+import (
+	"github.com/e-asphyx/gpio"
+	"testing"
+	"time"
+)
+
+func newTestMAX7300(t *testing.T) (*MAX7300, *regBus) {
+	bus := &regBus{}
+	// power-on default: all ports are inputs without pull-up
+	for i := 0; i < 8; i++ {
+		bus.regs[max7300RegPortConfig+i] = 0xaa
+	}
+
+	m, err := NewMAX7300(bus, 0x40, 28)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bus.regs[max7300RegConfig] != max7300ConfigRun {
+		t.Fatalf("config = %#x", bus.regs[max7300RegConfig])
+	}
+	bus.reset()
+	return m, bus
+}
+
+func TestMAX7300Ports(t *testing.T) {
+	if _, err := NewMAX7300(&regBus{}, 0x40, 16); err != ErrPort {
+		t.Errorf("got %v, want ErrPort", err)
+	}
+
+	m, _ := newTestMAX7300(t)
+	for _, num := range []int{3, 32} {
+		if _, err := m.Pin(num); err != ErrPort {
+			t.Errorf("Pin(%d) error %v", num, err)
+		}
+	}
+	if err := m.WritePorts(2, 0); err != ErrPort {
+		t.Errorf("WritePorts(2) error %v", err)
+	}
+}
+
+func TestMAX7300Write(t *testing.T) {
+	m, bus := newTestMAX7300(t)
+
+	p, _ := m.Pin(13)
+	if err := p.SetDirection(gpio.DirOut); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetDirection(gpio.DirOut); err != nil {
+		t.Fatal(err)
+	}
+	// P13 is the second port of the P12-P15 config register
+	if v := bus.regs[max7300RegPortConfig+3]; v != 0xa6 {
+		t.Errorf("port config = %#x", v)
+	}
+
+	for _, v := range []int{1, 1, 0, 0} {
+		if err := p.Write(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// config, port <- 1, port <- 0
+	if n := bus.reset(); n != 3 {
+		t.Errorf("got %d register writes, want 3", n)
+	}
+
+	// cached level, the port register isn't read
+	bus.set(max7300RegPort+13, 1)
+	if v, _ := p.Read(); v != 0 {
+		t.Errorf("Read() = %d, want 0", v)
+	}
+	if v, _ := p.ReadBack(); v != 1 {
+		t.Errorf("ReadBack() = %d, want 1", v)
+	}
+
+	// WritePorts updates the same cache
+	if err := m.WritePorts(12, 0x02); err != nil {
+		t.Fatal(err)
+	}
+	p.Write(1)
+	if len(bus.writes) != 1 || bus.writes[0][0] != max7300RegPorts+12 {
+		t.Errorf("got writes % x", bus.writes)
+	}
+}
+
+func TestMAX7300DryRun(t *testing.T) {
+	m, bus := newTestMAX7300(t)
+
+	gpio.ClearDryRunWrites()
+	gpio.SetDryRun(true)
+	defer func() {
+		gpio.SetDryRun(false)
+		gpio.ClearDryRunWrites()
+	}()
+
+	p, _ := m.Pin(20)
+	if err := p.Write(1); err != nil {
+		t.Fatal(err)
+	}
+	// P28-P31 only
+	if err := m.WritePorts(28, 0x05); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bus.writes) != 0 {
+		t.Errorf("bus written: % x", bus.writes)
+	}
+
+	want := []gpio.DryRunRecord{
+		{Pin: "max7300@0x40:20", Value: 1},
+		{Pin: "max7300@0x40:28", Value: 1},
+		{Pin: "max7300@0x40:29", Value: 0},
+		{Pin: "max7300@0x40:30", Value: 1},
+		{Pin: "max7300@0x40:31", Value: 0},
+	}
+	r := gpio.DryRunWrites()
+	if len(r) != len(want) {
+		t.Fatalf("got %d records, want %d", len(r), len(want))
+	}
+	for i, w := range want {
+		if r[i].Pin != w.Pin || r[i].Value != w.Value {
+			t.Errorf("record %d: got %s <- %d, want %s <- %d", i, r[i].Pin, r[i].Value, w.Pin, w.Value)
+		}
+	}
+}
+
+func TestMAX7300CheckReset(t *testing.T) {
+	m, bus := newTestMAX7300(t)
+
+	p, _ := m.Pin(4)
+	p.SetDirection(gpio.DirOut)
+	p.Write(1)
+
+	if reset, err := m.CheckReset(); err != nil || reset {
+		t.Fatalf("CheckReset() = %v, %v", reset, err)
+	}
+
+	// shutdown with default configuration
+	bus.set(max7300RegConfig, 0)
+	bus.set(max7300RegPortConfig+1, 0xaa)
+	bus.set(max7300RegPort+4, 0)
+	bus.reset()
+
+	reset, err := m.CheckReset()
+	if err != nil || !reset {
+		t.Fatalf("CheckReset() = %v, %v", reset, err)
+	}
+	if bus.regs[max7300RegPort+4] != 1 || bus.regs[max7300RegPortConfig+1] != 0xa9 || bus.regs[max7300RegConfig] != max7300ConfigRun {
+		t.Errorf("port %d config %#x run %#x", bus.regs[max7300RegPort+4], bus.regs[max7300RegPortConfig+1], bus.regs[max7300RegConfig])
+	}
+	if w := bus.writes[0]; w[0] != max7300RegPort+4 {
+		t.Errorf("output not restored first: % x", bus.writes)
+	}
+}
+
+func TestMAX7300Trigger(t *testing.T) {
+	m, bus := newTestMAX7300(t)
+
+	p, _ := m.Pin(12)
+	if _, err := p.Trigger(gpio.EdgeBoth); err != gpio.ErrUnsupported {
+		t.Errorf("P12 trigger error %v", err)
+	}
+
+	p, _ = m.Pin(26)
+	if _, err := p.Trigger(gpio.EdgeBoth); err != ErrInterrupt {
+		t.Fatalf("got %v, want ErrInterrupt", err)
+	}
+
+	irq := newFakeIRQ()
+	m.SetInterrupt(irq)
+	defer irq.tr.Close()
+
+	tr, err := p.Trigger(gpio.EdgeFalling)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bus.regs[max7300RegTransMask] != 0x04 || bus.regs[max7300RegConfig] != max7300ConfigRun|max7300ConfigTrans {
+		t.Errorf("mask %#x config %#x", bus.regs[max7300RegTransMask], bus.regs[max7300RegConfig])
+	}
+
+	// rising edge is filtered, the extra interrupt waits for it to be served
+	const snapshot = max7300RegPorts + max7300FirstTrans
+	bus.set(snapshot, 0x04)
+	irq.fire()
+	irq.fire()
+	bus.set(snapshot, 0x00)
+	irq.fire()
+
+	select {
+	case ev := <-tr.EventCh():
+		if ev.Value != 0 || ev.Seq != 1 {
+			t.Errorf("got %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+
+	if err = tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bus.regs[max7300RegTransMask] != 0 || bus.regs[max7300RegConfig] != max7300ConfigRun {
+		t.Errorf("mask %#x config %#x after close", bus.regs[max7300RegTransMask], bus.regs[max7300RegConfig])
+	}
+}
@@ -0,0 +1,486 @@
+package expander
+
+import (
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"github.com/e-asphyx/gpio/i2c"
+	"sync"
+	"time"
+)
+
+// MCP23008 register addresses, MCP23017 ones (IOCON.BANK=0) are doubled with port B following port A
+const (
+	mcpIODIR = iota
+	mcpIPOL
+	mcpGPINTEN
+	mcpDEFVAL
+	mcpINTCON
+	mcpIOCON
+	mcpGPPU
+	mcpINTF
+	mcpINTCAP
+	mcpGPIO
+	mcpOLAT
+)
+
+const (
+	mcpIOCONMirror = 1 << 6
+
+	// Default base address, A2-A0 are added
+	MCP23017Addr = 0x20
+)
+
+// MCP23008 (8 pins) or MCP23017 (16 pins, port B is 8-15) I2C expander
+type MCP23017 struct {
+	dev   i2c.Device
+	ports int
+
+	mutex    sync.Mutex
+	irq      gpio.PinReadTrigger
+	irqTr    gpio.PinTrigger
+	triggers map[int]*mcpTrigger
+	last     uint16
+
+	// shadow copy of configuration and latch registers, GPIO and interrupt flags aren't cached
+	shadow [mcpOLAT + 1][2]byte
+	valid  [mcpOLAT + 1][2]bool
+}
+
+type MCPPin struct {
+	m   *MCP23017
+	num int
+}
+
+type mcpTrigger struct {
+	pin    *MCPPin
+	edge   gpio.Trigger
+	ch     chan int
+	events chan gpio.Event
+	seq    uint64
+}
+
+func newMCP(bus i2c.Bus, addr uint16, ports int) (*MCP23017, error) {
+	m := &MCP23017{
+		dev:      i2c.Device{Bus: bus, Addr: addr},
+		ports:    ports,
+		triggers: make(map[int]*mcpTrigger),
+	}
+
+	// single interrupt output for both ports
+	var iocon byte
+	if ports == 2 {
+		iocon = mcpIOCONMirror
+	}
+	if err := m.setReg(mcpIOCON, 0, iocon); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func NewMCP23017(bus i2c.Bus, addr uint16) (*MCP23017, error) {
+	return newMCP(bus, addr, 2)
+}
+
+func NewMCP23008(bus i2c.Bus, addr uint16) (*MCP23017, error) {
+	return newMCP(bus, addr, 1)
+}
+
+func (m *MCP23017) reg(r, port int) byte {
+	if m.ports == 1 {
+		return byte(r)
+	}
+	return byte(r*2 + port)
+}
+
+func (m *MCP23017) readReg(r, port int) (byte, error) {
+	return m.dev.ReadByteReg(m.reg(r, port))
+}
+
+func (m *MCP23017) writeReg(r, port int, v byte) error {
+	return m.dev.WriteReg(m.reg(r, port), v)
+}
+
+func cacheable(r int) bool {
+	return r != mcpGPIO && r != mcpINTF && r != mcpINTCAP
+}
+
+// Register value from cache if possible. Must be called with mutex held.
+func (m *MCP23017) cachedReg(r, port int) (byte, error) {
+	if cacheable(r) && m.valid[r][port] {
+		return m.shadow[r][port], nil
+	}
+
+	v, err := m.readReg(r, port)
+	if err != nil {
+		return 0, err
+	}
+	if cacheable(r) {
+		m.shadow[r][port], m.valid[r][port] = v, true
+	}
+	return v, nil
+}
+
+// Write register unless cache says it already holds v. Must be called with mutex held.
+func (m *MCP23017) setReg(r, port int, v byte) error {
+	if m.valid[r][port] && m.shadow[r][port] == v {
+		return nil
+	}
+
+	if err := m.writeReg(r, port, v); err != nil {
+		m.valid[r][port] = false
+		return err
+	}
+	m.shadow[r][port], m.valid[r][port] = v, true
+	return nil
+}
+
+// Drop cached registers, e.g. after the chip was power cycled
+func (m *MCP23017) Invalidate() {
+	m.mutex.Lock()
+	m.valid = [mcpOLAT + 1][2]bool{}
+	m.mutex.Unlock()
+}
+
+// Registers are restored in this order so outputs come up at the right level
+var mcpRestoreOrder = []int{mcpIOCON, mcpOLAT, mcpIPOL, mcpGPPU, mcpDEFVAL, mcpINTCON, mcpIODIR, mcpGPINTEN}
+
+// Compare IOCON and IODIR with the cache and reprogram all cached registers on mismatch,
+// which means the chip has been reset. Returns true if reset was detected.
+func (m *MCP23017) CheckReset() (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	reset := false
+	for _, r := range []int{mcpIOCON, mcpIODIR} {
+		if !m.valid[r][0] {
+			continue
+		}
+		v, err := m.readReg(r, 0)
+		if err != nil {
+			return false, err
+		}
+		if v != m.shadow[r][0] {
+			reset = true
+		}
+	}
+	if !reset {
+		return false, nil
+	}
+
+	for _, r := range mcpRestoreOrder {
+		for port := 0; port < m.ports; port++ {
+			if !m.valid[r][port] {
+				continue
+			}
+			if err := m.writeReg(r, port, m.shadow[r][port]); err != nil {
+				return true, err
+			}
+		}
+	}
+
+	// resync change detection and release INT
+	cur, err := m.readAll(mcpGPIO)
+	if err != nil {
+		return true, err
+	}
+	m.last = cur
+	return true, nil
+}
+
+// Set or clear pin bit in register
+func (m *MCP23017) updateBit(r, num int, set bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.updateBitLocked(r, num, set)
+}
+
+// must be called with mutex held
+func (m *MCP23017) updateBitLocked(r, num int, set bool) error {
+	port, bit := num/8, uint(num%8)
+
+	v, err := m.cachedReg(r, port)
+	if err != nil {
+		return err
+	}
+	if set {
+		v |= 1 << bit
+	} else {
+		v &^= 1 << bit
+	}
+	return m.setReg(r, port, v)
+}
+
+// all ports as one value
+func (m *MCP23017) readAll(r int) (uint16, error) {
+	var buf [2]byte
+	if err := m.dev.ReadReg(m.reg(r, 0), buf[:m.ports]); err != nil {
+		return 0, err
+	}
+	return uint16(buf[0]) | uint16(buf[1])<<8, nil
+}
+
+func (m *MCP23017) Len() int {
+	return m.ports * 8
+}
+
+// Host input wired to INT (INTA). Required for triggers.
+func (m *MCP23017) SetInterrupt(pin gpio.PinReadTrigger) {
+	m.mutex.Lock()
+	m.irq = pin
+	m.mutex.Unlock()
+}
+
+func (m *MCP23017) Pin(num int) (*MCPPin, error) {
+	if num < 0 || num >= m.Len() {
+		return nil, ErrPort
+	}
+	return &MCPPin{m: m, num: num}, nil
+}
+
+// Read all pins at once, bit n is pin n
+func (m *MCP23017) ReadAll() (uint16, error) {
+	return m.readAll(mcpGPIO)
+}
+
+// Write all output latches at once
+func (m *MCP23017) WriteAll(value uint16) error {
+	if gpio.DryRun() {
+		for i := 0; i < m.Len(); i++ {
+			gpio.RecordDryRunWrite(m.pinName(i), int(value>>uint(i))&1)
+		}
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	buf := []byte{byte(value), byte(value >> 8)}
+	same := true
+	for port := 0; port < m.ports; port++ {
+		if !m.valid[mcpOLAT][port] || m.shadow[mcpOLAT][port] != buf[port] {
+			same = false
+		}
+	}
+	if same {
+		return nil
+	}
+
+	err := m.dev.WriteReg(m.reg(mcpOLAT, 0), buf[:m.ports]...)
+	for port := 0; port < m.ports; port++ {
+		m.shadow[mcpOLAT][port], m.valid[mcpOLAT][port] = buf[port], err == nil
+	}
+	return err
+}
+
+func (m *MCP23017) serve(tr gpio.PinTrigger) {
+	for range tr.Ch() {
+		now := time.Now()
+		m.mutex.Lock()
+
+		// reading GPIO releases INT
+		cur, err := m.readAll(mcpGPIO)
+		if err != nil {
+			m.mutex.Unlock()
+			continue
+		}
+
+		changed := cur ^ m.last
+		m.last = cur
+
+		for num, t := range m.triggers {
+			if changed&(1<<uint(num)) == 0 {
+				continue
+			}
+
+			val := int(cur>>uint(num)) & 1
+			if (t.edge == gpio.EdgeRising && val == 0) || (t.edge == gpio.EdgeFalling && val == 1) {
+				continue
+			}
+
+			t.seq++
+			if len(t.ch) != cap(t.ch) {
+				t.ch <- val
+			}
+			if len(t.events) != cap(t.events) {
+				t.events <- gpio.Event{Value: val, Timestamp: now, Seq: t.seq}
+			}
+		}
+
+		m.mutex.Unlock()
+	}
+}
+
+func (p *MCPPin) Num() int {
+	return p.num
+}
+
+// Outputs are read from the latch cache without bus access
+func (p *MCPPin) Read() (int, error) {
+	m := p.m
+	port, bit := p.num/8, uint(p.num%8)
+
+	m.mutex.Lock()
+	dir, err := m.cachedReg(mcpIODIR, port)
+	if err == nil && dir&(1<<bit) == 0 {
+		var v byte
+		v, err = m.cachedReg(mcpOLAT, port)
+		m.mutex.Unlock()
+		return int(v>>bit) & 1, err
+	}
+	m.mutex.Unlock()
+
+	return p.ReadBack()
+}
+
+// Drop the whole chip cache. Implements gpio.Invalidator.
+func (p *MCPPin) Invalidate() {
+	p.m.Invalidate()
+}
+
+// Pin level from GPIO register, bypassing the cache. Implements gpio.ReadBacker.
+func (p *MCPPin) ReadBack() (int, error) {
+	v, err := p.m.readReg(mcpGPIO, p.num/8)
+	if err != nil {
+		return 0, err
+	}
+	return int(v>>uint(p.num%8)) & 1, nil
+}
+
+func (p *MCPPin) Write(value int) error {
+	if gpio.DryRun() {
+		gpio.RecordDryRunWrite(p.String(), value)
+		return nil
+	}
+	return p.m.updateBit(mcpOLAT, p.num, value != 0)
+}
+
+func (m *MCP23017) pinName(num int) string {
+	return fmt.Sprintf("mcp23017@0x%02x:%d", m.dev.Addr, num)
+}
+
+func (p *MCPPin) String() string {
+	return p.m.pinName(p.num)
+}
+
+func (p *MCPPin) Direction() (gpio.Direction, error) {
+	p.m.mutex.Lock()
+	v, err := p.m.cachedReg(mcpIODIR, p.num/8)
+	p.m.mutex.Unlock()
+	if err != nil {
+		return gpio.DirIn, err
+	}
+	if v&(1<<uint(p.num%8)) != 0 {
+		return gpio.DirIn, nil
+	}
+	return gpio.DirOut, nil
+}
+
+func (p *MCPPin) SetDirection(dir gpio.Direction) error {
+	return p.m.updateBit(mcpIODIR, p.num, dir == gpio.DirIn)
+}
+
+// Internal 100k pull-up
+func (p *MCPPin) SetPullUp(pullUp bool) error {
+	return p.m.updateBit(mcpGPPU, p.num, pullUp)
+}
+
+// Invert input polarity in hardware
+func (p *MCPPin) SetInverted(inv bool) error {
+	return p.m.updateBit(mcpIPOL, p.num, inv)
+}
+
+// Interrupt on change, requires the interrupt pin
+func (p *MCPPin) Trigger(edge gpio.Trigger) (gpio.PinTrigger, error) {
+	m := p.m
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.irq == nil {
+		return nil, ErrInterrupt
+	}
+	if _, ok := m.triggers[p.num]; ok {
+		return nil, gpio.ErrTrigger
+	}
+
+	if m.irqTr == nil {
+		// INT is active low
+		tr, err := m.irq.Trigger(gpio.EdgeFalling)
+		if err != nil {
+			return nil, err
+		}
+		m.irqTr = tr
+		go m.serve(tr)
+	}
+
+	// compare against previous value
+	if err := m.updateBitLocked(mcpINTCON, p.num, false); err != nil {
+		return nil, err
+	}
+	if err := m.updateBitLocked(mcpGPINTEN, p.num, true); err != nil {
+		return nil, err
+	}
+
+	cur, err := m.readAll(mcpGPIO)
+	if err != nil {
+		m.updateBitLocked(mcpGPINTEN, p.num, false)
+		return nil, err
+	}
+	m.last = cur
+
+	t := &mcpTrigger{
+		pin:    p,
+		edge:   edge,
+		ch:     make(chan int, 64),
+		events: make(chan gpio.Event, 64),
+	}
+	m.triggers[p.num] = t
+
+	return t, nil
+}
+
+func (p *MCPPin) TriggerWithDebounce(edge gpio.Trigger, interval time.Duration) (gpio.PinTrigger, error) {
+	return gpio.NewDebounceWithInterval(p, edge, interval)
+}
+
+func (t *mcpTrigger) Ch() <-chan int {
+	return t.ch
+}
+
+func (t *mcpTrigger) EventCh() <-chan gpio.Event {
+	return t.events
+}
+
+func (t *mcpTrigger) Trigger() gpio.Trigger {
+	return t.edge
+}
+
+func (t *mcpTrigger) Close() error {
+	m := t.pin.m
+	m.mutex.Lock()
+
+	if m.triggers[t.pin.num] != t {
+		m.mutex.Unlock()
+		return gpio.ErrInvalid
+	}
+
+	delete(m.triggers, t.pin.num)
+	close(t.ch)
+	close(t.events)
+	err := m.updateBitLocked(mcpGPINTEN, t.pin.num, false)
+
+	var irqTr gpio.PinTrigger
+	if len(m.triggers) == 0 {
+		irqTr = m.irqTr
+		m.irqTr = nil
+	}
+	m.mutex.Unlock()
+
+	if irqTr != nil {
+		if e := irqTr.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
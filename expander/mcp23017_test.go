@@ -0,0 +1,310 @@
+package expander
+
+import (
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Register file behind an I2C address: first written byte selects the register,
+// the rest is written with auto increment, reads continue from the same register.
+type regBus struct {
+	mutex  sync.Mutex
+	regs   [256]byte
+	writes [][]byte
+}
+
+func (b *regBus) WriteRead(addr uint16, w, r []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(w) == 0 {
+		return nil
+	}
+	ptr := int(w[0])
+	if len(w) > 1 {
+		b.writes = append(b.writes, append([]byte(nil), w...))
+		for i, v := range w[1:] {
+			b.regs[ptr+i] = v
+		}
+	}
+	for i := range r {
+		r[i] = b.regs[ptr+i]
+	}
+	return nil
+}
+
+func (b *regBus) set(reg, v byte) {
+	b.mutex.Lock()
+	b.regs[reg] = v
+	b.mutex.Unlock()
+}
+
+func (b *regBus) reset() (n int) {
+	b.mutex.Lock()
+	n, b.writes = len(b.writes), nil
+	b.mutex.Unlock()
+	return n
+}
+
+// INT line fed by the test. Sends on ch complete only once the previous interrupt is served.
+type fakeIRQ struct {
+	tr irqTrigger
+}
+
+type irqTrigger struct {
+	ch     chan int
+	events chan gpio.Event
+	once   sync.Once
+}
+
+func newFakeIRQ() *fakeIRQ {
+	return &fakeIRQ{tr: irqTrigger{ch: make(chan int), events: make(chan gpio.Event)}}
+}
+
+func (f *fakeIRQ) Read() (int, error) { return 1, nil }
+
+func (f *fakeIRQ) Trigger(edge gpio.Trigger) (gpio.PinTrigger, error) { return &f.tr, nil }
+
+func (f *fakeIRQ) TriggerWithDebounce(edge gpio.Trigger, interval time.Duration) (gpio.PinTrigger, error) {
+	return &f.tr, nil
+}
+
+func (f *fakeIRQ) fire() { f.tr.ch <- 0 }
+
+func (t *irqTrigger) Ch() <-chan int             { return t.ch }
+func (t *irqTrigger) EventCh() <-chan gpio.Event { return t.events }
+func (t *irqTrigger) Trigger() gpio.Trigger      { return gpio.EdgeFalling }
+
+func (t *irqTrigger) Close() error {
+	t.once.Do(func() {
+		close(t.ch)
+		close(t.events)
+	})
+	return nil
+}
+
+// MCP23017 addresses with IOCON.BANK=0
+const (
+	tIODIRA = 0x00
+	tIOCON  = 0x0a
+	tGPIOA  = 0x12
+	tOLATA  = 0x14
+	tOLATB  = 0x15
+)
+
+func newTestMCP(t *testing.T) (*MCP23017, *regBus) {
+	bus := &regBus{}
+	bus.regs[tIODIRA], bus.regs[tIODIRA+1] = 0xff, 0xff
+	m, err := NewMCP23017(bus, MCP23017Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bus.regs[tIOCON] != mcpIOCONMirror {
+		t.Fatalf("IOCON = %#x", bus.regs[tIOCON])
+	}
+	bus.reset()
+	return m, bus
+}
+
+func TestMCPRegisterCache(t *testing.T) {
+	m, bus := newTestMCP(t)
+
+	p, err := m.Pin(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetDirection(gpio.DirOut); err != nil {
+		t.Fatal(err)
+	}
+	if bus.regs[tIODIRA+1] != 0xfd {
+		t.Errorf("IODIRB = %#x", bus.regs[tIODIRA+1])
+	}
+
+	for _, v := range []int{1, 1, 1, 0, 0} {
+		if err = p.Write(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// IODIR, OLAT <- 1, OLAT <- 0
+	if n := bus.reset(); n != 3 {
+		t.Errorf("got %d register writes, want 3", n)
+	}
+
+	// output level comes from the latch cache even if the pin is held low externally
+	bus.set(tOLATB, 0xff)
+	p.Write(1)
+	bus.set(tGPIOA+1, 0)
+	if v, _ := p.Read(); v != 1 {
+		t.Errorf("Read() = %d, want 1", v)
+	}
+	if v, _ := p.ReadBack(); v != 0 {
+		t.Errorf("ReadBack() = %d, want 0", v)
+	}
+
+	bus.reset()
+	if _, err = m.Pin(16); err != ErrPort {
+		t.Errorf("Pin(16) error %v", err)
+	}
+}
+
+func TestMCPWriteAll(t *testing.T) {
+	m, bus := newTestMCP(t)
+
+	if err := m.WriteAll(0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteAll(0x1234); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bus.writes) != 1 {
+		t.Fatalf("got %d writes, want 1", len(bus.writes))
+	}
+	if w := bus.writes[0]; len(w) != 3 || w[0] != tOLATA || w[1] != 0x34 || w[2] != 0x12 {
+		t.Errorf("got write % x", w)
+	}
+
+	// single pin update goes through the same cache
+	p, _ := m.Pin(2)
+	p.Write(1)
+	if len(bus.writes) != 1 {
+		t.Errorf("redundant write % x", bus.writes[1])
+	}
+}
+
+func TestMCPDryRun(t *testing.T) {
+	m, bus := newTestMCP(t)
+
+	gpio.ClearDryRunWrites()
+	gpio.SetDryRun(true)
+	defer func() {
+		gpio.SetDryRun(false)
+		gpio.ClearDryRunWrites()
+	}()
+
+	p, _ := m.Pin(3)
+	if err := p.Write(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteAll(0x8001); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bus.writes) != 0 {
+		t.Errorf("bus written: % x", bus.writes)
+	}
+
+	r := gpio.DryRunWrites()
+	if len(r) != 17 {
+		t.Fatalf("got %d records, want 17", len(r))
+	}
+	if r[0].Pin != "mcp23017@0x20:3" || r[0].Value != 1 {
+		t.Errorf("got %+v", r[0])
+	}
+	if r[1].Pin != "mcp23017@0x20:0" || r[1].Value != 1 || r[2].Value != 0 || r[16].Value != 1 {
+		t.Errorf("got %+v", r[1:])
+	}
+}
+
+func TestMCPCheckReset(t *testing.T) {
+	m, bus := newTestMCP(t)
+
+	p, _ := m.Pin(0)
+	p.SetDirection(gpio.DirOut)
+	p.Write(1)
+
+	if reset, err := m.CheckReset(); err != nil || reset {
+		t.Fatalf("CheckReset() = %v, %v", reset, err)
+	}
+
+	// power-on defaults
+	bus.set(tIOCON, 0)
+	bus.set(tIODIRA, 0xff)
+	bus.set(tOLATA, 0)
+	bus.reset()
+
+	reset, err := m.CheckReset()
+	if err != nil || !reset {
+		t.Fatalf("CheckReset() = %v, %v", reset, err)
+	}
+	if bus.regs[tIOCON] != mcpIOCONMirror || bus.regs[tIODIRA] != 0xfe || bus.regs[tOLATA] != 1 {
+		t.Errorf("IOCON %#x IODIRA %#x OLATA %#x", bus.regs[tIOCON], bus.regs[tIODIRA], bus.regs[tOLATA])
+	}
+
+	// latch must be restored before the pin is turned into output
+	var olat, iodir int
+	for i, w := range bus.writes {
+		switch w[0] {
+		case tOLATA:
+			olat = i
+		case tIODIRA:
+			iodir = i
+		}
+	}
+	if olat > iodir {
+		t.Errorf("IODIR restored before OLAT: % x", bus.writes)
+	}
+}
+
+func TestMCPTrigger(t *testing.T) {
+	m, bus := newTestMCP(t)
+	p, _ := m.Pin(4)
+
+	if _, err := p.Trigger(gpio.EdgeRising); err != ErrInterrupt {
+		t.Fatalf("got %v, want ErrInterrupt", err)
+	}
+
+	irq := newFakeIRQ()
+	m.SetInterrupt(irq)
+	defer irq.tr.Close()
+
+	tr, err := p.Trigger(gpio.EdgeRising)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Trigger(gpio.EdgeRising); err != gpio.ErrTrigger {
+		t.Errorf("second trigger error %v", err)
+	}
+
+	expect := func(seq uint64) {
+		select {
+		case ev := <-tr.EventCh():
+			if ev.Value != 1 || ev.Seq != seq {
+				t.Errorf("got %+v", ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+	}
+
+	bus.set(tGPIOA, 0x10)
+	irq.fire()
+	expect(1)
+
+	// falling edge is filtered, the extra interrupt waits for it to be served
+	bus.set(tGPIOA, 0x00)
+	irq.fire()
+	irq.fire()
+	select {
+	case ev := <-tr.EventCh():
+		t.Errorf("unexpected %+v", ev)
+	default:
+	}
+
+	bus.set(tGPIOA, 0x10)
+	irq.fire()
+	expect(2)
+
+	if len(tr.Ch()) != 2 {
+		t.Errorf("got %d values", len(tr.Ch()))
+	}
+
+	if err = tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Close(); err != gpio.ErrInvalid {
+		t.Errorf("second Close() error %v", err)
+	}
+}
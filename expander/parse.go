@@ -0,0 +1,95 @@
+package expander
+
+import (
+	"github.com/e-asphyx/gpio"
+	"github.com/e-asphyx/gpio/i2c"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// I2C bus used by specifiers without explicit bus number
+var DefaultBus = 1
+
+type mcpKey struct {
+	bus  int
+	addr uint16
+}
+
+// Expanders opened by specifiers are shared and stay open
+var (
+	specDevices = make(map[mcpKey]*MCP23017)
+	specMutex   sync.Mutex
+)
+
+// PA0-PA7, PB0-PB7 or plain pin number
+func parseMCPPin(s string) (int, error) {
+	s = strings.ToUpper(s)
+	base := 0
+	if len(s) == 3 && s[0] == 'P' && (s[1] == 'A' || s[1] == 'B') {
+		if s[1] == 'B' {
+			base = 8
+		}
+		s = s[2:]
+	}
+
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || (base != 0 && n > 7) {
+		return 0, gpio.ErrSpec
+	}
+	return base + int(n), nil
+}
+
+// Opens "@[bus:]addr:pin" part of "mcp23017@0x20:PB3" or "mcp23017@1:0x20:PB3"
+func openMCPSpec(arg string) (gpio.PinReader, error) {
+	if !strings.HasPrefix(arg, "@") {
+		return nil, gpio.ErrSpec
+	}
+
+	parts := strings.Split(arg[1:], ":")
+	bus := DefaultBus
+	switch len(parts) {
+	case 2:
+	case 3:
+		n, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, gpio.ErrSpec
+		}
+		bus = int(n)
+		parts = parts[1:]
+	default:
+		return nil, gpio.ErrSpec
+	}
+
+	addr, err := strconv.ParseUint(parts[0], 0, 7)
+	if err != nil {
+		return nil, gpio.ErrSpec
+	}
+	num, err := parseMCPPin(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	specMutex.Lock()
+	defer specMutex.Unlock()
+
+	key := mcpKey{bus: bus, addr: uint16(addr)}
+	m, ok := specDevices[key]
+	if !ok {
+		a, err := i2c.Open(bus)
+		if err != nil {
+			return nil, err
+		}
+		if m, err = NewMCP23017(a, key.addr); err != nil {
+			a.Close()
+			return nil, err
+		}
+		specDevices[key] = m
+	}
+
+	return m.Pin(num)
+}
+
+func init() {
+	gpio.RegisterBackend("mcp23017", openMCPSpec)
+}
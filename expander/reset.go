@@ -0,0 +1,57 @@
+package expander
+
+import (
+	"sync"
+	"time"
+)
+
+// Expander able to notice it was reset, e.g. by a supply dip, and reprogram its cached configuration.
+// Implemented by MCP23017, MAX7300 and pcf8574.PCF8574.
+type ResetChecker interface {
+	CheckReset() (bool, error)
+}
+
+// Periodic reset check
+type ResetWatch struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Poll dev every interval. onReset is called after configuration was restored, err is the
+// restore error if any. Check errors other than the reset itself are ignored until the next poll.
+func WatchReset(dev ResetChecker, interval time.Duration, onReset func(err error)) *ResetWatch {
+	w := &ResetWatch{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				reset, err := dev.CheckReset()
+				if reset && onReset != nil {
+					onReset(err)
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+
+	return w
+}
+
+func (w *ResetWatch) Close() error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
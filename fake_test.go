@@ -0,0 +1,95 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// In-memory pin for tests. Level changes made with set are delivered to active triggers.
+type fakePin struct {
+	mutex    sync.Mutex
+	value    int
+	writes   []int
+	triggers []*fakeTrigger
+}
+
+func (p *fakePin) Read() (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.value, nil
+}
+
+func (p *fakePin) Write(value int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.value = value
+	p.writes = append(p.writes, value)
+	return nil
+}
+
+func (p *fakePin) Trigger(edge Trigger) (PinTrigger, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	tr := &fakeTrigger{
+		edge:   edge,
+		ch:     make(chan int, 64),
+		events: make(chan Event, 64),
+	}
+	p.triggers = append(p.triggers, tr)
+	return tr, nil
+}
+
+func (p *fakePin) TriggerWithDebounce(edge Trigger, interval time.Duration) (PinTrigger, error) {
+	return p.Trigger(edge)
+}
+
+func (p *fakePin) set(value int, ts time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if value == p.value {
+		return
+	}
+	p.value = value
+	for _, tr := range p.triggers {
+		tr.send(Event{Value: value, Timestamp: ts})
+	}
+}
+
+// Only EventCh is fed
+type fakeTrigger struct {
+	edge   Trigger
+	mutex  sync.Mutex
+	closed bool
+	ch     chan int
+	events chan Event
+}
+
+func (tr *fakeTrigger) send(ev Event) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if tr.closed {
+		return
+	}
+	if tr.edge == EdgeBoth || (tr.edge == EdgeRising) == (ev.Value != 0) {
+		tr.events <- ev
+	}
+}
+
+func (tr *fakeTrigger) Ch() <-chan int        { return tr.ch }
+func (tr *fakeTrigger) EventCh() <-chan Event { return tr.events }
+func (tr *fakeTrigger) Trigger() Trigger      { return tr.edge }
+
+func (tr *fakeTrigger) Close() error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if !tr.closed {
+		tr.closed = true
+		close(tr.ch)
+		close(tr.events)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package gpio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Fuse blow notification
+type FuseEvent struct {
+	OnTime time.Duration // on time within window at the moment of blowing
+	Time   time.Time
+}
+
+type onInterval struct {
+	start, end time.Time
+}
+
+// Output wrapper limiting cumulative on time within a sliding window
+type Fuse struct {
+	pin     PinWriter
+	window  time.Duration
+	budget  time.Duration
+	mutex   sync.Mutex
+	on      bool
+	onSince time.Time
+	history []onInterval
+	blown   bool
+	timer   *time.Timer
+	ch      chan FuseEvent
+}
+
+var ErrFuseBlown = errors.New("Output fuse blown")
+
+// Output may stay on at most budget within any window
+func NewFuse(pin PinWriter, window, budget time.Duration) *Fuse {
+	return &Fuse{
+		pin:    pin,
+		window: window,
+		budget: budget,
+		ch:     make(chan FuseEvent, 1),
+	}
+}
+
+// must be called with mutex held
+func (f *Fuse) used(now time.Time) time.Duration {
+	from := now.Add(-f.window)
+
+	i := 0
+	for i < len(f.history) && !f.history[i].end.After(from) {
+		i++
+	}
+	f.history = f.history[i:]
+
+	var total time.Duration
+	for _, iv := range f.history {
+		start := iv.start
+		if start.Before(from) {
+			start = from
+		}
+		total += iv.end.Sub(start)
+	}
+
+	if f.on {
+		start := f.onSince
+		if start.Before(from) {
+			start = from
+		}
+		total += now.Sub(start)
+	}
+
+	return total
+}
+
+// must be called with mutex held
+func (f *Fuse) schedule(now time.Time) {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	if !f.on {
+		return
+	}
+
+	remain := f.budget - f.used(now)
+	if remain < 0 {
+		remain = 0
+	}
+	f.timer = time.AfterFunc(remain, f.check)
+}
+
+func (f *Fuse) check() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.on || f.blown {
+		return
+	}
+
+	now := time.Now()
+	used := f.used(now)
+	if used < f.budget {
+		// older on time slid out of window
+		f.schedule(now)
+		return
+	}
+
+	f.pin.Write(0)
+	f.history = append(f.history, onInterval{f.onSince, now})
+	f.on = false
+	f.blown = true
+
+	select {
+	case f.ch <- FuseEvent{OnTime: used, Time: now}:
+	default:
+	}
+}
+
+func (f *Fuse) Write(value int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.blown {
+		if value != 0 {
+			return ErrFuseBlown
+		}
+		return f.pin.Write(0)
+	}
+
+	now := time.Now()
+	if value != 0 && f.used(now) >= f.budget {
+		return ErrFuseBlown
+	}
+
+	if err := f.pin.Write(value); err != nil {
+		return err
+	}
+
+	switch {
+	case value != 0 && !f.on:
+		f.on = true
+		f.onSince = now
+	case value == 0 && f.on:
+		f.history = append(f.history, onInterval{f.onSince, now})
+		f.on = false
+	}
+
+	f.schedule(now)
+	return nil
+}
+
+// Blow notifications
+func (f *Fuse) Ch() <-chan FuseEvent {
+	return f.ch
+}
+
+func (f *Fuse) Blown() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.blown
+}
+
+// Allow writes again. Accumulated on time is kept.
+func (f *Fuse) Reset() {
+	f.mutex.Lock()
+	f.blown = false
+	f.mutex.Unlock()
+}
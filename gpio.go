@@ -1,11 +1,14 @@
 package gpio
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"runtime"
+	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -66,29 +69,78 @@ type PinReadWriter interface {
 // Must be closed before making any subsequent Read and Write calls
 type PinTrigger interface {
 	Ch() <-chan int
+	EventCh() <-chan Event
 	Close() error
 	Trigger() Trigger
 }
 
+// Trigger reporting the error which ended event delivery. The error is sent before event
+// channels are closed.
+type ErrorReporter interface {
+	Err() <-chan error
+}
+
+// Error channel of trigger, nil if it doesn't report errors
+func TriggerErr(tr PinTrigger) <-chan error {
+	if r, ok := tr.(ErrorReporter); ok {
+		return r.Err()
+	}
+	return nil
+}
+
 /* ------------------------------------------------------------------------- */
 
 var (
 	ErrInvalid = errors.New("Invalid pin")
 	ErrTrigger = errors.New("Trigger active")
+	ErrDirIn   = errors.New("Write to pin configured as input")
 )
 
+// Sysfs pin. Open pins are kept in a registry used by ManagedPins, CloseAll and LoadState,
+// which keeps them reachable: a pin is never unexported by the garbage collector and must be
+// released with Close (or CloseAll) when no longer needed.
 type Pin struct {
 	idx     int
+	name    string
 	fd      *os.File
+	fdMutex sync.RWMutex // fd is replaced on reattach
+	lock    *os.File
 	ch      chan int
+	events  chan Event
+	errs    chan error
+	feed    valueFeed
+	history eventRing
+	seq     uint64
 	trigger Trigger
+	cfg     triggerConfig
+	dir     Direction
+	outMode OutputMode
+	autoDir bool
+	dryRun  bool
+
+	priority  int32
+	overflows uint32
+	hook      func(val int)
+
+	gone          int32 // device disappeared
+	reattachMutex sync.Mutex
+	autoReattach  bool
+	triggerLost   bool
+	reattachStop  chan struct{}
+	reattachDone  chan struct{}
 }
 
 type gpioTrigger Pin //huh
 
+var (
+	managed      = make(map[int]*Pin)
+	managedMutex sync.Mutex
+)
+
 type gpioDebounce struct {
-	src PinTrigger
-	ch  chan int
+	eventPipe
+	src   PinTrigger
+	stats bounceStats
 }
 
 func openWriteCloseFile(filename, data string) error {
@@ -101,10 +153,11 @@ func openWriteCloseFile(filename, data string) error {
 	return err
 }
 
-func NewPin(num int) (pin *Pin, err error) {
+// Export pin if needed and open its value file
+func exportPin(ctx context.Context, num int) (*os.File, error) {
 	fileName := fmt.Sprintf("/sys/class/gpio/gpio%d/value", num)
 
-	_, err = os.Stat(fileName)
+	_, err := os.Stat(fileName)
 	if err != nil {
 		err = openWriteCloseFile("/sys/class/gpio/export", strconv.FormatUint(uint64(num), 10))
 		if err != nil {
@@ -112,27 +165,102 @@ func NewPin(num int) (pin *Pin, err error) {
 		}
 	}
 
-	var fd *os.File
 	cnt := 0
 	for {
-		fd, err = os.OpenFile(fileName, os.O_RDWR|os.O_SYNC, 0666)
+		fd, err := os.OpenFile(fileName, os.O_RDWR|os.O_SYNC, 0666)
 		if err == nil {
-			break
-		} else if !os.IsPermission(err) || cnt == 10 {
+			return fd, nil
+		} else if !os.IsPermission(err) {
+			return nil, err
+		} else if _, ok := ctx.Deadline(); !ok && cnt == 10 {
 			return nil, err
 		}
 
 		// Wait for permission change by udev
 		cnt++
-		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Export and open pin. The caller owns it and must Close it, dropping the last reference
+// leaves the pin exported and registered until the process exits.
+func NewPin(num int) (pin *Pin, err error) {
+	return NewPinContext(context.Background(), num)
+}
+
+// Open pin waiting for udev permission change until ctx deadline if there's one.
+// Same as NewPin, the pin must be closed explicitly.
+func NewPinContext(ctx context.Context, num int) (pin *Pin, err error) {
+	lock, err := lockPin(num)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			unlockPin(lock)
+		}
+	}()
+
+	fd, err := exportPin(ctx, num)
+	if err != nil {
+		return nil, err
+	}
+
+	pin = &Pin{idx: num, fd: fd, lock: lock}
+	pin.dir, err = pin.Direction()
+	if err != nil {
+		fd.Close()
+		return nil, err
 	}
 
-	pin = &Pin{idx: num, fd: fd}
-	runtime.SetFinalizer(pin, (*Pin).Close)
+	managedMutex.Lock()
+	managed[num] = pin
+	managedMutex.Unlock()
 
 	return pin, nil
 }
 
+// Currently open pins sorted by number. Pins stay referenced until closed.
+func ManagedPins() []*Pin {
+	managedMutex.Lock()
+	pins := make([]*Pin, 0, len(managed))
+	for _, pin := range managed {
+		pins = append(pins, pin)
+	}
+	managedMutex.Unlock()
+
+	sort.Slice(pins, func(i, j int) bool { return pins[i].idx < pins[j].idx })
+	return pins
+}
+
+// Close all managed pins
+func CloseAll() error {
+	var err error
+	for _, pin := range ManagedPins() {
+		if e := pin.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (pin *Pin) Num() int {
+	return pin.idx
+}
+
+func (pin *Pin) Name() string {
+	return pin.name
+}
+
+// Set informational name
+func (pin *Pin) SetName(name string) {
+	pin.name = name
+}
+
 func (pin *Pin) Read() (int, error) {
 	if pin.ch != nil {
 		return 0, ErrTrigger
@@ -145,7 +273,17 @@ func (pin *Pin) Read() (int, error) {
 	return val, nil
 }
 
+// Current value file
+func (pin *Pin) file() *os.File {
+	pin.fdMutex.RLock()
+	defer pin.fdMutex.RUnlock()
+	return pin.fd
+}
+
 func (pin *Pin) read() (int, error) {
+	pin.fdMutex.RLock()
+	defer pin.fdMutex.RUnlock()
+
 	_, err := pin.fd.Seek(0, os.SEEK_SET)
 	if err != nil {
 		return 0, err
@@ -166,18 +304,45 @@ func (pin *Pin) read() (int, error) {
 	return val, nil
 }
 
+// Switch pin to output on Write instead of returning ErrDirIn
+func (pin *Pin) SetAutoDirection(auto bool) {
+	pin.autoDir = auto
+}
+
 func (pin *Pin) Write(value int) error {
 	if pin.ch != nil {
 		return ErrTrigger
 	}
 
+	if pin.dir == DirIn && !pin.autoDir {
+		return ErrDirIn
+	}
+
+	// dry run must not reconfigure the pin either
+	if pin.dryRun || DryRun() {
+		RecordDryRunWrite(pin.String(), value)
+		return nil
+	}
+
+	if pin.dir == DirIn {
+		if err := pin.SetDirection(DirOut); err != nil {
+			return err
+		}
+	}
+
+	if pin.outMode != PushPull {
+		return pin.writeEmulated(value)
+	}
+
 	var buf [1]byte
 	if value != 0 {
 		buf[0] = '1'
 	} else {
 		buf[0] = '0'
 	}
+	pin.fdMutex.RLock()
 	_, err := pin.fd.Write(buf[:])
+	pin.fdMutex.RUnlock()
 	if err != nil {
 		return err
 	}
@@ -185,22 +350,40 @@ func (pin *Pin) Write(value int) error {
 }
 
 func (pin *Pin) Close() error {
+	// trigger Close stops the reattach loop itself, closing channels left open by it
 	if pin.ch != nil {
 		err := (*gpioTrigger)(pin).Close()
 		if err != nil {
 			return err
 		}
 	}
+	pin.stopReattach()
 
+	pin.fdMutex.Lock()
 	err := pin.fd.Close()
+	pin.fdMutex.Unlock()
 	if err != nil {
 		return err
 	}
 
-	return openWriteCloseFile("/sys/class/gpio/unexport", strconv.FormatUint(uint64(pin.idx), 10))
+	managedMutex.Lock()
+	if managed[pin.idx] == pin {
+		delete(managed, pin.idx)
+	}
+	managedMutex.Unlock()
+
+	// unexport while still holding the lock
+	err = openWriteCloseFile("/sys/class/gpio/unexport", strconv.FormatUint(uint64(pin.idx), 10))
+	unlockPin(pin.lock)
+	pin.lock = nil
+	return err
 }
 
 func (pin *Pin) Direction() (Direction, error) {
+	if pin.outMode != PushPull {
+		return pin.dir, nil
+	}
+
 	fd, err := os.Open(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx))
 	if err != nil {
 		return DirIn, err
@@ -230,13 +413,20 @@ func (pin *Pin) SetDirection(dir Direction) error {
 
 	var dirStr string
 
-	if dir == DirIn {
+	// emulated open drain/source output starts released
+	if dir == DirIn || pin.outMode != PushPull {
 		dirStr = "in"
 	} else {
 		dirStr = "out"
 	}
 
-	return openWriteCloseFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx), dirStr)
+	err := openWriteCloseFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx), dirStr)
+	if err != nil {
+		return err
+	}
+
+	pin.dir = dir
+	return nil
 }
 
 func (pin *Pin) setEdge(edge Trigger) error {
@@ -260,10 +450,25 @@ func (pin *Pin) setEdge(edge Trigger) error {
 }
 
 func (pin *Pin) Trigger(edge Trigger) (trigger PinTrigger, err error) {
+	return pin.triggerWithHook(edge, nil)
+}
+
+// Options take effect only when the trigger is not running yet
+func (pin *Pin) TriggerWithOptions(edge Trigger, opts ...TriggerOption) (PinTrigger, error) {
+	return pin.triggerWithHook(edge, nil, opts...)
+}
+
+// hook is called from the event loop before delivering the event
+func (pin *Pin) triggerWithHook(edge Trigger, hook func(val int), opts ...TriggerOption) (trigger PinTrigger, err error) {
 	if pin.ch != nil {
 		return (*gpioTrigger)(pin), nil
 	}
 
+	srv, err := getEpollServer()
+	if err != nil {
+		return nil, err
+	}
+
 	err = pin.SetDirection(DirIn)
 	if err != nil {
 		return nil, err
@@ -275,9 +480,16 @@ func (pin *Pin) Trigger(edge Trigger) (trigger PinTrigger, err error) {
 	}
 
 	pin.trigger = edge
-	pin.ch = make(chan int, 64)
-
-	err = epollSrv.addPin(pin)
+	pin.hook = hook
+	pin.seq = 0
+	pin.cfg = newTriggerConfig(opts)
+	atomic.StoreUint32(&pin.overflows, 0)
+	pin.ch = make(chan int, pin.cfg.buffer)
+	pin.events = make(chan Event, pin.cfg.buffer)
+	pin.errs = make(chan error, 1)
+	pin.feed.reset()
+
+	err = srv.addPin(pin)
 	if err != nil {
 		return nil, err
 	}
@@ -290,24 +502,56 @@ func (pin *Pin) TriggerWithDebounce(edge Trigger, interval time.Duration) (PinTr
 }
 
 func (pin *gpioTrigger) Close() error {
-	if pin.ch == nil || pin.fd.Fd() == ^uintptr(0) {
+	if pin.ch == nil || (*Pin)(pin).file().Fd() == ^uintptr(0) {
 		return ErrInvalid
 	}
 
-	err := epollSrv.deletePin((*Pin)(pin))
+	if (*Pin)(pin).closeRemovedTrigger() {
+		return nil
+	}
+
+	srv, err := getEpollServer()
 	if err != nil {
 		return err
 	}
 
-	// sync
-	for range pin.ch {
+	// channels of a stopped loop are already closed
+	err = srv.deletePin((*Pin)(pin))
+	if err != nil && err != ErrEventLoop {
+		return err
 	}
+
+	// sync
+	drainTrigger(pin.ch, pin.events)
 	pin.ch = nil
+	pin.events = nil
 
 	return (*Pin)(pin).setEdge(EdgeNone)
 }
 
+// Trigger priority classes
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// Events of higher priority triggers are delivered first when several pins fire at once
+func (pin *gpioTrigger) SetPriority(prio int) {
+	atomic.StoreInt32(&pin.priority, int32(prio))
+}
+
+func (pin *gpioTrigger) Err() <-chan error {
+	return pin.errs
+}
+
+func (pin *gpioTrigger) Overflows() uint32 {
+	return atomic.LoadUint32(&pin.overflows)
+}
+
+// Values are taken from EventCh, read one of them
 func (pin *gpioTrigger) Ch() <-chan int {
+	pin.feed.start(pin.ch, pin.events)
 	return pin.ch
 }
 
@@ -330,23 +574,42 @@ func NewDebounceWithInterval(pin PinReadTrigger, trigger Trigger, interval time.
 		return nil, err
 	}
 
-	out := make(chan int)
+	d := &gpioDebounce{
+		eventPipe: newEventPipe(64),
+		src:       tr,
+	}
 
 	go func() {
 		timer := time.NewTimer(interval)
-		var debounce bool = false
+		var (
+			debounce bool = false
+			accepted time.Time
+			last     time.Time
+			bounce   time.Duration
+		)
 
 		for {
 			select {
 			case <-timer.C:
 				debounce = false
+				if bounce != 0 {
+					d.stats.addBounce(bounce)
+					bounce = 0
+				}
 
-			case val, ok := <-tr.Ch():
+			case ev, ok := <-tr.EventCh():
 				if !ok {
-					close(out)
+					d.close()
 					return
 				}
 
+				val := ev.Value
+				now := ev.Timestamp
+				if !last.IsZero() {
+					d.stats.addInterval(now.Sub(last))
+				}
+				last = now
+
 				if (trigger == EdgeRising && val == 1) ||
 					(trigger == EdgeFalling && val == 0) ||
 					val != value {
@@ -354,20 +617,23 @@ func NewDebounceWithInterval(pin PinReadTrigger, trigger Trigger, interval time.
 					value = val
 
 					if !debounce {
-						out <- val
+						d.stats.addPassed()
+						d.send(ev)
 						debounce = true
+						accepted = now
 						timer.Reset(interval)
+						continue
 					}
 				}
+
+				if debounce {
+					d.stats.addSuppressed()
+					bounce = now.Sub(accepted)
+				}
 			}
 		}
 	}()
 
-	d := &gpioDebounce{
-		src: tr,
-		ch:  out,
-	}
-
 	return d, nil
 }
 
@@ -381,9 +647,7 @@ func (d *gpioDebounce) Close() error {
 		return err
 	}
 
-	for range d.ch {
-	}
-
+	d.drain()
 	return nil
 }
 
@@ -391,6 +655,14 @@ func (d *gpioDebounce) Ch() <-chan int {
 	return d.ch
 }
 
+func (d *gpioDebounce) EventCh() <-chan Event {
+	return d.events
+}
+
+func (d *gpioDebounce) Err() <-chan error {
+	return TriggerErr(d.src)
+}
+
 func (d *gpioDebounce) Trigger() Trigger {
 	return d.src.Trigger()
 }
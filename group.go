@@ -0,0 +1,142 @@
+package gpio
+
+import "errors"
+
+// Controller able to read several lines with single register access
+type BankReader interface {
+	ReadBank() (uint32, error)
+}
+
+type groupBank struct {
+	bank BankWriter
+	mask uint32
+}
+
+// Pins accessed as single value, bit n of the value is pin n. Pins of the same bank
+// are written with single WriteBank call.
+type PinGroup struct {
+	pins  []interface{}
+	bits  []uint // bit in own bank, if any
+	bank  []int  // index into banks or -1
+	banks []groupBank
+}
+
+var ErrGroupSize = errors.New("Too many pins in group")
+
+// Width of uint
+const maxGroupPins = 32 << (^uint(0) >> 63)
+
+// Pins must implement PinReader, PinWriter or both
+func NewPinGroup(pins ...interface{}) (*PinGroup, error) {
+	if len(pins) > maxGroupPins {
+		return nil, ErrGroupSize
+	}
+
+	g := &PinGroup{
+		pins: pins,
+		bits: make([]uint, len(pins)),
+		bank: make([]int, len(pins)),
+	}
+
+	for i, p := range pins {
+		g.bank[i] = -1
+
+		bp, ok := p.(BankPin)
+		if !ok {
+			continue
+		}
+
+		bank, bit := bp.Bank()
+		g.bits[i] = bit
+
+		idx := -1
+		for j := range g.banks {
+			if g.banks[j].bank == bank {
+				idx = j
+				break
+			}
+		}
+		if idx < 0 {
+			g.banks = append(g.banks, groupBank{bank: bank})
+			idx = len(g.banks) - 1
+		}
+
+		g.banks[idx].mask |= 1 << bit
+		g.bank[i] = idx
+	}
+
+	return g, nil
+}
+
+func (g *PinGroup) Len() int {
+	return len(g.pins)
+}
+
+// Bank controllers with separate set and clear registers (bcm2708) raise bits one cycle before
+// lowering others
+func (g *PinGroup) Write(value uint) error {
+	values := make([]uint32, len(g.banks))
+
+	for i, p := range g.pins {
+		bit := int(value>>uint(i)) & 1
+
+		if b := g.bank[i]; b >= 0 {
+			values[b] |= uint32(bit) << g.bits[i]
+			continue
+		}
+
+		w, ok := p.(PinWriter)
+		if !ok {
+			return ErrUnsupported
+		}
+		if err := w.Write(bit); err != nil {
+			return err
+		}
+	}
+
+	for i, b := range g.banks {
+		if err := b.bank.WriteBank(b.mask, values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *PinGroup) Read() (uint, error) {
+	levels := make([]uint32, len(g.banks))
+	sampled := make([]bool, len(g.banks))
+
+	for i, b := range g.banks {
+		if r, ok := b.bank.(BankReader); ok {
+			v, err := r.ReadBank()
+			if err != nil {
+				return 0, err
+			}
+			levels[i] = v
+			sampled[i] = true
+		}
+	}
+
+	var value uint
+	for i, p := range g.pins {
+		if b := g.bank[i]; b >= 0 && sampled[b] {
+			value |= uint((levels[b]>>g.bits[i])&1) << uint(i)
+			continue
+		}
+
+		r, ok := p.(PinReader)
+		if !ok {
+			return 0, ErrUnsupported
+		}
+		v, err := r.Read()
+		if err != nil {
+			return 0, err
+		}
+		if v != 0 {
+			value |= 1 << uint(i)
+		}
+	}
+
+	return value, nil
+}
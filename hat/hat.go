@@ -0,0 +1,337 @@
+package hat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"github.com/e-asphyx/gpio/bcm2708"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	signature     = 0x69502d52 // "R-Pi"
+	headerLen     = 12
+	atomHeaderLen = 8
+
+	atomVendorInfo = 0x0001
+	atomGPIOMap    = 0x0002
+	atomDTBlob     = 0x0003
+	atomCustom     = 0x0004
+
+	gpioMapLen = 30
+	numPins    = 28
+
+	eepromAddr   = 0x50
+	eepromSize   = 4096
+	i2cSlaveCtl  = 0x0703
+	deviceTree   = "/proc/device-tree/hat"
+	sysfsEEPROM  = "/sys/bus/i2c/devices/0-0050/eeprom"
+	i2cEEPROMBus = "/dev/i2c-0"
+)
+
+// GPIO function as encoded in GPIO map atom (same as BCM283x FSEL values)
+type Function int
+
+const (
+	FuncInput  Function = 0
+	FuncOutput Function = 1
+	FuncAlt0   Function = 4
+	FuncAlt1   Function = 5
+	FuncAlt2   Function = 6
+	FuncAlt3   Function = 7
+	FuncAlt4   Function = 3
+	FuncAlt5   Function = 2
+)
+
+// Pull setting requested by HAT
+type Pull int
+
+const (
+	PullDefault Pull = iota
+	PullUp
+	PullDown
+	PullNone
+)
+
+// Configuration of a single pin used by HAT
+type PinConfig struct {
+	Name     string
+	GPIO     int
+	Function Function
+	Pull     Pull
+}
+
+// Decoded HAT EEPROM contents
+type HAT struct {
+	UUID       [16]byte
+	ProductID  uint16
+	ProductVer uint16
+	Vendor     string
+	Product    string
+
+	Drive      int
+	Slew       int
+	Hysteresis int
+	BackPower  int
+	Pins       []PinConfig
+
+	DeviceTree []byte
+	Custom     [][]byte
+}
+
+var (
+	ErrSignature = errors.New("Invalid HAT EEPROM signature")
+	ErrFormat    = errors.New("Malformed HAT EEPROM")
+	ErrCRC       = errors.New("HAT EEPROM atom CRC mismatch")
+	ErrNoHAT     = errors.New("HAT not found")
+)
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Parse raw EEPROM image
+func Parse(data []byte) (*HAT, error) {
+	if len(data) < headerLen {
+		return nil, ErrFormat
+	}
+	if binary.LittleEndian.Uint32(data) != signature {
+		return nil, ErrSignature
+	}
+
+	numAtoms := int(binary.LittleEndian.Uint16(data[6:]))
+	eepLen := int(binary.LittleEndian.Uint32(data[8:]))
+	if eepLen < len(data) {
+		data = data[:eepLen]
+	}
+
+	h := new(HAT)
+	pos := headerLen
+	for i := 0; i < numAtoms; i++ {
+		if pos+atomHeaderLen > len(data) {
+			return nil, ErrFormat
+		}
+
+		typ := binary.LittleEndian.Uint16(data[pos:])
+		dlen := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if dlen < 2 || pos+atomHeaderLen+dlen > len(data) {
+			return nil, ErrFormat
+		}
+
+		atom := data[pos : pos+atomHeaderLen+dlen-2]
+		crc := binary.LittleEndian.Uint16(data[pos+atomHeaderLen+dlen-2:])
+		if crc16(atom) != crc {
+			return nil, ErrCRC
+		}
+
+		payload := atom[atomHeaderLen:]
+		pos += atomHeaderLen + dlen
+
+		var err error
+		switch typ {
+		case atomVendorInfo:
+			err = h.parseVendorInfo(payload)
+
+		case atomGPIOMap:
+			err = h.parseGPIOMap(payload)
+
+		case atomDTBlob:
+			h.DeviceTree = append([]byte(nil), payload...)
+
+		case atomCustom:
+			h.Custom = append(h.Custom, append([]byte(nil), payload...))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+func (h *HAT) parseVendorInfo(data []byte) error {
+	if len(data) < 22 {
+		return ErrFormat
+	}
+
+	copy(h.UUID[:], data)
+	h.ProductID = binary.LittleEndian.Uint16(data[16:])
+	h.ProductVer = binary.LittleEndian.Uint16(data[18:])
+
+	vsLen := int(data[20])
+	psLen := int(data[21])
+	if 22+vsLen+psLen > len(data) {
+		return ErrFormat
+	}
+
+	h.Vendor = string(data[22 : 22+vsLen])
+	h.Product = string(data[22+vsLen : 22+vsLen+psLen])
+
+	return nil
+}
+
+func (h *HAT) parseGPIOMap(data []byte) error {
+	if len(data) < gpioMapLen {
+		return ErrFormat
+	}
+
+	h.Drive = int(data[0] & 0xf)
+	h.Slew = int((data[0] >> 4) & 3)
+	h.Hysteresis = int((data[0] >> 6) & 3)
+	h.BackPower = int(data[1] & 3)
+
+	h.Pins = nil
+	for i := 0; i < numPins; i++ {
+		v := data[2+i]
+		if v&0x80 == 0 {
+			continue
+		}
+
+		h.Pins = append(h.Pins, PinConfig{
+			Name:     fmt.Sprintf("GPIO%d", i),
+			GPIO:     i,
+			Function: Function(v & 7),
+			Pull:     Pull((v >> 5) & 3),
+		})
+	}
+
+	return nil
+}
+
+func readI2C() ([]byte, error) {
+	fd, err := os.OpenFile(i2cEEPROMBus, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	err = unix.IoctlSetInt(int(fd.Fd()), i2cSlaveCtl, eepromAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// 16 bit address
+	_, err = fd.Write([]byte{0, 0})
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, eepromSize)
+	n, err := fd.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// Read raw EEPROM image using at24 driver if bound or i2c-0 bus directly
+func ReadEEPROM() ([]byte, error) {
+	data, err := ioutil.ReadFile(sysfsEEPROM)
+	if err == nil {
+		return data, nil
+	}
+	return readI2C()
+}
+
+func readDTString(name string) string {
+	buf, err := ioutil.ReadFile(deviceTree + "/" + name)
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimRight(buf, "\x00"))
+}
+
+// Detect and decode attached HAT. Vendor information is taken from device tree
+// if EEPROM can't be read, a corrupt EEPROM image is reported as an error.
+func Open() (*HAT, error) {
+	if _, err := os.Stat(deviceTree); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoHAT
+		}
+		return nil, err
+	}
+
+	data, err := ReadEEPROM()
+	if err == nil {
+		return Parse(data)
+	}
+
+	h := &HAT{
+		Vendor:  readDTString("vendor"),
+		Product: readDTString("product"),
+	}
+
+	uuid, err := hex.DecodeString(strings.Replace(readDTString("uuid"), "-", "", -1))
+	if err == nil {
+		copy(h.UUID[:], uuid)
+	}
+	fmt.Sscanf(readDTString("product_id"), "0x%x", &h.ProductID)
+	fmt.Sscanf(readDTString("product_ver"), "0x%x", &h.ProductVer)
+
+	return h, nil
+}
+
+// Pin config by name
+func (h *HAT) Pin(name string) (PinConfig, bool) {
+	for _, p := range h.Pins {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PinConfig{}, false
+}
+
+// Apply GPIO map to the hardware. Returns configured input and output pins by name.
+// Alternate functions are left to the firmware.
+func (h *HAT) Configure() (map[string]bcm2708.Pin, error) {
+	if err := bcm2708.Open(); err != nil {
+		return nil, err
+	}
+
+	pins := make(map[string]bcm2708.Pin)
+
+	for _, p := range h.Pins {
+		pin := bcm2708.Pin(p.GPIO)
+
+		switch p.Pull {
+		case PullUp:
+			pin.SetPullUpDown(gpio.PullUp)
+		case PullDown:
+			pin.SetPullUpDown(gpio.PullDown)
+		case PullNone:
+			pin.SetPullUpDown(gpio.PullOff)
+		}
+
+		switch p.Function {
+		case FuncInput:
+			pin.SetDirection(gpio.DirIn)
+		case FuncOutput:
+			pin.SetDirection(gpio.DirOut)
+		default:
+			continue
+		}
+
+		pins[p.Name] = pin
+	}
+
+	return pins, nil
+}
@@ -0,0 +1,151 @@
+package hat
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		data string
+		crc  uint16
+	}{
+		{"", 0x0000},
+		{"123456789", 0xbb3d},
+		{"\x00", 0x0000},
+		{"\xff", 0x4040},
+	}
+
+	for _, tt := range tests {
+		if got := crc16([]byte(tt.data)); got != tt.crc {
+			t.Errorf("%q: got %#04x, want %#04x", tt.data, got, tt.crc)
+		}
+	}
+}
+
+type atom struct {
+	typ  uint16
+	data []byte
+}
+
+func image(atoms ...atom) []byte {
+	buf := make([]byte, headerLen)
+	binary.LittleEndian.PutUint32(buf, signature)
+	buf[4] = 1
+	binary.LittleEndian.PutUint16(buf[6:], uint16(len(atoms)))
+
+	for i, a := range atoms {
+		hdr := make([]byte, atomHeaderLen)
+		binary.LittleEndian.PutUint16(hdr, a.typ)
+		binary.LittleEndian.PutUint16(hdr[2:], uint16(i))
+		binary.LittleEndian.PutUint32(hdr[4:], uint32(len(a.data)+2))
+
+		rec := append(hdr, a.data...)
+		rec = append(rec, 0, 0)
+		binary.LittleEndian.PutUint16(rec[len(rec)-2:], crc16(rec[:len(rec)-2]))
+		buf = append(buf, rec...)
+	}
+
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(buf)))
+	return buf
+}
+
+func vendorInfo(vendor, product string) []byte {
+	b := make([]byte, 22)
+	for i := 0; i < 16; i++ {
+		b[i] = byte(i)
+	}
+	binary.LittleEndian.PutUint16(b[16:], 0x1234)
+	binary.LittleEndian.PutUint16(b[18:], 0x0002)
+	b[20] = byte(len(vendor))
+	b[21] = byte(len(product))
+	return append(append(b, vendor...), product...)
+}
+
+func gpioMap(pins map[int]byte) []byte {
+	b := make([]byte, gpioMapLen)
+	b[0] = 0x5 | 1<<4 | 2<<6
+	b[1] = 1
+	for p, v := range pins {
+		b[2+p] = 0x80 | v
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	data := image(
+		atom{atomVendorInfo, vendorInfo("Acme", "Blinker")},
+		atom{atomGPIOMap, gpioMap(map[int]byte{4: byte(FuncOutput), 17: byte(FuncInput) | byte(PullUp)<<5})},
+		atom{atomDTBlob, []byte{0xd0, 0x0d, 0xfe, 0xed}},
+		atom{atomCustom, []byte("hello")},
+	)
+	// unused tail of the EEPROM must be ignored
+	data = append(data, 0xff, 0xff, 0xff, 0xff)
+
+	h, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Vendor != "Acme" || h.Product != "Blinker" || h.ProductID != 0x1234 || h.ProductVer != 2 || h.UUID[15] != 15 {
+		t.Errorf("vendor info: got %+v", h)
+	}
+	if h.Drive != 5 || h.Slew != 1 || h.Hysteresis != 2 || h.BackPower != 1 {
+		t.Errorf("bank settings: got drive %d slew %d hysteresis %d back power %d", h.Drive, h.Slew, h.Hysteresis, h.BackPower)
+	}
+
+	want := []PinConfig{
+		{Name: "GPIO4", GPIO: 4, Function: FuncOutput, Pull: PullDefault},
+		{Name: "GPIO17", GPIO: 17, Function: FuncInput, Pull: PullUp},
+	}
+	if len(h.Pins) != len(want) {
+		t.Fatalf("got pins %+v, want %+v", h.Pins, want)
+	}
+	for i := range want {
+		if h.Pins[i] != want[i] {
+			t.Errorf("pin %d: got %+v, want %+v", i, h.Pins[i], want[i])
+		}
+	}
+
+	if string(h.DeviceTree) != "\xd0\x0d\xfe\xed" {
+		t.Errorf("got device tree %x", h.DeviceTree)
+	}
+	if len(h.Custom) != 1 || string(h.Custom[0]) != "hello" {
+		t.Errorf("got custom atoms %q", h.Custom)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	good := image(atom{atomVendorInfo, vendorInfo("Acme", "Blinker")})
+
+	badCRC := append([]byte(nil), good...)
+	badCRC[headerLen+atomHeaderLen+22] ^= 1
+
+	badSig := append([]byte(nil), good...)
+	badSig[0] = 'X'
+
+	truncated := append([]byte(nil), good...)
+	binary.LittleEndian.PutUint16(truncated[6:], 2)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrFormat},
+		{"short header", good[:headerLen-1], ErrFormat},
+		{"signature", badSig, ErrSignature},
+		{"crc", badCRC, ErrCRC},
+		{"missing atom", truncated, ErrFormat},
+		{"cut atom", good[:len(good)-1], ErrFormat},
+		{"short vendor info", image(atom{atomVendorInfo, make([]byte, 21)}), ErrFormat},
+		{"vendor string overflow", image(atom{atomVendorInfo, vendorInfo("Acme", "Blinker")[:25]}), ErrFormat},
+		{"short gpio map", image(atom{atomGPIOMap, make([]byte, gpioMapLen-1)}), ErrFormat},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.data); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package i2c
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+const (
+	i2cSlave      = 0x0703
+	i2cSlaveForce = 0x0706
+	i2cTenBit     = 0x0704
+	i2cRdwr       = 0x0707
+	i2cSmbus      = 0x0720
+
+	i2cMRd  = 0x0001
+	i2cMTen = 0x0010
+
+	smbusWrite = 0
+	smbusRead  = 1
+
+	smbusQuick        = 0
+	smbusByte         = 1
+	smbusByteData     = 2
+	smbusWordData     = 3
+	smbusBlockData    = 5
+	smbusI2CBlockData = 8
+
+	smbusBlockMax = 32
+)
+
+// struct i2c_msg
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   unsafe.Pointer
+}
+
+// struct i2c_rdwr_ioctl_data
+type i2cRdwrData struct {
+	msgs  unsafe.Pointer
+	nmsgs uint32
+}
+
+// struct i2c_smbus_ioctl_data
+type i2cSmbusData struct {
+	readWrite uint8
+	command   uint8
+	size      uint32
+	data      unsafe.Pointer
+}
+
+// Kernel I2C adapter (/dev/i2c-N)
+type Adapter struct {
+	fd    *os.File
+	mutex sync.Mutex
+	addr  int
+	force bool
+}
+
+func Open(bus int) (*Adapter, error) {
+	fd, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Adapter{fd: fd, addr: -1}
+	runtime.SetFinalizer(a, (*Adapter).Close)
+
+	return a, nil
+}
+
+func (a *Adapter) ioctl(req, arg uintptr) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, a.fd.Fd(), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (a *Adapter) ioctlPtr(req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, a.fd.Fd(), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Talk to devices claimed by kernel drivers. Off by default, such addresses return ErrBusy.
+func (a *Adapter) SetForce(force bool) {
+	a.mutex.Lock()
+	a.force = force
+	a.addr = -1
+	a.mutex.Unlock()
+}
+
+func (a *Adapter) WriteRead(addr uint16, w, r []byte) error {
+	if len(w) == 0 && len(r) == 0 {
+		return a.WriteQuick(addr, false)
+	}
+
+	var flags uint16
+	if addr > MaxAddr7 {
+		flags = i2cMTen
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !a.force {
+		// I2C_RDWR doesn't check ownership itself
+		if err := a.setAddr(addr); err != nil {
+			return err
+		}
+	}
+
+	// built right before the syscall, the kernel reads them through the pointers
+	var msgs [2]i2cMsg
+	n := 0
+	if len(w) != 0 {
+		msgs[n] = i2cMsg{addr: addr, flags: flags, len: uint16(len(w)), buf: unsafe.Pointer(&w[0])}
+		n++
+	}
+	if len(r) != 0 {
+		msgs[n] = i2cMsg{addr: addr, flags: flags | i2cMRd, len: uint16(len(r)), buf: unsafe.Pointer(&r[0])}
+		n++
+	}
+	data := i2cRdwrData{msgs: unsafe.Pointer(&msgs[0]), nmsgs: uint32(n)}
+
+	err := a.ioctlPtr(i2cRdwr, unsafe.Pointer(&data))
+	runtime.KeepAlive(w)
+	runtime.KeepAlive(r)
+	runtime.KeepAlive(&msgs)
+
+	if err == unix.EREMOTEIO || err == unix.ENXIO {
+		return ErrNack
+	}
+	return err
+}
+
+// must be called with mutex held
+func (a *Adapter) setAddr(addr uint16) error {
+	if int(addr) == a.addr {
+		return nil
+	}
+
+	var ten uintptr
+	if addr > MaxAddr7 {
+		ten = 1
+	}
+	if err := a.ioctl(i2cTenBit, ten); err != nil {
+		return err
+	}
+
+	req := uintptr(i2cSlave)
+	if a.force {
+		req = i2cSlaveForce
+	}
+	if err := a.ioctl(req, uintptr(addr)); err != nil {
+		if err == unix.EBUSY {
+			return ErrBusy
+		}
+		return err
+	}
+
+	a.addr = int(addr)
+	return nil
+}
+
+func (a *Adapter) smbus(addr uint16, rw uint8, cmd uint8, size uint32, data []byte) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.setAddr(addr); err != nil {
+		return err
+	}
+
+	args := i2cSmbusData{
+		readWrite: rw,
+		command:   cmd,
+		size:      size,
+	}
+	if data != nil {
+		args.data = unsafe.Pointer(&data[0])
+	}
+
+	err := a.ioctlPtr(i2cSmbus, unsafe.Pointer(&args))
+	runtime.KeepAlive(data)
+
+	if err == unix.EREMOTEIO || err == unix.ENXIO {
+		return ErrNack
+	}
+	return err
+}
+
+// SMBus quick command. Useful for probing.
+func (a *Adapter) WriteQuick(addr uint16, read bool) error {
+	var rw uint8 = smbusWrite
+	if read {
+		rw = smbusRead
+	}
+	return a.smbus(addr, rw, 0, smbusQuick, nil)
+}
+
+func (a *Adapter) ReceiveByte(addr uint16) (byte, error) {
+	var buf [smbusBlockMax + 2]byte
+	err := a.smbus(addr, smbusRead, 0, smbusByte, buf[:])
+	return buf[0], err
+}
+
+func (a *Adapter) SendByte(addr uint16, value byte) error {
+	return a.smbus(addr, smbusWrite, value, smbusByte, nil)
+}
+
+func (a *Adapter) ReadByteData(addr uint16, cmd byte) (byte, error) {
+	var buf [smbusBlockMax + 2]byte
+	err := a.smbus(addr, smbusRead, cmd, smbusByteData, buf[:])
+	return buf[0], err
+}
+
+func (a *Adapter) WriteByteData(addr uint16, cmd, value byte) error {
+	var buf [smbusBlockMax + 2]byte
+	buf[0] = value
+	return a.smbus(addr, smbusWrite, cmd, smbusByteData, buf[:])
+}
+
+func (a *Adapter) ReadWordData(addr uint16, cmd byte) (uint16, error) {
+	var buf [smbusBlockMax + 2]byte
+	err := a.smbus(addr, smbusRead, cmd, smbusWordData, buf[:])
+	return uint16(buf[0]) | uint16(buf[1])<<8, err
+}
+
+func (a *Adapter) WriteWordData(addr uint16, cmd byte, value uint16) error {
+	var buf [smbusBlockMax + 2]byte
+	buf[0] = byte(value)
+	buf[1] = byte(value >> 8)
+	return a.smbus(addr, smbusWrite, cmd, smbusWordData, buf[:])
+}
+
+func (a *Adapter) ReadBlockData(addr uint16, cmd byte) ([]byte, error) {
+	var buf [smbusBlockMax + 2]byte
+	err := a.smbus(addr, smbusRead, cmd, smbusBlockData, buf[:])
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(buf[0])
+	if n > smbusBlockMax {
+		n = smbusBlockMax
+	}
+	return append([]byte(nil), buf[1:1+n]...), nil
+}
+
+func (a *Adapter) WriteBlockData(addr uint16, cmd byte, data []byte) error {
+	if len(data) > smbusBlockMax {
+		data = data[:smbusBlockMax]
+	}
+
+	var buf [smbusBlockMax + 2]byte
+	buf[0] = byte(len(data))
+	copy(buf[1:], data)
+	return a.smbus(addr, smbusWrite, cmd, smbusBlockData, buf[:])
+}
+
+func (a *Adapter) ReadI2CBlockData(addr uint16, cmd byte, data []byte) error {
+	if len(data) > smbusBlockMax {
+		data = data[:smbusBlockMax]
+	}
+
+	var buf [smbusBlockMax + 2]byte
+	buf[0] = byte(len(data))
+	err := a.smbus(addr, smbusRead, cmd, smbusI2CBlockData, buf[:])
+	if err != nil {
+		return err
+	}
+
+	copy(data, buf[1:])
+	return nil
+}
+
+func (a *Adapter) Close() error {
+	return a.fd.Close()
+}
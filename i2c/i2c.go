@@ -0,0 +1,68 @@
+package i2c
+
+import (
+	"errors"
+	"os"
+)
+
+// Addresses above this value use 10 bit addressing
+const MaxAddr7 = 0x7f
+
+var (
+	ErrNack    = errors.New("No acknowledge")
+	ErrAddress = errors.New("Invalid address")
+	ErrBusy    = errors.New("Address in use by kernel driver")
+)
+
+// I2C bus master
+type Bus interface {
+	// Write w and then read into r using repeated start condition. Either can be empty.
+	WriteRead(addr uint16, w, r []byte) error
+}
+
+// Slave device on a bus
+type Device struct {
+	Bus  Bus
+	Addr uint16
+}
+
+func (d *Device) Write(data []byte) error {
+	return d.Bus.WriteRead(d.Addr, data, nil)
+}
+
+func (d *Device) Read(data []byte) error {
+	return d.Bus.WriteRead(d.Addr, nil, data)
+}
+
+// Read consecutive registers starting from reg
+func (d *Device) ReadReg(reg byte, data []byte) error {
+	return d.Bus.WriteRead(d.Addr, []byte{reg}, data)
+}
+
+// Write consecutive registers starting from reg
+func (d *Device) WriteReg(reg byte, data ...byte) error {
+	buf := make([]byte, len(data)+1)
+	buf[0] = reg
+	copy(buf[1:], data)
+	return d.Bus.WriteRead(d.Addr, buf, nil)
+}
+
+func (d *Device) ReadByteReg(reg byte) (byte, error) {
+	var buf [1]byte
+	err := d.ReadReg(reg, buf[:])
+	return buf[0], err
+}
+
+// Opens kernel adapter or calls fallback (typically bit-bang master) if it isn't available
+func OpenWithFallback(bus int, fallback func() (Bus, error)) (Bus, error) {
+	a, err := Open(bus)
+	if err == nil {
+		return a, nil
+	}
+
+	if (os.IsNotExist(err) || os.IsPermission(err)) && fallback != nil {
+		return fallback()
+	}
+
+	return nil, err
+}
@@ -0,0 +1,90 @@
+package i2c
+
+const (
+	scanFirst = 0x03
+	scanLast  = 0x77
+)
+
+// Responding device on a bus
+type ScanResult struct {
+	Addr    uint16
+	Guesses []string // chips commonly found at the address
+	Busy    bool     // claimed by kernel driver, not probed (UU in i2cdetect)
+}
+
+var knownChips = map[uint16][]string{
+	0x1d: {"ADXL345"},
+	0x1e: {"HMC5883L"},
+	0x23: {"BH1750"},
+	0x29: {"VL53L0X", "TSL2561"},
+	0x39: {"TSL2561", "APDS-9960"},
+	0x3c: {"SSD1306"},
+	0x3d: {"SSD1306"},
+	0x40: {"PCA9685", "INA219", "Si7021", "HTU21D"},
+	0x48: {"ADS1115", "PCF8591", "TMP102"},
+	0x49: {"ADS1115", "TSL2561", "TMP102"},
+	0x4a: {"ADS1115", "TMP102"},
+	0x4b: {"ADS1115", "TMP102"},
+	0x53: {"ADXL345"},
+	0x5c: {"BH1750", "AM2320"},
+	0x60: {"MCP4725", "Si1145"},
+	0x62: {"MCP4725"},
+	0x68: {"DS1307", "DS3231", "MPU-6050"},
+	0x69: {"MPU-6050"},
+	0x76: {"BME280", "BMP280", "MS5611"},
+	0x77: {"BME280", "BMP280", "BMP180", "MS5611"},
+}
+
+func init() {
+	for a := uint16(0x20); a <= 0x27; a++ {
+		knownChips[a] = append(knownChips[a], "MCP23017", "MCP23008", "PCF8574", "HD44780 backpack")
+	}
+	for a := uint16(0x38); a <= 0x3f; a++ {
+		knownChips[a] = append(knownChips[a], "PCF8574A")
+	}
+	for a := uint16(0x40); a <= 0x4f; a++ {
+		knownChips[a] = append(knownChips[a], "MAX7300")
+	}
+	for a := uint16(0x50); a <= 0x57; a++ {
+		knownChips[a] = append(knownChips[a], "24Cxx EEPROM")
+	}
+}
+
+// Bus able to send SMBus quick command, like Adapter
+type quickWriter interface {
+	WriteQuick(addr uint16, read bool) error
+}
+
+func probe(bus Bus, addr uint16) error {
+	// same as i2cdetect: quick write may corrupt EEPROMs and confuse some sensors, read those instead
+	if q, ok := bus.(quickWriter); ok && !(addr >= 0x30 && addr <= 0x37 || addr >= 0x50 && addr <= 0x5f) {
+		if err := q.WriteQuick(addr, false); err == nil || err == ErrNack {
+			return err
+		}
+		// adapter may not support quick command
+	}
+
+	var buf [1]byte
+	return bus.WriteRead(addr, nil, buf[:])
+}
+
+// Probe addresses 0x03-0x77. Adapter without SetForce reports addresses bound to kernel
+// drivers as busy before touching the bus.
+func Scan(bus Bus) ([]ScanResult, error) {
+	var res []ScanResult
+	for addr := uint16(scanFirst); addr <= scanLast; addr++ {
+		err := probe(bus, addr)
+		if err == ErrNack {
+			continue
+		}
+		if err != nil && err != ErrBusy {
+			return res, err
+		}
+		res = append(res, ScanResult{Addr: addr, Guesses: knownChips[addr], Busy: err == ErrBusy})
+	}
+	return res, nil
+}
+
+func (a *Adapter) Scan() ([]ScanResult, error) {
+	return Scan(a)
+}
@@ -0,0 +1,53 @@
+package i2c
+
+import (
+	"fmt"
+	"time"
+)
+
+// Single WriteRead transaction seen by Traced
+type TraceRecord struct {
+	Addr     uint16
+	Write    []byte
+	Read     []byte // data received, nil if nothing was read
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+func (r TraceRecord) String() string {
+	s := fmt.Sprintf("i2c 0x%02x w[% x] r[% x] %v", r.Addr, r.Write, r.Read, r.Duration)
+	if r.Err != nil {
+		s += ": " + r.Err.Error()
+	}
+	return s
+}
+
+// Bus wrapper passing every transaction to a sink, e.g. func(r TraceRecord) { log.Println(r) }
+type TracedBus struct {
+	Bus  Bus
+	Sink func(r TraceRecord)
+}
+
+func Traced(bus Bus, sink func(r TraceRecord)) *TracedBus {
+	return &TracedBus{Bus: bus, Sink: sink}
+}
+
+func (t *TracedBus) WriteRead(addr uint16, w, r []byte) error {
+	start := time.Now()
+	err := t.Bus.WriteRead(addr, w, r)
+
+	rec := TraceRecord{
+		Addr:     addr,
+		Write:    append([]byte(nil), w...),
+		Start:    start,
+		Duration: time.Now().Sub(start),
+		Err:      err,
+	}
+	if len(r) != 0 {
+		rec.Read = append([]byte(nil), r...)
+	}
+
+	t.Sink(rec)
+	return err
+}
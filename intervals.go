@@ -0,0 +1,128 @@
+package gpio
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// What IntervalStats measures
+type IntervalMode int
+
+const (
+	IntervalEdges  IntervalMode = iota // between any consecutive edges
+	IntervalPeriod                     // between rising edges
+	IntervalHigh                       // rising to falling, i.e. pulse width
+	IntervalLow                        // falling to rising
+)
+
+// Summary over the window. Jitter is the standard deviation.
+type IntervalSummary struct {
+	Count  int
+	Min    time.Duration
+	Mean   time.Duration
+	Max    time.Duration
+	Jitter time.Duration
+}
+
+// Rolling statistics of edge intervals, e.g. for RC receiver pulses or fan tachometers
+type IntervalStats struct {
+	mode IntervalMode
+
+	mutex sync.Mutex
+	ring  []time.Duration
+	pos   int
+	n     int
+	last  Event
+}
+
+// Keep last window intervals
+func NewIntervalStats(mode IntervalMode, window int) *IntervalStats {
+	if window < 1 {
+		window = 1
+	}
+	return &IntervalStats{
+		mode: mode,
+		ring: make([]time.Duration, window),
+	}
+}
+
+func (s *IntervalStats) Add(ev Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.mode == IntervalPeriod && ev.Value != 1 {
+		return
+	}
+
+	prev := s.last
+	s.last = ev
+	if prev.Timestamp.IsZero() {
+		return
+	}
+
+	switch s.mode {
+	case IntervalHigh:
+		if prev.Value != 1 || ev.Value != 0 {
+			return
+		}
+	case IntervalLow:
+		if prev.Value != 0 || ev.Value != 1 {
+			return
+		}
+	}
+
+	s.ring[s.pos] = ev.Timestamp.Sub(prev.Timestamp)
+	s.pos = (s.pos + 1) % len(s.ring)
+	if s.n < len(s.ring) {
+		s.n++
+	}
+}
+
+// Feed events from trigger until it's closed
+func (s *IntervalStats) Consume(tr PinTrigger) {
+	for ev := range tr.EventCh() {
+		s.Add(ev)
+	}
+}
+
+func (s *IntervalStats) Reset() {
+	s.mutex.Lock()
+	s.pos = 0
+	s.n = 0
+	s.last = Event{}
+	s.mutex.Unlock()
+}
+
+func (s *IntervalStats) Stats() IntervalSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res := IntervalSummary{Count: s.n}
+	if s.n == 0 {
+		return res
+	}
+
+	var sum float64
+	for i := 0; i < s.n; i++ {
+		d := s.ring[i]
+		if i == 0 || d < res.Min {
+			res.Min = d
+		}
+		if d > res.Max {
+			res.Max = d
+		}
+		sum += float64(d)
+	}
+	mean := sum / float64(s.n)
+
+	var variance float64
+	for i := 0; i < s.n; i++ {
+		x := float64(s.ring[i]) - mean
+		variance += x * x
+	}
+
+	res.Mean = time.Duration(mean)
+	res.Jitter = time.Duration(math.Sqrt(variance / float64(s.n)))
+	return res
+}
@@ -0,0 +1,291 @@
+package ir
+
+import (
+	"github.com/e-asphyx/gpio"
+	"time"
+)
+
+type Protocol int
+
+const (
+	NEC Protocol = iota
+	RC5
+)
+
+const (
+	necUnit         = 562500 * time.Nanosecond
+	necLeaderMark   = 16 * necUnit
+	necLeaderSpace  = 8 * necUnit
+	necRepeatSpace  = 4 * necUnit
+	necBits         = 32
+	necRepeatWindow = 150 * time.Millisecond // repeat codes come every 108ms
+
+	rc5Half         = 889 * time.Microsecond
+	rc5Halves       = 28
+	rc5RepeatWindow = 150 * time.Millisecond // held key is resent every 114ms
+
+	tolerancePct = 30
+)
+
+// Decoded remote control frame
+type Frame struct {
+	Protocol Protocol
+	Address  uint16 // 8 bit, or 16 bit for extended NEC
+	Command  uint8
+	Repeat   bool // key is being held
+}
+
+func near(d, want time.Duration) bool {
+	diff := d - want
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= want*tolerancePct/100
+}
+
+type necState int
+
+const (
+	necIdle necState = iota
+	necLeader
+	necData
+	necRepeat
+)
+
+type necDecoder struct {
+	state necState
+	bits  uint32
+	n     int
+	last  Frame
+	valid bool
+	since time.Duration // since the last frame or repeat
+}
+
+func (d *necDecoder) feed(mark bool, dur time.Duration) (Frame, bool) {
+	d.since += dur
+
+	switch {
+	case mark && near(dur, necLeaderMark):
+		d.state = necLeader
+		return Frame{}, false
+
+	case d.state == necLeader && !mark:
+		switch {
+		case near(dur, necLeaderSpace):
+			d.state, d.bits, d.n = necData, 0, 0
+		case near(dur, necRepeatSpace):
+			d.state = necRepeat
+		default:
+			d.state = necIdle
+		}
+		return Frame{}, false
+
+	case d.state == necRepeat && mark:
+		d.state = necIdle
+		if near(dur, necUnit) && d.valid && d.since < necRepeatWindow {
+			d.since = 0
+			f := d.last
+			f.Repeat = true
+			return f, true
+		}
+		return Frame{}, false
+
+	case d.state == necData && mark:
+		if !near(dur, necUnit) {
+			d.state = necIdle
+			return Frame{}, false
+		}
+		if d.n < necBits {
+			return Frame{}, false
+		}
+
+		// final mark after 32 bits
+		d.state = necIdle
+		addr, naddr := uint8(d.bits), uint8(d.bits>>8)
+		cmd, ncmd := uint8(d.bits>>16), uint8(d.bits>>24)
+		if cmd != ^ncmd {
+			return Frame{}, false
+		}
+
+		f := Frame{Protocol: NEC, Address: uint16(addr), Command: cmd}
+		if addr != ^naddr {
+			f.Address = uint16(addr) | uint16(naddr)<<8
+		}
+		d.last, d.valid, d.since = f, true, 0
+		return f, true
+
+	case d.state == necData && !mark:
+		// LSB first
+		switch {
+		case near(dur, necUnit):
+		case near(dur, 3*necUnit):
+			d.bits |= 1 << uint(d.n)
+		default:
+			d.state = necIdle
+			return Frame{}, false
+		}
+		d.n++
+		return Frame{}, false
+	}
+
+	d.state = necIdle
+	return Frame{}, false
+}
+
+type rc5Decoder struct {
+	halves     []bool // true for mark
+	lastToggle int
+	last       Frame
+	valid      bool
+	since      time.Duration
+}
+
+func (d *rc5Decoder) feed(mark bool, dur time.Duration) (Frame, bool) {
+	d.since += dur
+
+	var n int
+	switch {
+	case near(dur, rc5Half):
+		n = 1
+	case near(dur, 2*rc5Half):
+		n = 2
+	}
+
+	if n == 0 {
+		// gap after the frame completes a trailing zero bit
+		if !mark && len(d.halves) == rc5Halves-1 {
+			d.halves = append(d.halves, false)
+			return d.decode()
+		}
+		d.halves = d.halves[:0]
+		return Frame{}, false
+	}
+
+	if len(d.halves) == 0 {
+		if !mark {
+			return Frame{}, false
+		}
+		// first half of the start bit is idle
+		d.halves = append(d.halves, false)
+	}
+
+	for i := 0; i < n; i++ {
+		d.halves = append(d.halves, mark)
+	}
+
+	if len(d.halves) >= rc5Halves {
+		return d.decode()
+	}
+	return Frame{}, false
+}
+
+func (d *rc5Decoder) decode() (Frame, bool) {
+	halves := d.halves
+	d.halves = d.halves[:0]
+	if len(halves) != rc5Halves {
+		return Frame{}, false
+	}
+
+	var bits uint16
+	for i := 0; i < rc5Halves; i += 2 {
+		// space-mark is 1, mark-space is 0
+		switch {
+		case !halves[i] && halves[i+1]:
+			bits = bits<<1 | 1
+		case halves[i] && !halves[i+1]:
+			bits <<= 1
+		default:
+			return Frame{}, false
+		}
+	}
+
+	toggle := int(bits>>11) & 1
+	f := Frame{
+		Protocol: RC5,
+		Address:  (bits >> 6) & 0x1f,
+		Command:  uint8(bits & 0x3f),
+	}
+	// second start bit is the inverted 7th command bit in RC5X
+	if bits&(1<<12) == 0 {
+		f.Command |= 0x40
+	}
+
+	f.Repeat = d.valid && toggle == d.lastToggle && f.Address == d.last.Address &&
+		f.Command == d.last.Command && d.since < rc5RepeatWindow
+	d.last, d.lastToggle, d.valid, d.since = f, toggle, true, 0
+	return f, true
+}
+
+// Frame decoder fed by consecutive mark (carrier on) and space durations
+type Decoder struct {
+	nec necDecoder
+	rc5 rc5Decoder
+}
+
+func (dec *Decoder) Feed(mark bool, d time.Duration) (Frame, bool) {
+	f, ok := dec.nec.feed(mark, d)
+	if f2, ok2 := dec.rc5.feed(mark, d); ok2 {
+		return f2, true
+	}
+	return f, ok
+}
+
+// Decodes frames from a demodulating receiver like TSOP382 whose output is low during marks.
+// Wrap pin with gpio.Invert for active high receivers.
+type Receiver struct {
+	tr gpio.PinTrigger
+	ch chan Frame
+}
+
+func NewReceiver(pin gpio.PinReadTrigger) (*Receiver, error) {
+	tr, err := pin.Trigger(gpio.EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		tr: tr,
+		ch: make(chan Frame, 16),
+	}
+	go r.serve()
+
+	return r, nil
+}
+
+func (r *Receiver) serve() {
+	defer close(r.ch)
+
+	var (
+		dec  Decoder
+		last time.Time
+		buf  [64]gpio.Event
+	)
+
+	for {
+		n, err := gpio.ReadEvents(r.tr, buf[:])
+		if err != nil {
+			return
+		}
+
+		for _, ev := range buf[:n] {
+			if !last.IsZero() {
+				// level before the edge was a mark if the output went high
+				if f, ok := dec.Feed(ev.Value != 0, ev.Timestamp.Sub(last)); ok && len(r.ch) != cap(r.ch) {
+					r.ch <- f
+				}
+			}
+			last = ev.Timestamp
+		}
+	}
+}
+
+func (r *Receiver) Ch() <-chan Frame {
+	return r.ch
+}
+
+func (r *Receiver) Close() error {
+	err := r.tr.Close()
+	for range r.ch {
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package ir
+
+import (
+	"testing"
+	"time"
+)
+
+type pulse struct {
+	mark bool
+	dur  time.Duration
+}
+
+func necFrame(addr, naddr, cmd uint8) []pulse {
+	p := []pulse{{true, necLeaderMark}, {false, necLeaderSpace}}
+	bits := uint32(addr) | uint32(naddr)<<8 | uint32(cmd)<<16 | uint32(^cmd)<<24
+	for i := 0; i < necBits; i++ {
+		space := necUnit
+		if bits&(1<<uint(i)) != 0 {
+			space = 3 * necUnit
+		}
+		p = append(p, pulse{true, necUnit}, pulse{false, space})
+	}
+	return append(p, pulse{true, necUnit}, pulse{false, 40 * time.Millisecond})
+}
+
+func necRepeatCode() []pulse {
+	return []pulse{{true, necLeaderMark}, {false, necRepeatSpace}, {true, necUnit}, {false, 96 * time.Millisecond}}
+}
+
+func rc5Frame(toggle int, addr, cmd uint8) []pulse {
+	bits := uint16(1)<<13 | uint16(toggle&1)<<11 | uint16(addr&0x1f)<<6 | uint16(cmd&0x3f)
+	if cmd&0x40 == 0 {
+		bits |= 1 << 12
+	}
+
+	var halves []bool
+	for i := 13; i >= 0; i-- {
+		one := bits&(1<<uint(i)) != 0
+		halves = append(halves, !one, one)
+	}
+
+	// leading space is idle
+	var p []pulse
+	for _, h := range halves[1:] {
+		if n := len(p); n != 0 && p[n-1].mark == h {
+			p[n-1].dur += rc5Half
+		} else {
+			p = append(p, pulse{h, rc5Half})
+		}
+	}
+
+	gap := 89 * time.Millisecond
+	if n := len(p); !p[n-1].mark {
+		p[n-1].dur += gap
+	} else {
+		p = append(p, pulse{false, gap})
+	}
+	return p
+}
+
+func concat(frames ...[]pulse) []pulse {
+	var p []pulse
+	for _, f := range frames {
+		p = append(p, f...)
+	}
+	return p
+}
+
+// stretch all durations to check tolerance
+func scale(p []pulse, pct int) []pulse {
+	res := make([]pulse, len(p))
+	for i, x := range p {
+		res[i] = pulse{x.mark, x.dur * time.Duration(pct) / 100}
+	}
+	return res
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		pulses []pulse
+		want   []Frame
+	}{
+		{
+			"nec",
+			necFrame(0x04, ^uint8(0x04), 0x08),
+			[]Frame{{Protocol: NEC, Address: 0x04, Command: 0x08}},
+		},
+		{
+			"nec extended address",
+			necFrame(0x34, 0x12, 0xa5),
+			[]Frame{{Protocol: NEC, Address: 0x1234, Command: 0xa5}},
+		},
+		{
+			"nec repeat",
+			concat(necFrame(0x04, ^uint8(0x04), 0x08), necRepeatCode(), necRepeatCode()),
+			[]Frame{
+				{Protocol: NEC, Address: 0x04, Command: 0x08},
+				{Protocol: NEC, Address: 0x04, Command: 0x08, Repeat: true},
+				{Protocol: NEC, Address: 0x04, Command: 0x08, Repeat: true},
+			},
+		},
+		{
+			"nec repeat without frame",
+			necRepeatCode(),
+			nil,
+		},
+		{
+			"nec slow",
+			scale(necFrame(0x01, ^uint8(0x01), 0x02), 120),
+			[]Frame{{Protocol: NEC, Address: 0x01, Command: 0x02}},
+		},
+		{
+			"nec fast",
+			scale(necFrame(0x01, ^uint8(0x01), 0x02), 80),
+			[]Frame{{Protocol: NEC, Address: 0x01, Command: 0x02}},
+		},
+		{
+			"nec bad command check",
+			func() []pulse {
+				p := necFrame(0x04, ^uint8(0x04), 0x08)
+				// flip the last bit of inverted command
+				p[2+2*31+1].dur = necUnit
+				return p
+			}(),
+			nil,
+		},
+		{
+			"rc5",
+			rc5Frame(0, 0x05, 0x35),
+			[]Frame{{Protocol: RC5, Address: 0x05, Command: 0x35}},
+		},
+		{
+			"rc5 trailing zero",
+			rc5Frame(1, 0x00, 0x00),
+			[]Frame{{Protocol: RC5, Address: 0x00, Command: 0x00}},
+		},
+		{
+			"rc5x",
+			rc5Frame(0, 0x1f, 0x45),
+			[]Frame{{Protocol: RC5, Address: 0x1f, Command: 0x45}},
+		},
+		{
+			"rc5 held and pressed again",
+			concat(rc5Frame(0, 0x05, 0x10), rc5Frame(0, 0x05, 0x10), rc5Frame(1, 0x05, 0x10)),
+			[]Frame{
+				{Protocol: RC5, Address: 0x05, Command: 0x10},
+				{Protocol: RC5, Address: 0x05, Command: 0x10, Repeat: true},
+				{Protocol: RC5, Address: 0x05, Command: 0x10},
+			},
+		},
+		{
+			"rc5 slow",
+			scale(rc5Frame(0, 0x0a, 0x2b), 115),
+			[]Frame{{Protocol: RC5, Address: 0x0a, Command: 0x2b}},
+		},
+	}
+
+	for _, tt := range tests {
+		var (
+			dec Decoder
+			got []Frame
+		)
+		for _, p := range tt.pulses {
+			if f, ok := dec.Feed(p.mark, p.dur); ok {
+				got = append(got, f)
+			}
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: frame %d: got %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package gpio
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Per sample timeout of MeasureLatency
+const LatencyTimeout = 100 * time.Millisecond
+
+// Write to event delivery latency distribution
+type LatencyStats struct {
+	Samples int
+	Lost    int
+	Min     time.Duration
+	P50     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+var ErrNoSamples = errors.New("No events received")
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// Measure latency using output looped back to input. Output is toggled n times,
+// each toggle is timed from Write call to event receipt.
+func MeasureLatency(out PinWriter, in PinReadTrigger, n int) (*LatencyStats, error) {
+	if err := out.Write(0); err != nil {
+		return nil, err
+	}
+	time.Sleep(time.Millisecond)
+
+	tr, err := in.Trigger(EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+	defer tr.Close()
+
+	stats := &LatencyStats{}
+	samples := make([]time.Duration, 0, n)
+	value := 0
+	timer := time.NewTimer(LatencyTimeout)
+
+	for i := 0; i < n; i++ {
+		value ^= 1
+
+		// drop stale events
+		for len(tr.Ch()) != 0 {
+			<-tr.Ch()
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(LatencyTimeout)
+
+		start := time.Now()
+		if err := out.Write(value); err != nil {
+			return nil, err
+		}
+
+	wait:
+		for {
+			select {
+			case v, ok := <-tr.Ch():
+				if !ok {
+					return nil, ErrInvalid
+				}
+				if v == value {
+					samples = append(samples, time.Since(start))
+					break wait
+				}
+
+			case <-timer.C:
+				stats.Lost++
+				break wait
+			}
+		}
+	}
+
+	if len(samples) == 0 {
+		return stats, ErrNoSamples
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	stats.Samples = len(samples)
+	stats.Min = samples[0]
+	stats.Max = samples[len(samples)-1]
+	stats.P50 = percentile(samples, 0.5)
+	stats.P99 = percentile(samples, 0.99)
+
+	return stats, nil
+}
@@ -0,0 +1,268 @@
+package lcd
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"time"
+)
+
+const (
+	cmdClear       = 0x01
+	cmdHome        = 0x02
+	cmdEntryMode   = 0x04
+	cmdDisplay     = 0x08
+	cmdFunctionSet = 0x20
+	cmdSetCGRAM    = 0x40
+	cmdSetDDRAM    = 0x80
+
+	entryIncrement = 0x02
+
+	displayOn    = 0x04
+	displayCurs  = 0x02
+	displayBlink = 0x01
+
+	function2Line = 0x08
+
+	execTime  = 50 * time.Microsecond // 37us typical
+	clearTime = 2 * time.Millisecond  // 1.52ms typical
+)
+
+var (
+	ErrConfig = errors.New("Invalid display configuration")
+	ErrRange  = errors.New("Position out of range")
+)
+
+// HD44780 compatible character display in 4-bit mode. R/W must be tied low.
+type HD44780 struct {
+	rs        gpio.PinWriter
+	en        gpio.PinWriter
+	data      *gpio.PinGroup // D4-D7
+	backlight gpio.PinWriter
+	cols      int
+	rows      int
+	control   uint8
+	mutex     sync.Mutex
+}
+
+// Data bus as group of D4, D5, D6, D7 in this order
+func New(rs, en gpio.PinWriter, data *gpio.PinGroup, cols, rows int) (*HD44780, error) {
+	if data.Len() != 4 || cols < 1 || cols > 40 || rows < 1 || rows > 4 {
+		return nil, ErrConfig
+	}
+
+	d := &HD44780{
+		rs:      rs,
+		en:      en,
+		data:    data,
+		cols:    cols,
+		rows:    rows,
+		control: displayOn,
+	}
+
+	if err := d.init(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func NewPins(rs, en, d4, d5, d6, d7 gpio.PinWriter, cols, rows int) (*HD44780, error) {
+	data, err := gpio.NewPinGroup(d4, d5, d6, d7)
+	if err != nil {
+		return nil, err
+	}
+	return New(rs, en, data, cols, rows)
+}
+
+func (d *HD44780) init() error {
+	if err := d.en.Write(0); err != nil {
+		return err
+	}
+	if err := d.rs.Write(0); err != nil {
+		return err
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// reset by instruction, works regardless of the current interface width
+	for _, delay := range []time.Duration{4500 * time.Microsecond, 150 * time.Microsecond, 150 * time.Microsecond} {
+		if err := d.writeNibble(0x3); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+	}
+
+	// switch to 4-bit
+	if err := d.writeNibble(0x2); err != nil {
+		return err
+	}
+	time.Sleep(execTime)
+
+	function := uint8(cmdFunctionSet)
+	if d.rows > 1 {
+		function |= function2Line
+	}
+
+	for _, cmd := range []uint8{function, cmdDisplay | d.control, cmdClear, cmdEntryMode | entryIncrement} {
+		if err := d.command(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *HD44780) writeNibble(v uint8) error {
+	if err := d.data.Write(uint(v & 0xf)); err != nil {
+		return err
+	}
+	// data is latched on falling edge, min pulse width is 450ns
+	if err := d.en.Write(1); err != nil {
+		return err
+	}
+	time.Sleep(time.Microsecond)
+	return d.en.Write(0)
+}
+
+func (d *HD44780) write(v uint8, rs int) error {
+	if err := d.rs.Write(rs); err != nil {
+		return err
+	}
+	if err := d.writeNibble(v >> 4); err != nil {
+		return err
+	}
+	if err := d.writeNibble(v); err != nil {
+		return err
+	}
+
+	if rs == 0 && (v == cmdClear || v == cmdHome) {
+		time.Sleep(clearTime)
+	} else {
+		time.Sleep(execTime)
+	}
+	return nil
+}
+
+func (d *HD44780) command(cmd uint8) error {
+	return d.write(cmd, 0)
+}
+
+func (d *HD44780) Clear() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.command(cmdClear)
+}
+
+func (d *HD44780) Home() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.command(cmdHome)
+}
+
+func (d *HD44780) SetCursor(col, row int) error {
+	if col < 0 || col >= d.cols || row < 0 || row >= d.rows {
+		return ErrRange
+	}
+
+	// rows 2 and 3 continue rows 0 and 1 in DDRAM
+	offset := [4]int{0x00, 0x40, d.cols, 0x40 + d.cols}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.command(cmdSetDDRAM | uint8(offset[row]+col))
+}
+
+// Writes raw character codes at cursor position, codes 0-7 are custom characters
+func (d *HD44780) Write(p []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for i, c := range p {
+		if err := d.write(c, 1); err != nil {
+			return i, err
+		}
+	}
+	return len(p), nil
+}
+
+// Non-ASCII characters are printed as '?'
+func (d *HD44780) Print(s string) error {
+	buf := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			r = '?'
+		}
+		buf = append(buf, byte(r))
+	}
+
+	_, err := d.Write(buf)
+	return err
+}
+
+// Defines custom character 0-7 from 5x8 bitmap, bit 4 is the leftmost pixel.
+// Cursor position is reset to home.
+func (d *HD44780) CreateChar(n int, bitmap [8]byte) error {
+	if n < 0 || n > 7 {
+		return ErrRange
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.command(cmdSetCGRAM | uint8(n<<3)); err != nil {
+		return err
+	}
+	for _, row := range bitmap {
+		if err := d.write(row&0x1f, 1); err != nil {
+			return err
+		}
+	}
+	return d.command(cmdHome)
+}
+
+func (d *HD44780) setControl(bit uint8, on bool) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	control := d.control &^ bit
+	if on {
+		control |= bit
+	}
+	if err := d.command(cmdDisplay | control); err != nil {
+		return err
+	}
+	d.control = control
+	return nil
+}
+
+// Display contents are retained while off
+func (d *HD44780) SetDisplay(on bool) error {
+	return d.setControl(displayOn, on)
+}
+
+func (d *HD44780) SetCursorVisible(on bool) error {
+	return d.setControl(displayCurs, on)
+}
+
+func (d *HD44780) SetBlink(on bool) error {
+	return d.setControl(displayBlink, on)
+}
+
+// Optional active high backlight control, i.e. transistor gate
+func (d *HD44780) SetBacklightPin(pin gpio.PinWriter) {
+	d.mutex.Lock()
+	d.backlight = pin
+	d.mutex.Unlock()
+}
+
+func (d *HD44780) SetBacklight(on bool) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.backlight == nil {
+		return gpio.ErrUnsupported
+	}
+	return gpio.WriteBool(d.backlight, on)
+}
+
+func (d *HD44780) Size() (cols, rows int) {
+	return d.cols, d.rows
+}
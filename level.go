@@ -0,0 +1,66 @@
+package gpio
+
+type Level int
+
+//go:generate stringer -type=Level
+// Logic level
+const (
+	Low Level = iota
+	High
+)
+
+// Converts raw pin value
+func LevelOf(value int) Level {
+	if value != 0 {
+		return High
+	}
+	return Low
+}
+
+func (l Level) Int() int {
+	if l != Low {
+		return 1
+	}
+	return 0
+}
+
+func (l Level) Not() Level {
+	if l != Low {
+		return Low
+	}
+	return High
+}
+
+func ReadLevel(pin PinReader) (Level, error) {
+	val, err := pin.Read()
+	if err != nil {
+		return Low, err
+	}
+	return LevelOf(val), nil
+}
+
+func WriteLevel(pin PinWriter, l Level) error {
+	return pin.Write(l.Int())
+}
+
+func (pin *Pin) ReadLevel() (Level, error) {
+	return ReadLevel(pin)
+}
+
+func (pin *Pin) WriteLevel(l Level) error {
+	return WriteLevel(pin, l)
+}
+
+// Converts trigger values to levels. The returned channel is closed along with the trigger one.
+func Levels(tr PinTrigger) <-chan Level {
+	out := make(chan Level, cap(tr.Ch()))
+
+	go func() {
+		for val := range tr.Ch() {
+			out <- LevelOf(val)
+		}
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,507 @@
+package gpio
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+const (
+	gpioV2GetLineIoctl          = 0xc250b407
+	gpioV2LineSetConfigIoctl    = 0xc110b40d
+	gpioV2LineGetValuesIoctl    = 0xc010b40e
+	gpioV2LineSetValuesIoctl    = 0xc010b40f
+	gpioV2LineEventRisingEdge   = 1
+	gpioV2LineEventFallingEdge  = 2
+	lineEventBufferSize         = 64
+	lineEventsPerRead           = 16
+	lineDirectionFlags          = LineInput | LineOutput
+	lineEdgeFlags               = LineEdgeRising | LineEdgeFalling
+	lineClockFlags              = LineClockRealtime | LineClockHTE
+	lineDriveFlags              = LineOpenDrain | LineOpenSource
+	lineConfigurableFlagsFilter = ^LineUsed
+)
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [gpioV2LineNumAttrsMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [gpioV2LinesMax]uint32
+	consumer        [gpioMaxNameSize]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+type gpioV2LineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+// Line requested from GPIO character device
+type Line struct {
+	chip     *Chip
+	ownChip  bool
+	offset   int
+	fd       *os.File
+	flags    LineFlags
+	drive    LineFlags // applied when switched to output
+	debounce time.Duration
+	clock    EventClock
+	mutex    sync.Mutex
+
+	ch      chan int
+	events  chan Event
+	errs    chan error
+	feed    valueFeed
+	history eventRing
+	trigger Trigger
+	cfg     triggerConfig
+	done    chan struct{}
+
+	overflows uint32
+}
+
+type lineTrigger Line
+
+// Consumer label for requested lines
+var Consumer = filepath.Base(os.Args[0])
+
+// Source of line event timestamps
+type EventClock int
+
+const (
+	ClockMonotonic EventClock = iota
+	ClockRealtime
+	ClockHTE // hardware timestamping engine, if the platform has one
+)
+
+func (c EventClock) flags() LineFlags {
+	switch c {
+	case ClockRealtime:
+		return LineClockRealtime
+	case ClockHTE:
+		return LineClockHTE
+	}
+	return 0
+}
+
+func monotonicNow() time.Duration {
+	var ts unix.Timespec
+	unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
+	return time.Duration(ts.Nano())
+}
+
+// Convert CLOCK_MONOTONIC timestamp to wall clock time
+func MonotonicToTime(mono time.Duration) time.Time {
+	return defaultCorrelator.Time(mono)
+}
+
+// Request line keeping its current direction
+func (chip *Chip) RequestLine(offset int) (*Line, error) {
+	info, err := chip.LineInfo(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var req gpioV2LineRequest
+	req.offsets[0] = uint32(offset)
+	req.numLines = 1
+	req.eventBufferSize = lineEventBufferSize
+	copy(req.consumer[:gpioMaxNameSize-1], Consumer)
+
+	err = ioctlPtr(chip.fd.Fd(), gpioV2GetLineIoctl, unsafe.Pointer(&req))
+	if err != nil {
+		return nil, err
+	}
+
+	// make it pollable so reads can be interrupted by deadline
+	if err = unix.SetNonblock(int(req.fd), true); err != nil {
+		unix.Close(int(req.fd))
+		return nil, err
+	}
+
+	l := &Line{
+		chip:   chip,
+		offset: offset,
+		fd:     os.NewFile(uintptr(req.fd), fmt.Sprintf("%s:%d", chip.Name, offset)),
+		flags:  info.Flags & lineConfigurableFlagsFilter &^ lineEdgeFlags,
+		drive:  info.Flags & lineDriveFlags,
+	}
+	runtime.SetFinalizer(l, (*Line).Close)
+
+	return l, nil
+}
+
+// must be called with mutex held
+func (l *Line) setConfig(flags LineFlags, debounce time.Duration, value int) error {
+	var cfg gpioV2LineConfig
+	cfg.flags = uint64(flags)
+
+	if flags&LineOutput != 0 {
+		cfg.attrs[cfg.numAttrs] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIdOutputValues, value: uint64(value & 1)},
+			mask: 1,
+		}
+		cfg.numAttrs++
+	}
+
+	if debounce > 0 {
+		cfg.attrs[cfg.numAttrs] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIdDebounce, value: uint64(debounce / time.Microsecond)},
+			mask: 1,
+		}
+		cfg.numAttrs++
+	}
+
+	err := ioctlPtr(l.fd.Fd(), gpioV2LineSetConfigIoctl, unsafe.Pointer(&cfg))
+	if err != nil {
+		return err
+	}
+
+	l.flags = flags
+	l.debounce = debounce
+	return nil
+}
+
+func (l *Line) read() (int, error) {
+	vals := gpioV2LineValues{mask: 1}
+	err := ioctlPtr(l.fd.Fd(), gpioV2LineGetValuesIoctl, unsafe.Pointer(&vals))
+	if err != nil {
+		return 0, err
+	}
+	return int(vals.bits & 1), nil
+}
+
+func (l *Line) Offset() int {
+	return l.offset
+}
+
+func (l *Line) Read() (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.ch != nil {
+		return 0, ErrTrigger
+	}
+	return l.read()
+}
+
+func (l *Line) Write(value int) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.ch != nil {
+		return ErrTrigger
+	}
+	if l.flags&LineOutput == 0 {
+		return ErrDirIn
+	}
+
+	if DryRun() {
+		RecordDryRunWrite(l.String(), value)
+		return nil
+	}
+
+	vals := gpioV2LineValues{mask: 1}
+	if value != 0 {
+		vals.bits = 1
+	}
+	return ioctlPtr(l.fd.Fd(), gpioV2LineSetValuesIoctl, unsafe.Pointer(&vals))
+}
+
+func (l *Line) Direction() (Direction, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.flags&LineOutput != 0 {
+		return DirOut, nil
+	}
+	return DirIn, nil
+}
+
+// Switching to output keeps current level
+func (l *Line) SetDirection(dir Direction) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.ch != nil {
+		return ErrTrigger
+	}
+
+	flags := l.flags &^ (lineDirectionFlags | lineDriveFlags)
+	var value int
+	if dir == DirOut {
+		flags |= LineOutput | l.drive
+		value, _ = l.read()
+	} else {
+		flags |= LineInput
+	}
+
+	return l.setConfig(flags, 0, value)
+}
+
+func edgeFlags(edge Trigger) LineFlags {
+	switch edge {
+	case EdgeRising:
+		return LineEdgeRising
+	case EdgeFalling:
+		return LineEdgeFalling
+	case EdgeBoth:
+		return LineEdgeRising | LineEdgeFalling
+	}
+	return 0
+}
+
+func (l *Line) startTrigger(edge Trigger, debounce time.Duration, opts ...TriggerOption) (PinTrigger, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.ch != nil {
+		return (*lineTrigger)(l), nil
+	}
+
+	flags := l.flags&^(lineDirectionFlags|lineDriveFlags|lineEdgeFlags|lineClockFlags) | LineInput | edgeFlags(edge) | l.clock.flags()
+	if err := l.setConfig(flags, debounce, 0); err != nil {
+		return nil, err
+	}
+
+	l.trigger = edge
+	l.cfg = newTriggerConfig(opts)
+	atomic.StoreUint32(&l.overflows, 0)
+	l.ch = make(chan int, l.cfg.buffer)
+	l.events = make(chan Event, l.cfg.buffer)
+	l.errs = make(chan error, 1)
+	l.feed.reset()
+	l.done = make(chan struct{})
+
+	go (*lineTrigger)(l).serve(l.ch, l.events, l.done)
+
+	return (*lineTrigger)(l), nil
+}
+
+func (l *Line) Trigger(edge Trigger) (PinTrigger, error) {
+	return l.startTrigger(edge, 0)
+}
+
+// Options take effect only when the trigger is not running yet
+func (l *Line) TriggerWithOptions(edge Trigger, opts ...TriggerOption) (PinTrigger, error) {
+	return l.startTrigger(edge, 0, opts...)
+}
+
+// Timestamp source for triggers started afterwards. Events are always delivered
+// with wall clock time, monotonic stamps are converted.
+func (l *Line) SetEventClock(clock EventClock) {
+	l.mutex.Lock()
+	l.clock = clock
+	l.mutex.Unlock()
+}
+
+func (l *Line) TriggerWithClock(edge Trigger, clock EventClock) (PinTrigger, error) {
+	l.SetEventClock(clock)
+	return l.Trigger(edge)
+}
+
+// Uses kernel debounce if supported, software one otherwise
+func (l *Line) TriggerWithDebounce(edge Trigger, interval time.Duration) (PinTrigger, error) {
+	if interval < 0 {
+		interval = DefaultDebounceInterval
+	}
+
+	tr, err := l.startTrigger(edge, interval)
+	if err == nil {
+		return tr, nil
+	}
+
+	if err != unix.EINVAL && err != unix.ENOTSUP && err != unix.ENOTTY {
+		return nil, err
+	}
+	return NewDebounceWithInterval(l, edge, interval)
+}
+
+func (tr *lineTrigger) decode(ev *gpioV2LineEvent) Event {
+	val := 0
+	if ev.id == gpioV2LineEventRisingEdge {
+		val = 1
+	}
+
+	var ts time.Time
+	if tr.flags&LineClockRealtime != 0 {
+		ts = time.Unix(0, int64(ev.timestampNs))
+	} else {
+		ts = MonotonicToTime(time.Duration(ev.timestampNs))
+	}
+
+	return Event{Value: val, Timestamp: ts, Seq: uint64(ev.lineSeqno)}
+}
+
+func (tr *lineTrigger) serve(ch chan int, events chan Event, done chan struct{}) {
+	defer close(done)
+	defer tr.feed.close(ch, events)
+
+	var buf [lineEventsPerRead]gpioV2LineEvent
+	raw := (*[unsafe.Sizeof(buf)]byte)(unsafe.Pointer(&buf))[:]
+	evSize := int(unsafe.Sizeof(buf[0]))
+
+	for {
+		n, err := tr.fd.Read(raw)
+		if err != nil {
+			// deadline is set by Close
+			if te, ok := err.(interface {
+				Timeout() bool
+			}); !ok || !te.Timeout() {
+				select {
+				case tr.errs <- err:
+				default:
+				}
+			}
+			if pe, ok := err.(*os.PathError); ok && pe.Err == unix.ENODEV {
+				notifyPinStatus((*Line)(tr), PinRemoved, err)
+			}
+			return
+		}
+
+		for i := 0; i < n/evSize; i++ {
+			ev := tr.decode(&buf[i])
+			tr.history.push(ev)
+
+			if !tr.cfg.deliver(events, ev) {
+				atomic.AddUint32(&tr.overflows, 1)
+			}
+		}
+	}
+}
+
+func (tr *lineTrigger) Err() <-chan error {
+	return tr.errs
+}
+
+func (tr *lineTrigger) Overflows() uint32 {
+	return atomic.LoadUint32(&tr.overflows)
+}
+
+// Values are taken from EventCh, read one of them
+func (tr *lineTrigger) Ch() <-chan int {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	tr.feed.start(tr.ch, tr.events)
+	return tr.ch
+}
+
+func (tr *lineTrigger) EventCh() <-chan Event {
+	return tr.events
+}
+
+func (tr *lineTrigger) Trigger() Trigger {
+	return tr.trigger
+}
+
+func (tr *lineTrigger) ReadEvents(buf []Event) (int, error) {
+	return readEvents(tr.events, buf)
+}
+
+func (tr *lineTrigger) SetHistory(n int) {
+	tr.history.resize(n)
+}
+
+func (tr *lineTrigger) History() []Event {
+	return tr.history.events()
+}
+
+func (tr *lineTrigger) Close() error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if tr.ch == nil {
+		return ErrInvalid
+	}
+
+	// interrupt blocked read
+	tr.fd.SetReadDeadline(time.Now())
+	drainTrigger(tr.ch, tr.events)
+	<-tr.done
+	tr.fd.SetReadDeadline(time.Time{})
+
+	tr.ch = nil
+	tr.events = nil
+	tr.done = nil
+
+	return (*Line)(tr).setConfig(tr.flags&^lineEdgeFlags, 0, 0)
+}
+
+// Release line
+func (l *Line) Close() error {
+	if l.ch != nil {
+		if err := (*lineTrigger)(l).Close(); err != nil {
+			return err
+		}
+	}
+
+	err := l.fd.Close()
+	if l.ownChip {
+		if e := l.chip.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// "gpiochip0:5" specifier
+func openChipLine(arg string) (PinReader, error) {
+	i := strings.IndexByte(arg, ':')
+	if i < 0 {
+		return nil, ErrSpec
+	}
+
+	offset, err := strconv.ParseUint(arg[i+1:], 10, 16)
+	if err != nil {
+		return nil, ErrSpec
+	}
+
+	chip, err := OpenChip("gpiochip" + arg[:i])
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := chip.RequestLine(int(offset))
+	if err != nil {
+		chip.Close()
+		return nil, err
+	}
+	l.ownChip = true
+
+	return l, nil
+}
+
+func init() {
+	RegisterBackend("gpiochip", openChipLine)
+}
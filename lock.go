@@ -0,0 +1,64 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const DefaultLockDir = "/var/lock"
+
+var ErrPinLocked = errors.New("Pin is locked by another process")
+
+var (
+	lockDir   string
+	lockMutex sync.Mutex
+)
+
+// Take advisory lock file gpio-N.lock in dir before exporting pin and hold it until the pin is
+// unexported, so cooperating processes don't share pins silently. Empty dir disables locking (default).
+// Locks are per open pin, so opening the same pin twice fails too.
+func SetLockDir(dir string) {
+	lockMutex.Lock()
+	lockDir = dir
+	lockMutex.Unlock()
+}
+
+func lockPin(num int) (*os.File, error) {
+	lockMutex.Lock()
+	dir := lockDir
+	lockMutex.Unlock()
+
+	if dir == "" {
+		return nil, nil
+	}
+
+	fd, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("gpio-%d.lock", num)), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = unix.Flock(int(fd.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		fd.Close()
+		if err == unix.EWOULDBLOCK {
+			return nil, ErrPinLocked
+		}
+		return nil, err
+	}
+
+	// informational only, the lock itself is what counts
+	fd.Truncate(0)
+	fmt.Fprintf(fd, "%d\n", os.Getpid())
+
+	return fd, nil
+}
+
+// File is left in place, removing it would race with other lockers
+func unlockPin(fd *os.File) {
+	if fd != nil {
+		fd.Close()
+	}
+}
@@ -0,0 +1,107 @@
+package gpio
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+type ReadFunc func() (int, error)
+type WriteFunc func(value int) error
+
+// Interceptor for pin operations. Either function can be nil.
+type Middleware struct {
+	Read  func(next ReadFunc) ReadFunc
+	Write func(next WriteFunc) WriteFunc
+}
+
+var ErrUnsupported = errors.New("Operation not supported")
+
+// Pin with middleware chain attached. Operations not supported by the wrapped pin return ErrUnsupported.
+type WrappedPin struct {
+	pin   interface{}
+	read  ReadFunc
+	write WriteFunc
+}
+
+// Attach middleware to pin. The first middleware is the outermost one.
+func Wrap(pin interface{}, mw ...Middleware) *WrappedPin {
+	w := &WrappedPin{pin: pin}
+
+	if r, ok := pin.(PinReader); ok {
+		w.read = r.Read
+	} else {
+		w.read = func() (int, error) { return 0, ErrUnsupported }
+	}
+
+	if wr, ok := pin.(PinWriter); ok {
+		w.write = wr.Write
+	} else {
+		w.write = func(int) error { return ErrUnsupported }
+	}
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		if mw[i].Read != nil {
+			w.read = mw[i].Read(w.read)
+		}
+		if mw[i].Write != nil {
+			w.write = mw[i].Write(w.write)
+		}
+	}
+
+	return w
+}
+
+// Wrapped pin
+func (w *WrappedPin) Unwrap() interface{} {
+	return w.pin
+}
+
+func (w *WrappedPin) Read() (int, error) {
+	return w.read()
+}
+
+func (w *WrappedPin) Write(value int) error {
+	return w.write(value)
+}
+
+func (w *WrappedPin) Direction() (Direction, error) {
+	if p, ok := w.pin.(PinReadWriter); ok {
+		return p.Direction()
+	}
+	return DirIn, ErrUnsupported
+}
+
+func (w *WrappedPin) Trigger(edge Trigger) (PinTrigger, error) {
+	if p, ok := w.pin.(PinReadTrigger); ok {
+		return p.Trigger(edge)
+	}
+	return nil, ErrUnsupported
+}
+
+func (w *WrappedPin) TriggerWithDebounce(edge Trigger, interval time.Duration) (PinTrigger, error) {
+	if p, ok := w.pin.(PinReadTrigger); ok {
+		return p.TriggerWithDebounce(edge, interval)
+	}
+	return nil, ErrUnsupported
+}
+
+// Log every operation with given prefix
+func Logger(l *log.Logger, name string) Middleware {
+	return Middleware{
+		Read: func(next ReadFunc) ReadFunc {
+			return func() (int, error) {
+				val, err := next()
+				l.Printf("%s: read %d %v", name, val, err)
+				return val, err
+			}
+		},
+		Write: func(next WriteFunc) WriteFunc {
+			return func(value int) error {
+				err := next(value)
+				l.Printf("%s: write %d %v", name, value, err)
+				return err
+			}
+		},
+	}
+}
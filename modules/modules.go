@@ -0,0 +1,262 @@
+package modules
+
+import (
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"time"
+)
+
+// Electrical defaults of a module
+type Profile struct {
+	ActiveLow bool
+	Pull      gpio.Pull     // applied only if the backend can set pulls
+	Debounce  time.Duration // zero disables debouncing
+}
+
+var (
+	// Grove button, has on-board pull-down
+	GroveButton = Profile{Debounce: 20 * time.Millisecond}
+	// Bare push button wired to ground
+	PlainButton = Profile{ActiveLow: true, Pull: gpio.PullUp, Debounce: 20 * time.Millisecond}
+	// Grove mini PIR, clean push-pull output
+	GrovePIR = Profile{}
+	// Grove relay, LED and buzzer are driven active high
+	GroveRelay  = Profile{}
+	GroveLED    = Profile{}
+	GroveBuzzer = Profile{}
+)
+
+// Digital input module
+type Input struct {
+	src     gpio.PinReadTrigger
+	closer  func() error
+	profile Profile
+
+	mutex sync.Mutex
+	tr    gpio.PinTrigger
+	last  bool
+}
+
+// Digital output module
+type Output struct {
+	dst    gpio.PinWriter
+	closer func() error
+
+	mutex sync.Mutex
+	on    bool
+}
+
+// Wrap already opened pin
+func NewInput(pin gpio.PinReadTrigger, p Profile) *Input {
+	// external resistor is assumed where bias is unsupported
+	if pc, ok := pin.(gpio.PinConfigurer); ok {
+		pc.SetPull(p.Pull)
+	}
+
+	in := &Input{src: pin, profile: p}
+	if p.ActiveLow {
+		in.src = gpio.Invert(pin)
+	}
+	return in
+}
+
+// Open sysfs GPIO as input
+func OpenInput(num int, p Profile) (*Input, error) {
+	pin, err := gpio.NewPin(num)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pin.SetDirection(gpio.DirIn); err != nil {
+		pin.Close()
+		return nil, err
+	}
+
+	in := NewInput(pin, p)
+	in.closer = pin.Close
+	return in, nil
+}
+
+func NewButton(num int) (*Input, error) {
+	return OpenInput(num, GroveButton)
+}
+
+func NewPIR(num int) (*Input, error) {
+	return OpenInput(num, GrovePIR)
+}
+
+// Current state. Returns the last reported state while watched.
+func (in *Input) Active() (bool, error) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	if in.tr != nil {
+		return in.last, nil
+	}
+	return gpio.ReadBool(in.src)
+}
+
+// Deliver state changes using profile's debounce interval
+func (in *Input) Watch() (<-chan bool, error) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	if in.tr != nil {
+		return nil, gpio.ErrTrigger
+	}
+
+	last, err := gpio.ReadBool(in.src)
+	if err != nil {
+		return nil, err
+	}
+
+	var tr gpio.PinTrigger
+	if in.profile.Debounce > 0 {
+		tr, err = in.src.TriggerWithDebounce(gpio.EdgeBoth, in.profile.Debounce)
+	} else {
+		tr, err = in.src.Trigger(gpio.EdgeBoth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	in.tr = tr
+	in.last = last
+
+	ch := make(chan bool, 1)
+	go func() {
+		for val := range tr.Ch() {
+			in.mutex.Lock()
+			in.last = val != 0
+			in.mutex.Unlock()
+
+			select {
+			case ch <- val != 0:
+			default:
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// Stop watching and release the pin if opened by constructor
+func (in *Input) Close() error {
+	in.mutex.Lock()
+	tr := in.tr
+	in.tr = nil
+	in.mutex.Unlock()
+
+	if tr != nil {
+		if err := tr.Close(); err != nil {
+			return err
+		}
+	}
+
+	if in.closer != nil {
+		return in.closer()
+	}
+	return nil
+}
+
+// Wrap already opened pin. Output is switched off.
+func NewOutput(pin gpio.PinWriter, p Profile) (*Output, error) {
+	out := &Output{dst: pin}
+	if p.ActiveLow {
+		out.dst = gpio.Invert(pin)
+	}
+
+	if err := out.Set(false); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Open sysfs GPIO as output
+func OpenOutput(num int, p Profile) (*Output, error) {
+	pin, err := gpio.NewPin(num)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pin.SetDirection(gpio.DirOut); err != nil {
+		pin.Close()
+		return nil, err
+	}
+
+	out, err := NewOutput(pin, p)
+	if err != nil {
+		pin.Close()
+		return nil, err
+	}
+
+	out.closer = pin.Close
+	return out, nil
+}
+
+func NewRelay(num int) (*Output, error) {
+	return OpenOutput(num, GroveRelay)
+}
+
+func NewLED(num int) (*Output, error) {
+	return OpenOutput(num, GroveLED)
+}
+
+func NewBuzzer(num int) (*Output, error) {
+	return OpenOutput(num, GroveBuzzer)
+}
+
+func (out *Output) Set(on bool) error {
+	out.mutex.Lock()
+	defer out.mutex.Unlock()
+
+	if err := gpio.WriteBool(out.dst, on); err != nil {
+		return err
+	}
+	out.on = on
+	return nil
+}
+
+func (out *Output) On() error {
+	return out.Set(true)
+}
+
+func (out *Output) Off() error {
+	return out.Set(false)
+}
+
+func (out *Output) Toggle() error {
+	out.mutex.Lock()
+	on := out.on
+	out.mutex.Unlock()
+
+	return out.Set(!on)
+}
+
+func (out *Output) IsOn() bool {
+	out.mutex.Lock()
+	defer out.mutex.Unlock()
+	return out.on
+}
+
+// Switch on for d, e.g. beep a buzzer
+func (out *Output) Pulse(d time.Duration) error {
+	if err := out.On(); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return out.Off()
+}
+
+// Switch off and release the pin if opened by constructor
+func (out *Output) Close() error {
+	err := out.Off()
+
+	if out.closer != nil {
+		if e := out.closer(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,381 @@
+package onewire
+
+import (
+	"errors"
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	cmdSearchROM = 0xf0
+	cmdReadROM   = 0x33
+	cmdMatchROM  = 0x55
+	cmdSkipROM   = 0xcc
+
+	cmdConvertT       = 0x44
+	cmdReadScratchpad = 0xbe
+
+	FamilyDS18B20 = 0x28
+
+	conversionTime = 750 * time.Millisecond // 12 bit resolution
+)
+
+var (
+	ErrNoPresence = errors.New("No devices on the bus")
+	ErrCRC        = errors.New("CRC mismatch")
+	ErrSearch     = errors.New("Search failed")
+)
+
+// 64 bit ROM code: family, serial number and CRC
+type Address [8]byte
+
+func (a Address) Family() byte {
+	return a[0]
+}
+
+func (a Address) String() string {
+	return fmt.Sprintf("%02x-%02x%02x%02x%02x%02x%02x", a[0], a[6], a[5], a[4], a[3], a[2], a[1])
+}
+
+// Bus master on a single pin with external 4.7k pull-up. The line is driven open-drain by switching
+// the pin between low output and input. Slot timing is microsecond scale, so use memory mapped
+// pins where possible; sysfs is too slow on most boards.
+type Bus struct {
+	pin   gpio.PinReadWriter
+	mutex sync.Mutex
+}
+
+func New(pin gpio.PinReadWriter) (*Bus, error) {
+	b := &Bus{pin: pin}
+	if err := b.release(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Bus) release() error {
+	if p, ok := b.pin.(gpio.DirectionSetter); ok {
+		return p.SetDirection(gpio.DirIn)
+	}
+	return b.pin.Write(1)
+}
+
+func (b *Bus) low() error {
+	if p, ok := b.pin.(gpio.DirectionSetter); ok {
+		if err := p.SetDirection(gpio.DirOut); err != nil {
+			return err
+		}
+	}
+	return b.pin.Write(0)
+}
+
+func spinUntil(t time.Time) {
+	for time.Now().Before(t) {
+	}
+}
+
+// Must be called with mutex held and thread locked
+func (b *Bus) reset() (bool, error) {
+	if err := b.low(); err != nil {
+		return false, err
+	}
+	time.Sleep(480 * time.Microsecond)
+
+	start := time.Now()
+	if err := b.release(); err != nil {
+		return false, err
+	}
+	spinUntil(start.Add(70 * time.Microsecond))
+
+	v, err := b.pin.Read()
+	if err != nil {
+		return false, err
+	}
+	time.Sleep(410 * time.Microsecond)
+
+	return v == 0, nil
+}
+
+func (b *Bus) writeBit(v int) error {
+	start := time.Now()
+	if err := b.low(); err != nil {
+		return err
+	}
+
+	if v != 0 {
+		spinUntil(start.Add(6 * time.Microsecond))
+		if err := b.release(); err != nil {
+			return err
+		}
+		spinUntil(start.Add(70 * time.Microsecond))
+	} else {
+		spinUntil(start.Add(60 * time.Microsecond))
+		if err := b.release(); err != nil {
+			return err
+		}
+		spinUntil(start.Add(70 * time.Microsecond))
+	}
+	return nil
+}
+
+func (b *Bus) readBit() (int, error) {
+	start := time.Now()
+	if err := b.low(); err != nil {
+		return 0, err
+	}
+	spinUntil(start.Add(6 * time.Microsecond))
+	if err := b.release(); err != nil {
+		return 0, err
+	}
+	spinUntil(start.Add(15 * time.Microsecond))
+
+	v, err := b.pin.Read()
+	if err != nil {
+		return 0, err
+	}
+	spinUntil(start.Add(70 * time.Microsecond))
+	return v, nil
+}
+
+func (b *Bus) writeByte(v byte) error {
+	for i := uint(0); i < 8; i++ {
+		if err := b.writeBit(int(v>>i) & 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bus) readByte() (byte, error) {
+	var v byte
+	for i := uint(0); i < 8; i++ {
+		bit, err := b.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v |= byte(bit) << i
+	}
+	return v, nil
+}
+
+// Dallas/Maxim CRC-8
+func CRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			mix := (crc ^ b) & 1
+			crc >>= 1
+			if mix != 0 {
+				crc ^= 0x8c
+			}
+			b >>= 1
+		}
+	}
+	return crc
+}
+
+// Reset the bus, address the device (all devices if addr is nil) and exchange data.
+// Thread is locked for the whole transaction.
+func (b *Bus) Transaction(addr *Address, w []byte, r []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	return b.transaction(addr, w, r)
+}
+
+func (b *Bus) transaction(addr *Address, w []byte, r []byte) error {
+	present, err := b.reset()
+	if err != nil {
+		return err
+	}
+	if !present {
+		return ErrNoPresence
+	}
+
+	if addr == nil {
+		err = b.writeByte(cmdSkipROM)
+	} else {
+		err = b.writeByte(cmdMatchROM)
+		for i := 0; err == nil && i < len(addr); i++ {
+			err = b.writeByte(addr[i])
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, v := range w {
+		if err = b.writeByte(v); err != nil {
+			return err
+		}
+	}
+	for i := range r {
+		if r[i], err = b.readByte(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Presence pulse check
+func (b *Bus) Reset() (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	return b.reset()
+}
+
+// Address of the only device on the bus
+func (b *Bus) ReadROM() (Address, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var a Address
+	present, err := b.reset()
+	if err != nil {
+		return a, err
+	}
+	if !present {
+		return a, ErrNoPresence
+	}
+	if err = b.writeByte(cmdReadROM); err != nil {
+		return a, err
+	}
+	for i := range a {
+		if a[i], err = b.readByte(); err != nil {
+			return a, err
+		}
+	}
+	if CRC8(a[:7]) != a[7] {
+		return a, ErrCRC
+	}
+	return a, nil
+}
+
+// Enumerate all devices using the ROM search algorithm
+func (b *Bus) Search() ([]Address, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var (
+		res     []Address
+		last    Address
+		lastDev = -1 // bit position of the last discrepancy taken as 0
+	)
+
+	for {
+		addr, next, err := b.searchPass(last, lastDev)
+		if err != nil {
+			return res, err
+		}
+		if CRC8(addr[:7]) != addr[7] {
+			return res, ErrCRC
+		}
+
+		res = append(res, addr)
+		if next < 0 {
+			return res, nil
+		}
+		last, lastDev = addr, next
+	}
+}
+
+// One pass of the search following prev up to discrepancy at bit pos (then taking 1).
+// Returns the new deepest zero-branch discrepancy or -1 if the search is complete.
+func (b *Bus) searchPass(prev Address, pos int) (Address, int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var addr Address
+	present, err := b.reset()
+	if err != nil {
+		return addr, -1, err
+	}
+	if !present {
+		return addr, -1, ErrNoPresence
+	}
+	if err = b.writeByte(cmdSearchROM); err != nil {
+		return addr, -1, err
+	}
+
+	discrepancy := -1
+	for i := 0; i < 64; i++ {
+		bit, err := b.readBit()
+		if err != nil {
+			return addr, -1, err
+		}
+		comp, err := b.readBit()
+		if err != nil {
+			return addr, -1, err
+		}
+
+		var dir int
+		switch {
+		case bit == 1 && comp == 1:
+			return addr, -1, ErrSearch
+		case bit != comp:
+			dir = bit
+		case i < pos:
+			dir = int(prev[i/8]>>uint(i%8)) & 1
+			if dir == 0 {
+				discrepancy = i
+			}
+		case i == pos:
+			dir = 1
+		default:
+			dir = 0
+			discrepancy = i
+		}
+
+		if dir != 0 {
+			addr[i/8] |= 1 << uint(i%8)
+		}
+		if err = b.writeBit(dir); err != nil {
+			return addr, -1, err
+		}
+	}
+
+	return addr, discrepancy, nil
+}
+
+// Start temperature conversion on all DS18B20 sensors at once and wait for it
+func (b *Bus) ConvertAll() error {
+	if err := b.Transaction(nil, []byte{cmdConvertT}, nil); err != nil {
+		return err
+	}
+	time.Sleep(conversionTime)
+	return nil
+}
+
+// Degrees Celsius from the last conversion, see ConvertAll. Nil addr talks to the only device.
+func (b *Bus) ReadTemperature(addr *Address) (float64, error) {
+	var sp [9]byte
+	if err := b.Transaction(addr, []byte{cmdReadScratchpad}, sp[:]); err != nil {
+		return 0, err
+	}
+	if CRC8(sp[:8]) != sp[8] {
+		return 0, ErrCRC
+	}
+	return float64(int16(uint16(sp[1])<<8|uint16(sp[0]))) / 16, nil
+}
+
+// Convert and read single sensor
+func (b *Bus) Temperature(addr *Address) (float64, error) {
+	if err := b.Transaction(addr, []byte{cmdConvertT}, nil); err != nil {
+		return 0, err
+	}
+	time.Sleep(conversionTime)
+	return b.ReadTemperature(addr)
+}
@@ -0,0 +1,355 @@
+package onewire
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"sort"
+	"testing"
+	"time"
+)
+
+var errReset = errors.New("reset")
+
+type slot struct {
+	reset bool
+	bit   int
+}
+
+type device struct {
+	rom        Address
+	scratchpad [9]byte
+}
+
+// Devices on an open-drain line. Slots are told apart by how long the master held the
+// line low, the devices answer when it's released.
+type wire struct {
+	devices []*device
+	lowAt   time.Time
+	isLow   bool
+	level   int
+	slots   chan slot
+	replies chan int
+}
+
+func newWire(devices ...*device) *wire {
+	w := &wire{
+		devices: devices,
+		level:   1,
+		slots:   make(chan slot),
+		replies: make(chan int),
+	}
+	go w.run()
+	return w
+}
+
+func (w *wire) Read() (int, error) {
+	return w.level, nil
+}
+
+func (w *wire) Direction() (gpio.Direction, error) {
+	return gpio.DirOut, nil
+}
+
+// Writing 1 releases the line
+func (w *wire) Write(v int) error {
+	if v == 0 {
+		w.lowAt, w.isLow = time.Now(), true
+		return nil
+	}
+	if !w.isLow {
+		return nil
+	}
+	w.isLow = false
+
+	var s slot
+	switch d := time.Since(w.lowAt); {
+	case d > 300*time.Microsecond:
+		s.reset = true
+	case d < 55*time.Microsecond:
+		s.bit = 1
+	}
+	w.slots <- s
+	w.level = <-w.replies
+	return nil
+}
+
+func (w *wire) close() {
+	close(w.slots)
+}
+
+// exchange one slot, drive is 0 to pull the line low
+func (w *wire) xfer(drive int) (int, error) {
+	s, ok := <-w.slots
+	if !ok {
+		return 0, errors.New("closed")
+	}
+	if s.reset {
+		w.replies <- w.presence()
+		return 0, errReset
+	}
+	w.replies <- drive
+	return s.bit, nil
+}
+
+func (w *wire) presence() int {
+	if len(w.devices) != 0 {
+		return 0
+	}
+	return 1
+}
+
+func (w *wire) run() {
+	for {
+		s, ok := <-w.slots
+		if !ok {
+			return
+		}
+		if !s.reset {
+			w.replies <- 1
+			continue
+		}
+		w.replies <- w.presence()
+
+		for w.session() == errReset {
+		}
+	}
+}
+
+func (w *wire) recvByte() (byte, error) {
+	var v byte
+	for i := uint(0); i < 8; i++ {
+		bit, err := w.xfer(1)
+		if err != nil {
+			return 0, err
+		}
+		v |= byte(bit) << i
+	}
+	return v, nil
+}
+
+// wired AND of all selected devices
+func (w *wire) send(active []*device, data func(d *device) []byte, n int) error {
+	for i := 0; i < n*8; i++ {
+		drive := 1
+		for _, d := range active {
+			drive &= int(data(d)[i/8]>>uint(i%8)) & 1
+		}
+		if _, err := w.xfer(drive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bit(a Address, i int) int {
+	return int(a[i/8]>>uint(i%8)) & 1
+}
+
+// one transaction after reset, returns errReset when the next one starts
+func (w *wire) session() error {
+	active := w.devices
+
+	cmd, err := w.recvByte()
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case cmdReadROM:
+		err = w.send(active, func(d *device) []byte { return d.rom[:] }, 8)
+
+	case cmdSearchROM:
+		for i := 0; i < 64 && err == nil; i++ {
+			b, c := 1, 1
+			for _, d := range active {
+				b &= bit(d.rom, i)
+				c &= 1 - bit(d.rom, i)
+			}
+			var dir int
+			if _, err = w.xfer(b); err == nil {
+				if _, err = w.xfer(c); err == nil {
+					dir, err = w.xfer(1)
+				}
+			}
+
+			var left []*device
+			for _, d := range active {
+				if bit(d.rom, i) == dir {
+					left = append(left, d)
+				}
+			}
+			active = left
+		}
+
+	case cmdMatchROM, cmdSkipROM:
+		if cmd == cmdMatchROM {
+			var a Address
+			for i := range a {
+				if a[i], err = w.recvByte(); err != nil {
+					return err
+				}
+			}
+			var left []*device
+			for _, d := range active {
+				if d.rom == a {
+					left = append(left, d)
+				}
+			}
+			active = left
+		}
+
+		if cmd, err = w.recvByte(); err == nil && cmd == cmdReadScratchpad {
+			err = w.send(active, func(d *device) []byte { return d.scratchpad[:] }, 9)
+		}
+	}
+
+	// idle until the next reset
+	for err == nil {
+		_, err = w.xfer(1)
+	}
+	return err
+}
+
+func newDevice(serial uint64, temp int16) *device {
+	d := &device{}
+	d.rom[0] = FamilyDS18B20
+	for i := 1; i < 7; i++ {
+		d.rom[i] = byte(serial >> uint(8*(i-1)))
+	}
+	d.rom[7] = CRC8(d.rom[:7])
+
+	d.scratchpad[0], d.scratchpad[1] = byte(temp), byte(uint16(temp)>>8)
+	d.scratchpad[8] = CRC8(d.scratchpad[:8])
+	return d
+}
+
+// Slots are timed by the host spinning, a preemption can stretch one enough to be taken
+// as the wrong bit. Corrupted transfers are retried as an application would do.
+func retry(f func() error) error {
+	for i := 0; ; i++ {
+		err := f()
+		if i == 4 || (err != ErrCRC && err != ErrSearch) {
+			return err
+		}
+	}
+}
+
+func TestCRC8(t *testing.T) {
+	// Maxim application note 27 example
+	rom := []byte{0x02, 0x1c, 0xb8, 0x01, 0x00, 0x00, 0x00, 0xa2}
+	if crc := CRC8(rom[:7]); crc != 0xa2 {
+		t.Errorf("CRC8 = %#02x, want 0xa2", crc)
+	}
+	if crc := CRC8(rom); crc != 0 {
+		t.Errorf("CRC8 with CRC appended = %#02x, want 0", crc)
+	}
+}
+
+func TestAddressString(t *testing.T) {
+	a := Address{0x28, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07}
+	if s := a.String(); s != "28-060504030201" {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	devices := []*device{
+		newDevice(0x0000a1b2c3d4, 0),
+		newDevice(0x0000a1b2c3d5, 0),
+		newDevice(0x00ff00000001, 0),
+	}
+	w := newWire(devices...)
+	defer w.close()
+
+	b, err := New(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found []Address
+	err = retry(func() (err error) {
+		found, err = b.Search()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(devices) {
+		t.Fatalf("found %v", found)
+	}
+
+	var want, got []string
+	for i := range devices {
+		want = append(want, devices[i].rom.String())
+		got = append(got, found[i].String())
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("found %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestNoPresence(t *testing.T) {
+	w := newWire()
+	defer w.close()
+
+	b, _ := New(w)
+	if present, err := b.Reset(); err != nil || present {
+		t.Errorf("Reset() = %v, %v", present, err)
+	}
+	if _, err := b.Search(); err != ErrNoPresence {
+		t.Errorf("Search() error %v", err)
+	}
+}
+
+func TestReadROM(t *testing.T) {
+	d := newDevice(0x123456, 0)
+	w := newWire(d)
+	defer w.close()
+
+	b, _ := New(w)
+	var a Address
+	err := retry(func() (err error) {
+		a, err = b.ReadROM()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != d.rom || a.Family() != FamilyDS18B20 {
+		t.Errorf("got %s, want %s", a, d.rom)
+	}
+}
+
+func TestReadTemperature(t *testing.T) {
+	devices := []*device{
+		newDevice(1, 0x0191), // +25.0625
+		newDevice(2, -162),   // -10.125
+	}
+	w := newWire(devices...)
+	defer w.close()
+
+	b, _ := New(w)
+	for i, want := range []float64{25.0625, -10.125} {
+		var v float64
+		err := retry(func() (err error) {
+			v, err = b.ReadTemperature(&devices[i].rom)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != want {
+			t.Errorf("device %d: got %v, want %v", i, v, want)
+		}
+	}
+
+	// both answering at once garbles the scratchpad
+	if _, err := b.ReadTemperature(nil); err != ErrCRC {
+		t.Errorf("skip ROM with two devices: %v", err)
+	}
+}
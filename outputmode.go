@@ -0,0 +1,97 @@
+package gpio
+
+import "fmt"
+
+// Output driver type
+type OutputMode int
+
+const (
+	PushPull   OutputMode = iota
+	OpenDrain             // Drives low only, released line is pulled high externally
+	OpenSource            // Drives high only, released line is pulled low externally
+)
+
+// Pin with selectable output driver
+type OutputModeSetter interface {
+	SetOutputMode(mode OutputMode) error
+}
+
+// True if value must be driven, false if line is released
+func (m OutputMode) drives(value int) bool {
+	switch m {
+	case OpenDrain:
+		return value == 0
+	case OpenSource:
+		return value != 0
+	}
+	return true
+}
+
+// Emulated by switching the pin to input when released. Logical direction stays DirOut.
+func (pin *Pin) SetOutputMode(mode OutputMode) error {
+	if pin.ch != nil {
+		return ErrTrigger
+	}
+
+	if pin.dir != DirOut {
+		pin.outMode = mode
+		return nil
+	}
+
+	if mode != PushPull {
+		// emulated modes start released
+		pin.outMode = mode
+		return pin.SetDirection(DirOut)
+	}
+
+	// keep the current level, plain "out" would pull the line low for a moment
+	value, err := pin.read()
+	if err != nil {
+		return err
+	}
+	dirStr := "low"
+	if value != 0 {
+		dirStr = "high"
+	}
+	if err := openWriteCloseFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx), dirStr); err != nil {
+		return err
+	}
+	pin.outMode = mode
+	return nil
+}
+
+func (pin *Pin) writeEmulated(value int) error {
+	dirStr := "in"
+	if pin.outMode.drives(value) {
+		// set direction and level at once
+		dirStr = "low"
+		if value != 0 {
+			dirStr = "high"
+		}
+	}
+	return openWriteCloseFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx), dirStr)
+}
+
+func (l *Line) SetOutputMode(mode OutputMode) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var drive LineFlags
+	switch mode {
+	case OpenDrain:
+		drive = LineOpenDrain
+	case OpenSource:
+		drive = LineOpenSource
+	}
+
+	l.drive = drive
+	if l.flags&LineOutput == 0 {
+		return nil
+	}
+
+	value, err := l.read()
+	if err != nil {
+		return err
+	}
+	return l.setConfig(l.flags&^lineDriveFlags|drive, l.debounce, value)
+}
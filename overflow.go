@@ -0,0 +1,142 @@
+package gpio
+
+import "sync"
+
+// What a trigger does with events when the consumer doesn't keep up
+type OverflowPolicy int
+
+const (
+	DropNewest OverflowPolicy = iota // Discard the incoming event
+	DropOldest                       // Discard the oldest queued event
+	Block                            // Wait for the consumer. Stalls delivery for all sysfs pins.
+)
+
+const DefaultTriggerBuffer = 64
+
+type triggerConfig struct {
+	buffer   int
+	overflow OverflowPolicy
+}
+
+type TriggerOption func(*triggerConfig)
+
+// Channel capacity, DefaultTriggerBuffer if not set
+func WithBuffer(n int) TriggerOption {
+	return func(c *triggerConfig) {
+		if n > 0 {
+			c.buffer = n
+		}
+	}
+}
+
+func WithOverflow(policy OverflowPolicy) TriggerOption {
+	return func(c *triggerConfig) {
+		c.overflow = policy
+	}
+}
+
+func newTriggerConfig(opts []TriggerOption) triggerConfig {
+	cfg := triggerConfig{buffer: DefaultTriggerBuffer}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Trigger counting events lost to overflow
+type OverflowCounter interface {
+	Overflows() uint32
+}
+
+// Pushes event according to policy, returns false if the event or an older one was dropped
+func (c *triggerConfig) deliver(events chan Event, ev Event) bool {
+	switch c.overflow {
+	case Block:
+		events <- ev
+		return true
+
+	case DropOldest:
+		ok := true
+		for {
+			select {
+			case events <- ev:
+				return ok
+			default:
+				select {
+				case <-events:
+					ok = false
+				default:
+				}
+			}
+		}
+	}
+
+	if len(events) == cap(events) {
+		return false
+	}
+	events <- ev
+	return true
+}
+
+const (
+	feedIdle = iota
+	feedRunning
+	feedClosed
+)
+
+// Value channel fed from the event channel once Ch is first called, so buffering and
+// overflow policy apply to the channel actually read. Ch and EventCh share events.
+type valueFeed struct {
+	mutex sync.Mutex
+	state int
+}
+
+func (f *valueFeed) reset() {
+	f.mutex.Lock()
+	f.state = feedIdle
+	f.mutex.Unlock()
+}
+
+func (f *valueFeed) start(ch chan int, events <-chan Event) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.state != feedIdle || ch == nil {
+		return
+	}
+	f.state = feedRunning
+
+	go func() {
+		for ev := range events {
+			ch <- ev.Value
+		}
+		close(ch)
+	}()
+}
+
+// Called by the producer instead of closing ch itself
+func (f *valueFeed) close(ch chan int, events chan Event) {
+	close(events)
+
+	f.mutex.Lock()
+	if f.state == feedIdle {
+		close(ch)
+	}
+	f.state = feedClosed
+	f.mutex.Unlock()
+}
+
+// Waits for the producer to close both channels. Drained concurrently as a blocking
+// producer may be stuck on either of them.
+func drainTrigger(ch chan int, events chan Event) {
+	done := make(chan struct{})
+	go func() {
+		for range events {
+		}
+		close(done)
+	}()
+
+	for range ch {
+	}
+	<-done
+}
@@ -0,0 +1,152 @@
+package gpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTriggerOptions(t *testing.T) {
+	tests := []struct {
+		opts []TriggerOption
+		want triggerConfig
+	}{
+		{nil, triggerConfig{buffer: DefaultTriggerBuffer, overflow: DropNewest}},
+		{[]TriggerOption{WithBuffer(8)}, triggerConfig{buffer: 8}},
+		{[]TriggerOption{WithBuffer(0)}, triggerConfig{buffer: DefaultTriggerBuffer}},
+		{[]TriggerOption{WithBuffer(-1), WithOverflow(Block)}, triggerConfig{buffer: DefaultTriggerBuffer, overflow: Block}},
+		{[]TriggerOption{WithOverflow(DropOldest), WithBuffer(1)}, triggerConfig{buffer: 1, overflow: DropOldest}},
+	}
+
+	for i, tt := range tests {
+		if got := newTriggerConfig(tt.opts); got != tt.want {
+			t.Errorf("%d: got %+v, want %+v", i, got, tt.want)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		kept   []int
+		ok     []bool
+	}{
+		{DropNewest, []int{1, 2, 3}, []bool{true, true, true, false, false}},
+		{DropOldest, []int{3, 4, 5}, []bool{true, true, true, false, false}},
+	}
+
+	for _, tt := range tests {
+		cfg := triggerConfig{buffer: 3, overflow: tt.policy}
+		events := make(chan Event, cfg.buffer)
+
+		for i := 1; i <= 5; i++ {
+			if ok := cfg.deliver(events, Event{Value: i}); ok != tt.ok[i-1] {
+				t.Errorf("policy %d, event %d: got %v, want %v", tt.policy, i, ok, tt.ok[i-1])
+			}
+		}
+
+		close(events)
+		var kept []int
+		for ev := range events {
+			kept = append(kept, ev.Value)
+		}
+		if len(kept) != len(tt.kept) {
+			t.Errorf("policy %d: got %v, want %v", tt.policy, kept, tt.kept)
+			continue
+		}
+		for i := range kept {
+			if kept[i] != tt.kept[i] {
+				t.Errorf("policy %d: got %v, want %v", tt.policy, kept, tt.kept)
+				break
+			}
+		}
+	}
+}
+
+func TestDeliverBlock(t *testing.T) {
+	cfg := triggerConfig{buffer: 1, overflow: Block}
+	events := make(chan Event, cfg.buffer)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 5; i++ {
+			if !cfg.deliver(events, Event{Value: i}) {
+				t.Errorf("event %d dropped", i)
+			}
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("producer didn't block on full buffer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	for i := 1; i <= 5; i++ {
+		if ev := <-events; ev.Value != i {
+			t.Errorf("got %d, want %d", ev.Value, i)
+		}
+	}
+	<-done
+}
+
+func TestValueFeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		started bool
+		values  []int
+	}{
+		{"idle", false, nil},
+		{"running", true, []int{1, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		var f valueFeed
+		ch := make(chan int, 8)
+		events := make(chan Event, 8)
+
+		if tt.started {
+			f.start(ch, events)
+			f.start(ch, events) // no second forwarder
+		}
+		for _, v := range tt.values {
+			events <- Event{Value: v}
+		}
+		f.close(ch, events)
+		// Ch after close must not start a forwarder on the closed channel
+		f.start(ch, events)
+
+		var got []int
+		for v := range ch {
+			got = append(got, v)
+		}
+		if len(got) != len(tt.values) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.values)
+		}
+	}
+}
+
+func TestDrainTrigger(t *testing.T) {
+	ch := make(chan int)
+	events := make(chan Event)
+
+	// blocked producer on either channel is released
+	go func() {
+		events <- Event{Value: 1}
+		ch <- 1
+		close(events)
+		close(ch)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		drainTrigger(ch, events)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("drain stuck")
+	}
+}
@@ -0,0 +1,82 @@
+package gpio
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Opens a pin given the part of specifier following the backend name
+type OpenFunc func(arg string) (PinReader, error)
+
+var ErrSpec = errors.New("Invalid pin specifier")
+
+var (
+	backends     = make(map[string]OpenFunc)
+	backendMutex sync.RWMutex
+)
+
+// Register pin specifier prefix, i.e. "mcp23017" for "mcp23017@0x20:PB3" which is registered
+// by importing the expander package. Prefixes are case insensitive.
+func RegisterBackend(prefix string, open OpenFunc) {
+	backendMutex.Lock()
+	backends[strings.ToLower(prefix)] = open
+	backendMutex.Unlock()
+}
+
+// Opens pin by specifier like "GPIO17", "BOARD11" or any registered backend prefix followed
+// by backend specific argument. Longest matching prefix wins.
+func Parse(spec string) (PinReader, error) {
+	lspec := strings.ToLower(spec)
+
+	backendMutex.RLock()
+	var (
+		prefix string
+		open   OpenFunc
+	)
+	for p, fn := range backends {
+		if strings.HasPrefix(lspec, p) && len(p) > len(prefix) {
+			prefix = p
+			open = fn
+		}
+	}
+	backendMutex.RUnlock()
+
+	if open == nil {
+		return nil, ErrSpec
+	}
+
+	return open(spec[len(prefix):])
+}
+
+func openSysfs(arg string) (PinReader, error) {
+	num, err := strconv.ParseUint(arg, 10, 16)
+	if err != nil {
+		return nil, ErrSpec
+	}
+	return NewPin(int(num))
+}
+
+func openBoard(arg string) (PinReader, error) {
+	num, err := strconv.ParseUint(arg, 10, 8)
+	if err != nil {
+		return nil, ErrSpec
+	}
+
+	board, err := BoardInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := board.HeaderPin(int(num))
+	if idx < 0 {
+		return nil, ErrInvalid
+	}
+	return NewPin(idx)
+}
+
+func init() {
+	RegisterBackend("GPIO", openSysfs)
+	RegisterBackend("BOARD", openBoard)
+}
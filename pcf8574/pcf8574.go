@@ -0,0 +1,376 @@
+package pcf8574
+
+import (
+	"errors"
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"github.com/e-asphyx/gpio/i2c"
+	"sync"
+	"time"
+)
+
+// Base addresses, A2-A0 are added
+const (
+	Addr  = 0x20 // PCF8574
+	AddrA = 0x38 // PCF8574A
+)
+
+var (
+	ErrPin       = errors.New("Invalid pin number")
+	ErrInterrupt = errors.New("Interrupt pin not set")
+)
+
+// PCF8574/PCF8574A quasi-bidirectional 8 bit expander. Inputs are pins latched high,
+// which the chip holds with a weak pull-up only.
+type PCF8574 struct {
+	dev i2c.Device
+
+	mutex    sync.Mutex
+	latch    byte
+	valid    bool // latch matches the chip
+	inputs   byte
+	irq      gpio.PinReadTrigger
+	irqTr    gpio.PinTrigger
+	triggers map[int]*pcfTrigger
+	last     byte
+}
+
+type Pin struct {
+	dev *PCF8574
+	num int
+}
+
+type pcfTrigger struct {
+	pin    *Pin
+	edge   gpio.Trigger
+	ch     chan int
+	events chan gpio.Event
+	seq    uint64
+}
+
+// All pins are released high (inputs) as after power-on
+func New(bus i2c.Bus, addr uint16) (*PCF8574, error) {
+	p := &PCF8574{
+		dev:      i2c.Device{Bus: bus, Addr: addr},
+		latch:    0xff,
+		inputs:   0xff,
+		triggers: make(map[int]*pcfTrigger),
+	}
+
+	if err := p.dev.Write([]byte{p.latch}); err != nil {
+		return nil, err
+	}
+	p.valid = true
+	return p, nil
+}
+
+func (p *PCF8574) read() (byte, error) {
+	var buf [1]byte
+	err := p.dev.Read(buf[:])
+	return buf[0], err
+}
+
+// must be called with mutex held
+func (p *PCF8574) setLatch(latch byte) error {
+	if p.valid && latch == p.latch {
+		return nil
+	}
+
+	if err := p.dev.Write([]byte{latch}); err != nil {
+		p.valid = false
+		return err
+	}
+	p.latch = latch
+	p.valid = true
+	return nil
+}
+
+// Power-on reset releases all pins high, so an output latched low reading back high means the
+// chip was reset. The latch is rewritten then. Returns true if reset was detected.
+func (p *PCF8574) CheckReset() (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	low := ^p.latch &^ p.inputs
+	if low == 0 {
+		// nothing to lose, reset state is the same
+		return false, nil
+	}
+
+	v, err := p.read()
+	if err != nil {
+		return false, err
+	}
+	if v&low == 0 {
+		return false, nil
+	}
+
+	p.valid = false
+	if err = p.setLatch(p.latch); err != nil {
+		return true, err
+	}
+
+	cur, err := p.read()
+	if err != nil {
+		return true, err
+	}
+	p.last = cur
+	return true, nil
+}
+
+// Force the next write, e.g. after the chip was power cycled and released all pins
+func (p *PCF8574) Invalidate() {
+	p.mutex.Lock()
+	p.valid = false
+	p.mutex.Unlock()
+}
+
+// Host input wired to INT. Required for triggers.
+func (p *PCF8574) SetInterrupt(pin gpio.PinReadTrigger) {
+	p.mutex.Lock()
+	p.irq = pin
+	p.mutex.Unlock()
+}
+
+func (p *PCF8574) Pin(num int) (*Pin, error) {
+	if num < 0 || num > 7 {
+		return nil, ErrPin
+	}
+	return &Pin{dev: p, num: num}, nil
+}
+
+// Read all pins at once
+func (p *PCF8574) ReadAll() (byte, error) {
+	return p.read()
+}
+
+// Write all latches at once. Pins configured as inputs stay high.
+func (p *PCF8574) WriteAll(value byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	value |= p.inputs
+	if gpio.DryRun() {
+		for i := 0; i < 8; i++ {
+			gpio.RecordDryRunWrite(p.pinName(i), int(value>>uint(i))&1)
+		}
+		return nil
+	}
+	return p.setLatch(value)
+}
+
+func (p *PCF8574) serve(tr gpio.PinTrigger) {
+	for range tr.Ch() {
+		now := time.Now()
+		p.mutex.Lock()
+
+		// reading releases INT
+		cur, err := p.read()
+		if err != nil {
+			p.mutex.Unlock()
+			continue
+		}
+
+		changed := cur ^ p.last
+		p.last = cur
+
+		for num, t := range p.triggers {
+			if changed&(1<<uint(num)) == 0 {
+				continue
+			}
+
+			val := int(cur>>uint(num)) & 1
+			if (t.edge == gpio.EdgeRising && val == 0) || (t.edge == gpio.EdgeFalling && val == 1) {
+				continue
+			}
+
+			t.seq++
+			if len(t.ch) != cap(t.ch) {
+				t.ch <- val
+			}
+			if len(t.events) != cap(t.events) {
+				t.events <- gpio.Event{Value: val, Timestamp: now, Seq: t.seq}
+			}
+		}
+
+		p.mutex.Unlock()
+	}
+}
+
+func (pin *Pin) Num() int {
+	return pin.num
+}
+
+// Outputs return the latch without bus access
+func (pin *Pin) Read() (int, error) {
+	p := pin.dev
+	bit := byte(1) << uint(pin.num)
+
+	p.mutex.Lock()
+	if p.inputs&bit == 0 {
+		v := p.latch & bit
+		p.mutex.Unlock()
+		return int(v >> uint(pin.num)), nil
+	}
+	p.mutex.Unlock()
+
+	return pin.ReadBack()
+}
+
+// Force the next write. Implements gpio.Invalidator.
+func (pin *Pin) Invalidate() {
+	pin.dev.Invalidate()
+}
+
+// Actual pin level, bypassing the latch. Implements gpio.ReadBacker.
+func (pin *Pin) ReadBack() (int, error) {
+	v, err := pin.dev.read()
+	if err != nil {
+		return 0, err
+	}
+	return int(v>>uint(pin.num)) & 1, nil
+}
+
+func (p *PCF8574) pinName(num int) string {
+	return fmt.Sprintf("pcf8574@0x%02x:%d", p.dev.Addr, num)
+}
+
+func (pin *Pin) String() string {
+	return pin.dev.pinName(pin.num)
+}
+
+func (pin *Pin) Write(value int) error {
+	p := pin.dev
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	bit := byte(1) << uint(pin.num)
+	if p.inputs&bit != 0 {
+		return gpio.ErrDirIn
+	}
+
+	if gpio.DryRun() {
+		gpio.RecordDryRunWrite(pin.String(), value)
+		return nil
+	}
+
+	if value != 0 {
+		return p.setLatch(p.latch | bit)
+	}
+	return p.setLatch(p.latch &^ bit)
+}
+
+func (pin *Pin) Direction() (gpio.Direction, error) {
+	p := pin.dev
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.inputs&(1<<uint(pin.num)) != 0 {
+		return gpio.DirIn, nil
+	}
+	return gpio.DirOut, nil
+}
+
+// Switching to input releases the pin high, outputs keep the current latch
+func (pin *Pin) SetDirection(dir gpio.Direction) error {
+	p := pin.dev
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	bit := byte(1) << uint(pin.num)
+	if dir == gpio.DirOut {
+		p.inputs &^= bit
+		return nil
+	}
+
+	p.inputs |= bit
+	return p.setLatch(p.latch | bit)
+}
+
+// Interrupt on change, requires the interrupt pin
+func (pin *Pin) Trigger(edge gpio.Trigger) (gpio.PinTrigger, error) {
+	p := pin.dev
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.irq == nil {
+		return nil, ErrInterrupt
+	}
+	if _, ok := p.triggers[pin.num]; ok {
+		return nil, gpio.ErrTrigger
+	}
+
+	bit := byte(1) << uint(pin.num)
+	p.inputs |= bit
+	if err := p.setLatch(p.latch | bit); err != nil {
+		return nil, err
+	}
+
+	if p.irqTr == nil {
+		// INT is active low
+		tr, err := p.irq.Trigger(gpio.EdgeFalling)
+		if err != nil {
+			return nil, err
+		}
+		p.irqTr = tr
+		go p.serve(tr)
+	}
+
+	cur, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+	p.last = cur
+
+	t := &pcfTrigger{
+		pin:    pin,
+		edge:   edge,
+		ch:     make(chan int, 64),
+		events: make(chan gpio.Event, 64),
+	}
+	p.triggers[pin.num] = t
+
+	return t, nil
+}
+
+func (pin *Pin) TriggerWithDebounce(edge gpio.Trigger, interval time.Duration) (gpio.PinTrigger, error) {
+	return gpio.NewDebounceWithInterval(pin, edge, interval)
+}
+
+func (t *pcfTrigger) Ch() <-chan int {
+	return t.ch
+}
+
+func (t *pcfTrigger) EventCh() <-chan gpio.Event {
+	return t.events
+}
+
+func (t *pcfTrigger) Trigger() gpio.Trigger {
+	return t.edge
+}
+
+func (t *pcfTrigger) Close() error {
+	p := t.pin.dev
+	p.mutex.Lock()
+
+	if p.triggers[t.pin.num] != t {
+		p.mutex.Unlock()
+		return gpio.ErrInvalid
+	}
+
+	delete(p.triggers, t.pin.num)
+	close(t.ch)
+	close(t.events)
+
+	var irqTr gpio.PinTrigger
+	if len(p.triggers) == 0 {
+		irqTr = p.irqTr
+		p.irqTr = nil
+	}
+	p.mutex.Unlock()
+
+	if irqTr != nil {
+		return irqTr.Close()
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package pcf8574
+
+import (
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Quasi-bidirectional port: pins read high unless latched low or pulled low externally
+type fakeBus struct {
+	mutex  sync.Mutex
+	latch  byte
+	low    byte // externally driven low
+	writes []byte
+}
+
+func (b *fakeBus) WriteRead(addr uint16, w, r []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, v := range w {
+		b.latch = v
+		b.writes = append(b.writes, v)
+	}
+	for i := range r {
+		r[i] = b.latch &^ b.low
+	}
+	return nil
+}
+
+func (b *fakeBus) pull(low byte) {
+	b.mutex.Lock()
+	b.low = low
+	b.mutex.Unlock()
+}
+
+// INT line fed by the test. Sends complete only once the previous interrupt is served.
+type fakeIRQ struct {
+	tr irqTrigger
+}
+
+type irqTrigger struct {
+	ch     chan int
+	events chan gpio.Event
+	once   sync.Once
+}
+
+func (f *fakeIRQ) Read() (int, error) { return 1, nil }
+
+func (f *fakeIRQ) Trigger(edge gpio.Trigger) (gpio.PinTrigger, error) { return &f.tr, nil }
+
+func (f *fakeIRQ) TriggerWithDebounce(edge gpio.Trigger, interval time.Duration) (gpio.PinTrigger, error) {
+	return &f.tr, nil
+}
+
+func (t *irqTrigger) Ch() <-chan int             { return t.ch }
+func (t *irqTrigger) EventCh() <-chan gpio.Event { return t.events }
+func (t *irqTrigger) Trigger() gpio.Trigger      { return gpio.EdgeFalling }
+
+func (t *irqTrigger) Close() error {
+	t.once.Do(func() {
+		close(t.ch)
+		close(t.events)
+	})
+	return nil
+}
+
+func newTestPCF(t *testing.T) (*PCF8574, *fakeBus) {
+	bus := &fakeBus{}
+	p, err := New(bus, Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bus.latch != 0xff {
+		t.Fatalf("latch = %#x after New", bus.latch)
+	}
+	bus.writes = nil
+	return p, bus
+}
+
+func TestWrite(t *testing.T) {
+	p, bus := newTestPCF(t)
+
+	pin, _ := p.Pin(2)
+	if err := pin.Write(0); err != gpio.ErrDirIn {
+		t.Fatalf("input write error %v", err)
+	}
+
+	pin.SetDirection(gpio.DirOut)
+	for _, v := range []int{0, 0, 1, 1} {
+		if err := pin.Write(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(bus.writes) != 2 || bus.writes[0] != 0xfb || bus.writes[1] != 0xff {
+		t.Errorf("got writes % x", bus.writes)
+	}
+
+	// inputs stay released
+	bus.writes = nil
+	if err := p.WriteAll(0x00); err != nil {
+		t.Fatal(err)
+	}
+	if bus.latch != 0xfb {
+		t.Errorf("latch = %#x, want 0xfb", bus.latch)
+	}
+
+	// output level from the latch, input from the bus
+	bus.pull(0x06)
+	if v, _ := pin.Read(); v != 0 {
+		t.Errorf("output Read() = %d", v)
+	}
+	in, _ := p.Pin(1)
+	if v, _ := in.Read(); v != 0 {
+		t.Errorf("input Read() = %d", v)
+	}
+
+	if _, err := p.Pin(8); err != ErrPin {
+		t.Errorf("Pin(8) error %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	p, bus := newTestPCF(t)
+
+	gpio.ClearDryRunWrites()
+	gpio.SetDryRun(true)
+	defer func() {
+		gpio.SetDryRun(false)
+		gpio.ClearDryRunWrites()
+	}()
+
+	out, _ := p.Pin(0)
+	out.SetDirection(gpio.DirOut)
+	in, _ := p.Pin(1)
+
+	if err := in.Write(0); err != gpio.ErrDirIn {
+		t.Errorf("input write error %v", err)
+	}
+	if err := out.Write(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WriteAll(0x00); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bus.writes) != 0 || bus.latch != 0xff {
+		t.Errorf("bus written: % x", bus.writes)
+	}
+
+	r := gpio.DryRunWrites()
+	if len(r) != 9 {
+		t.Fatalf("got %d records, want 9", len(r))
+	}
+	if r[0].Pin != "pcf8574@0x20:0" || r[0].Value != 0 {
+		t.Errorf("got %+v", r[0])
+	}
+	// only pin 0 is an output, the rest is recorded released
+	if r[1].Value != 0 || r[2].Pin != "pcf8574@0x20:1" || r[2].Value != 1 {
+		t.Errorf("got %+v", r[1:3])
+	}
+}
+
+func TestCheckReset(t *testing.T) {
+	p, bus := newTestPCF(t)
+
+	if reset, err := p.CheckReset(); err != nil || reset {
+		t.Fatalf("CheckReset() = %v, %v", reset, err)
+	}
+
+	pin, _ := p.Pin(5)
+	pin.SetDirection(gpio.DirOut)
+	pin.Write(0)
+	if reset, err := p.CheckReset(); err != nil || reset {
+		t.Fatalf("CheckReset() = %v, %v", reset, err)
+	}
+
+	// power-on releases everything
+	bus.WriteRead(Addr, []byte{0xff}, nil)
+	reset, err := p.CheckReset()
+	if err != nil || !reset {
+		t.Fatalf("CheckReset() = %v, %v", reset, err)
+	}
+	if bus.latch != 0xdf {
+		t.Errorf("latch = %#x, want 0xdf", bus.latch)
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	p, bus := newTestPCF(t)
+	pin, _ := p.Pin(3)
+
+	if _, err := pin.Trigger(gpio.EdgeFalling); err != ErrInterrupt {
+		t.Fatalf("got %v, want ErrInterrupt", err)
+	}
+
+	irq := &fakeIRQ{tr: irqTrigger{ch: make(chan int), events: make(chan gpio.Event)}}
+	p.SetInterrupt(irq)
+	defer irq.tr.Close()
+
+	tr, err := pin.Trigger(gpio.EdgeFalling)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pin.Trigger(gpio.EdgeFalling); err != gpio.ErrTrigger {
+		t.Errorf("second trigger error %v", err)
+	}
+
+	// unrelated pin, then pin 3 going low
+	bus.pull(0x01)
+	irq.tr.ch <- 0
+	bus.pull(0x09)
+	irq.tr.ch <- 0
+
+	select {
+	case ev := <-tr.EventCh():
+		if ev.Value != 0 || ev.Seq != 1 {
+			t.Errorf("got %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+
+	if err = tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Close(); err != gpio.ErrInvalid {
+		t.Errorf("second Close() error %v", err)
+	}
+}
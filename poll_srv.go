@@ -1,9 +1,15 @@
 package gpio
 
 import (
+	"errors"
 	"golang.org/x/sys/unix"
 	"log"
+	"math"
 	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type epollServer struct {
@@ -12,11 +18,39 @@ type epollServer struct {
 	fd       *os.File
 	add      chan *Pin
 	remove   chan *Pin
+	done     chan struct{} // closed when the loop stopped on fatal error
 }
 
 const maxEvents = 64
 
-var epollSrv *epollServer
+var (
+	epollSrv   *epollServer
+	epollMutex sync.Mutex
+)
+
+var ErrEventLoop = errors.New("Event loop stopped")
+
+// Event loop is started on first use so merely importing the package never fails.
+// It is restarted if it has stopped on error.
+func getEpollServer() (*epollServer, error) {
+	epollMutex.Lock()
+	defer epollMutex.Unlock()
+
+	if epollSrv != nil {
+		select {
+		case <-epollSrv.done:
+		default:
+			return epollSrv, nil
+		}
+	}
+
+	srv, err := newEpollServer()
+	if err != nil {
+		return nil, err
+	}
+	epollSrv = srv
+	return srv, nil
+}
 
 func newEpollServer() (srv *epollServer, err error) {
 	srv = new(epollServer)
@@ -44,6 +78,7 @@ func newEpollServer() (srv *epollServer, err error) {
 
 	srv.add = make(chan *Pin, 1)
 	srv.remove = make(chan *Pin, 1)
+	srv.done = make(chan struct{})
 
 	go srv.serve()
 	return srv, nil
@@ -51,23 +86,47 @@ func newEpollServer() (srv *epollServer, err error) {
 
 func (srv *epollServer) addPin(pin *Pin) error {
 	var buf [1]byte
-	srv.add <- pin
+	select {
+	case srv.add <- pin:
+	case <-srv.done:
+		return ErrEventLoop
+	}
 	_, err := srv.wakeup_w.Write(buf[:])
 	return err
 }
 
 func (srv *epollServer) deletePin(pin *Pin) error {
 	var buf [1]byte
-	srv.remove <- pin
+	select {
+	case srv.remove <- pin:
+	case <-srv.done:
+		return ErrEventLoop
+	}
 	_, err := srv.wakeup_w.Write(buf[:])
 	return err
 }
 
+// Ends event delivery of all pins, the loop is replaced on next use
+func (srv *epollServer) shutdown(pins map[int32]*Pin, err error) {
+	log.Println(err)
+
+	for _, pin := range pins {
+		pin.fail(err)
+	}
+	for len(srv.add) != 0 {
+		(<-srv.add).fail(err)
+	}
+
+	close(srv.done)
+	srv.fd.Close()
+	srv.wakeup_r.Close()
+	srv.wakeup_w.Close()
+}
+
 func (srv *epollServer) serve() {
 	pins := make(map[int32]*Pin)
 	events := make([]unix.EpollEvent, maxEvents)
 
-	defer srv.fd.Close()
 	for {
 		nfds, err := unix.EpollWait(int(srv.fd.Fd()), events, -1)
 		if err != nil {
@@ -75,29 +134,45 @@ func (srv *epollServer) serve() {
 				continue
 			}
 
-			log.Println(err)
+			srv.shutdown(pins, err)
 			return
 		}
+		now := time.Now()
+
+		// service high priority pins first
+		if nfds > 1 {
+			wakeupFd := int32(srv.wakeup_r.Fd())
+			prio := func(fd int32) int32 {
+				if fd == wakeupFd {
+					return math.MaxInt32
+				}
+				if pin, ok := pins[fd]; ok {
+					return atomic.LoadInt32(&pin.priority)
+				}
+				return math.MinInt32
+			}
+			sort.SliceStable(events[:nfds], func(i, j int) bool {
+				return prio(events[i].Fd) > prio(events[j].Fd)
+			})
+		}
 
 		for n := 0; n < nfds; n++ {
 			if events[n].Fd == int32(srv.wakeup_r.Fd()) {
 				var buf [1]byte
 				_, err = srv.wakeup_r.Read(buf[:])
 				if err != nil {
-					log.Println(err)
+					srv.shutdown(pins, err)
 					return
 				}
 
 				for len(srv.add) != 0 {
 					pin := <-srv.add
-					fd := pin.fd.Fd()
+					fd := pin.file().Fd()
 
 					if _, ok := pins[int32(fd)]; ok {
 						continue
 					}
 
-					pins[int32(fd)] = pin
-
 					evt := unix.EpollEvent{
 						Events: unix.EPOLLPRI | unix.EPOLLERR,
 						Fd:     int32(fd),
@@ -105,14 +180,17 @@ func (srv *epollServer) serve() {
 
 					err = unix.EpollCtl(int(srv.fd.Fd()), unix.EPOLL_CTL_ADD, int(fd), &evt)
 					if err != nil {
-						log.Println(err)
-						return
+						// only this pin is affected
+						pin.fail(err)
+						continue
 					}
+
+					pins[int32(fd)] = pin
 				}
 
 				for len(srv.remove) != 0 {
 					p := <-srv.remove
-					fd := p.fd.Fd()
+					fd := p.file().Fd()
 
 					var (
 						pin *Pin
@@ -124,32 +202,49 @@ func (srv *epollServer) serve() {
 
 					err = unix.EpollCtl(int(srv.fd.Fd()), unix.EPOLL_CTL_DEL, int(fd), &unix.EpollEvent{})
 					if err != nil {
-						return
+						log.Println(err)
 					}
 
 					delete(pins, int32(fd))
-					close(pin.ch)
+					pin.feed.close(pin.ch, pin.events)
 				}
 
 			} else if pin, ok := pins[events[n].Fd]; ok {
 				val, err := pin.read()
 				if err != nil {
-					log.Println(err)
-					return
+					// device is gone, keep serving other pins
+					unix.EpollCtl(int(srv.fd.Fd()), unix.EPOLL_CTL_DEL, int(events[n].Fd), &unix.EpollEvent{})
+					delete(pins, events[n].Fd)
+					pin.reportErr(err)
+					pin.removed(err)
+					continue
 				}
 
-				if len(pin.ch) != cap(pin.ch) {
-					pin.ch <- val
+				if pin.hook != nil {
+					pin.hook(val)
+				}
+
+				pin.seq++
+				ev := Event{Value: val, Timestamp: now, Seq: pin.seq}
+				pin.history.push(ev)
+
+				if !pin.cfg.deliver(pin.events, ev) {
+					atomic.AddUint32(&pin.overflows, 1)
 				}
 			}
 		}
 	}
 }
 
-func init() {
-	var err error
-	epollSrv, err = newEpollServer()
-	if err != nil {
-		log.Fatal(err)
+func (pin *Pin) reportErr(err error) {
+	select {
+	case pin.errs <- err:
+	default:
 	}
 }
+
+// Ends delivery of this pin only
+func (pin *Pin) fail(err error) {
+	pin.reportErr(err)
+	pin.feed.close(pin.ch, pin.events)
+}
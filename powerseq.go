@@ -0,0 +1,214 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Power sequence step. Drives Out to Value and holds it for Hold, or waits up to Timeout
+// for In to read Value if In is set.
+type PowerStep struct {
+	Out   PinWriter
+	Value int
+	Hold  time.Duration
+
+	In      PinReader
+	Timeout time.Duration
+}
+
+// Executes power on/off pulse sequences of modules like cellular modems.
+// Status is optional and used to skip sequences which would toggle power the wrong way.
+type PowerSequencer struct {
+	On       []PowerStep
+	Off      []PowerStep
+	Status   PinReader
+	StatusOn int
+}
+
+var ErrPowerTimeout = errors.New("Timeout waiting for power status")
+
+// Drive pin and hold
+func Drive(pin PinWriter, value int, hold time.Duration) PowerStep {
+	return PowerStep{Out: pin, Value: value, Hold: hold}
+}
+
+// Just wait
+func Delay(d time.Duration) PowerStep {
+	return PowerStep{Hold: d}
+}
+
+// Wait for pin level
+func WaitFor(pin PinReader, value int, timeout time.Duration) PowerStep {
+	return PowerStep{In: pin, Value: value, Timeout: timeout}
+}
+
+// SIM800 and alike: PWRKEY pulse of 1.2s turns module on, 1.5s turns it off, STATUS reflects the state.
+// keyActive is the level asserting PWRKEY at the host pin, it's 1 for most HATs driving the key
+// through a transistor.
+func SIM800(pwrkey PinWriter, keyActive int, status PinReader) *PowerSequencer {
+	idle := keyActive ^ 1
+	return &PowerSequencer{
+		On: []PowerStep{
+			Drive(pwrkey, keyActive, 1200*time.Millisecond),
+			Drive(pwrkey, idle, 0),
+			WaitFor(status, 1, 5*time.Second),
+		},
+		Off: []PowerStep{
+			Drive(pwrkey, keyActive, 1500*time.Millisecond),
+			Drive(pwrkey, idle, 0),
+			WaitFor(status, 0, 10*time.Second),
+		},
+		Status:   status,
+		StatusOn: 1,
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Waits using trigger if pin supports it and polls otherwise
+func waitLevel(ctx context.Context, pin PinReader, value int, timeout time.Duration) error {
+	v, err := pin.Read()
+	if err != nil {
+		return err
+	}
+	if v == value {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if rt, ok := pin.(PinReadTrigger); ok {
+		tr, err := rt.Trigger(EdgeBoth)
+		if err != nil {
+			return err
+		}
+
+	wait:
+		for {
+			select {
+			case v, ok := <-tr.Ch():
+				if !ok {
+					break wait
+				}
+				if v == value {
+					return tr.Close()
+				}
+			case <-ctx.Done():
+				break wait
+			}
+		}
+
+		if err := tr.Close(); err != nil {
+			return err
+		}
+	} else {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+	poll:
+		for {
+			select {
+			case <-ticker.C:
+				if v, err = pin.Read(); err != nil {
+					return err
+				}
+				if v == value {
+					return nil
+				}
+			case <-ctx.Done():
+				break poll
+			}
+		}
+	}
+
+	// edge may have happened before the trigger was set up
+	if v, err = pin.Read(); err != nil {
+		return err
+	}
+	if v == value {
+		return nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrPowerTimeout
+	}
+	return ctx.Err()
+}
+
+// Execute steps in order
+func (s *PowerSequencer) Run(ctx context.Context, steps []PowerStep) error {
+	for _, step := range steps {
+		if step.In != nil {
+			if err := waitLevel(ctx, step.In, step.Value, step.Timeout); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if step.Out != nil {
+			if err := step.Out.Write(step.Value); err != nil {
+				return err
+			}
+		}
+		if err := sleepCtx(ctx, step.Hold); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reports status pin state, always true if there's no status pin
+func (s *PowerSequencer) IsOn() (bool, error) {
+	if s.Status == nil {
+		return true, nil
+	}
+	v, err := s.Status.Read()
+	if err != nil {
+		return false, err
+	}
+	return v == s.StatusOn, nil
+}
+
+// Run power on sequence unless already on
+func (s *PowerSequencer) PowerOn(ctx context.Context) error {
+	if s.Status != nil {
+		on, err := s.IsOn()
+		if err != nil {
+			return err
+		}
+		if on {
+			return nil
+		}
+	}
+	return s.Run(ctx, s.On)
+}
+
+// Run power off sequence unless already off
+func (s *PowerSequencer) PowerOff(ctx context.Context) error {
+	if s.Status != nil {
+		on, err := s.IsOn()
+		if err != nil {
+			return err
+		}
+		if !on {
+			return nil
+		}
+	}
+	return s.Run(ctx, s.Off)
+}
@@ -0,0 +1,35 @@
+package gpio
+
+// Pin with configurable pull resistor. Backends without bias control return ErrUnsupported.
+type PinConfigurer interface {
+	SetPull(pull Pull) error
+}
+
+// sysfs has no bias control
+func (pin *Pin) SetPull(pull Pull) error {
+	return ErrUnsupported
+}
+
+func (l *Line) SetPull(pull Pull) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var bias LineFlags
+	switch pull {
+	case PullUp:
+		bias = LinePullUp
+	case PullDown:
+		bias = LinePullDown
+	default:
+		bias = LineBiasDisabled
+	}
+
+	var value int
+	if l.flags&LineOutput != 0 {
+		var err error
+		if value, err = l.read(); err != nil {
+			return err
+		}
+	}
+	return l.setConfig(l.flags&^lineBiasFlags|bias, l.debounce, value)
+}
@@ -0,0 +1,52 @@
+package gpio
+
+import (
+	"runtime"
+	"time"
+)
+
+// Output level held for Duration
+type PulseSpec struct {
+	Level    int
+	Duration time.Duration
+}
+
+// Pin able to emit pulse trains with hardware assisted timing
+type PulseSender interface {
+	SendPulses(pulses []PulseSpec) error
+}
+
+// Pulse train alternating between levels starting with first, as used by IR and OOK protocols
+func Alternating(first int, durations ...time.Duration) []PulseSpec {
+	pulses := make([]PulseSpec, len(durations))
+	level := first & 1
+	for i, d := range durations {
+		pulses[i] = PulseSpec{Level: level, Duration: d}
+		level ^= 1
+	}
+	return pulses
+}
+
+// Emit pulse train. Pins implementing PulseSender do it themselves, otherwise timing is done
+// by busy waiting. Deadlines are counted from the start so errors don't accumulate.
+// The pin is left at the level of the last pulse.
+func SendPulses(pin PinWriter, pulses []PulseSpec) error {
+	if s, ok := pin.(PulseSender); ok {
+		return s.SendPulses(pulses)
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	next := time.Now()
+	for _, p := range pulses {
+		if err := pin.Write(p.Level); err != nil {
+			return err
+		}
+
+		next = next.Add(p.Duration)
+		sleepUntil(next)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// One full cycle of input signal
+type PulseMeasurement struct {
+	Width     time.Duration // high time
+	Period    time.Duration // rising to rising
+	Timestamp time.Time     // rising edge ending the cycle
+}
+
+func (m PulseMeasurement) Frequency() float64 {
+	if m.Period <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(m.Period)
+}
+
+// 0.0 to 1.0
+func (m PulseMeasurement) Duty() float64 {
+	if m.Period <= 0 {
+		return 0
+	}
+	return float64(m.Width) / float64(m.Period)
+}
+
+// Measures pulse width and period of input signal from edge timestamps. Suited for
+// tachometers and PWM feedback up to a few hundred Hz; use bcm2708.Pin.MeasurePulse for faster signals.
+type PulseMeter struct {
+	tr PinTrigger
+	ch chan PulseMeasurement
+
+	mutex sync.Mutex
+	last  PulseMeasurement
+	done  chan struct{}
+}
+
+func NewPulseMeter(pin PinReadTrigger) (*PulseMeter, error) {
+	tr, err := pin.Trigger(EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &PulseMeter{
+		tr:   tr,
+		ch:   make(chan PulseMeasurement, 64),
+		done: make(chan struct{}),
+	}
+
+	go m.run()
+	return m, nil
+}
+
+func (m *PulseMeter) run() {
+	defer close(m.done)
+	defer close(m.ch)
+
+	var rise, fall time.Time
+	for ev := range m.tr.EventCh() {
+		if ev.Value == 0 {
+			if !rise.IsZero() {
+				fall = ev.Timestamp
+			}
+			continue
+		}
+
+		// need a complete rise-fall-rise cycle
+		if !rise.IsZero() && fall.After(rise) {
+			meas := PulseMeasurement{
+				Width:     fall.Sub(rise),
+				Period:    ev.Timestamp.Sub(rise),
+				Timestamp: ev.Timestamp,
+			}
+
+			m.mutex.Lock()
+			m.last = meas
+			m.mutex.Unlock()
+
+			if len(m.ch) != cap(m.ch) {
+				m.ch <- meas
+			}
+		}
+		rise = ev.Timestamp
+	}
+}
+
+// Every complete cycle
+func (m *PulseMeter) Ch() <-chan PulseMeasurement {
+	return m.ch
+}
+
+// Latest measurement, zero if there was none
+func (m *PulseMeter) Last() PulseMeasurement {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.last
+}
+
+// True if there was no complete cycle during maxAge, e.g. stalled fan
+func (m *PulseMeter) Stale(maxAge time.Duration) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.last.Timestamp.IsZero() || time.Now().Sub(m.last.Timestamp) > maxAge
+}
+
+func (m *PulseMeter) Close() error {
+	err := m.tr.Close()
+	<-m.done
+	return err
+}
@@ -0,0 +1,304 @@
+package gpio
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Output with adjustable duty cycle
+type DutyWriter interface {
+	// 0.0 to 1.0
+	SetDuty(duty float64) error
+}
+
+// Pin with PWM generator behind it, i.e. hardware PWM channel
+type PWMPin interface {
+	PWM(freq float64) (DutyWriter, error)
+}
+
+// Requested or achieved PWM timing
+type PWMConfig struct {
+	Frequency float64 // Hz
+	Steps     int     // distinct duty levels per period
+}
+
+// Generator able to trade frequency for resolution. Frequency is kept as close as possible
+// and resolution is lowered to what the backend can achieve; the actual values are returned.
+type PWMConfigurer interface {
+	ConfigurePWM(want PWMConfig) (PWMConfig, error)
+}
+
+// Software PWM on arbitrary output
+type SoftPWM struct {
+	pin    PinWriter
+	period time.Duration
+	duty   float64
+	steps  int
+	phase  float64
+	mutex  sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+	closed sync.Once
+}
+
+const spinThreshold = time.Millisecond
+
+// Shortest pulse software PWM loop can time reliably
+var SoftPWMTick = 10 * time.Microsecond
+
+var ErrFrequency = errors.New("Invalid frequency")
+
+// Sleep with sub-millisecond precision by spinning the last part
+func sleepUntil(deadline time.Time) {
+	if d := deadline.Sub(time.Now()); d > spinThreshold {
+		time.Sleep(d - spinThreshold)
+	}
+	for time.Now().Before(deadline) {
+	}
+}
+
+func freqPeriod(freq float64) (time.Duration, error) {
+	if freq <= 0 {
+		return 0, ErrFrequency
+	}
+	return time.Duration(float64(time.Second) / freq), nil
+}
+
+// Start software PWM with zero duty
+func NewSoftPWM(pin PinWriter, freq float64) (*SoftPWM, error) {
+	period, err := freqPeriod(freq)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSoftPWM(pin, period, 0, time.Now())
+}
+
+func newSoftPWM(pin PinWriter, period time.Duration, phase float64, epoch time.Time) (*SoftPWM, error) {
+	if err := pin.Write(0); err != nil {
+		return nil, err
+	}
+
+	p := &SoftPWM{
+		pin:    pin,
+		period: period,
+		phase:  phase,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go p.run(epoch)
+	return p, nil
+}
+
+func (p *SoftPWM) run(epoch time.Time) {
+	defer close(p.done)
+
+	next := epoch
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		p.mutex.Lock()
+		period := p.period
+		on := time.Duration(float64(period) * p.duty)
+		offset := time.Duration(float64(period) * p.phase)
+		p.mutex.Unlock()
+
+		start := next
+		next = start.Add(period)
+
+		switch {
+		case on <= 0:
+			p.pin.Write(0)
+		case on >= period:
+			p.pin.Write(1)
+		case offset+on <= period:
+			if offset > 0 {
+				p.pin.Write(0)
+				sleepUntil(start.Add(offset))
+			}
+			p.pin.Write(1)
+			sleepUntil(start.Add(offset + on))
+			p.pin.Write(0)
+		default:
+			// pulse wraps around the period boundary
+			p.pin.Write(1)
+			sleepUntil(start.Add(offset + on - period))
+			p.pin.Write(0)
+			sleepUntil(start.Add(offset))
+			p.pin.Write(1)
+		}
+
+		sleepUntil(next)
+
+		// don't try to catch up after being preempted but keep the phase grid
+		if now := time.Now(); now.Sub(next) > period {
+			next = next.Add(now.Sub(next) / period * period)
+		}
+	}
+}
+
+func (p *SoftPWM) SetDuty(duty float64) error {
+	if duty < 0 {
+		duty = 0
+	} else if duty > 1 {
+		duty = 1
+	}
+
+	p.mutex.Lock()
+	if p.steps > 0 {
+		duty = float64(int(duty*float64(p.steps)+0.5)) / float64(p.steps)
+	}
+	p.duty = duty
+	p.mutex.Unlock()
+
+	return nil
+}
+
+// Duty is quantized to the achieved number of steps
+func (p *SoftPWM) ConfigurePWM(want PWMConfig) (PWMConfig, error) {
+	period, err := freqPeriod(want.Frequency)
+	if err != nil {
+		return PWMConfig{}, err
+	}
+
+	steps := int(period / SoftPWMTick)
+	if steps < 1 {
+		return PWMConfig{}, ErrFrequency
+	}
+	if want.Steps > 0 && want.Steps < steps {
+		steps = want.Steps
+	}
+
+	p.mutex.Lock()
+	p.period = period
+	p.steps = steps
+	p.mutex.Unlock()
+
+	return PWMConfig{Frequency: float64(time.Second) / float64(period), Steps: steps}, nil
+}
+
+func (p *SoftPWM) SetFrequency(freq float64) error {
+	period, err := freqPeriod(freq)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	p.period = period
+	p.steps = 0
+	p.mutex.Unlock()
+
+	return nil
+}
+
+// Delay rising edge by a fraction of period, 0.0 to 1.0
+func (p *SoftPWM) SetPhase(phase float64) {
+	if phase < 0 || phase >= 1 {
+		phase = 0
+	}
+
+	p.mutex.Lock()
+	p.phase = phase
+	p.mutex.Unlock()
+}
+
+// Stop generator and drive output low. Subsequent calls do nothing.
+func (p *SoftPWM) Close() error {
+	var err error
+	p.closed.Do(func() {
+		close(p.stop)
+		<-p.done
+		err = p.pin.Write(0)
+	})
+	return err
+}
+
+// Software PWM channels sharing one period grid with rising edges spread evenly across it
+// so outputs don't all switch at once
+type SoftPWMGroup struct {
+	channels []*SoftPWM
+}
+
+func NewSoftPWMGroup(freq float64, pins ...PinWriter) (*SoftPWMGroup, error) {
+	period, err := freqPeriod(freq)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &SoftPWMGroup{}
+	epoch := time.Now()
+	for i, pin := range pins {
+		ch, err := newSoftPWM(pin, period, float64(i)/float64(len(pins)), epoch)
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		g.channels = append(g.channels, ch)
+	}
+
+	return g, nil
+}
+
+func (g *SoftPWMGroup) Len() int {
+	return len(g.channels)
+}
+
+func (g *SoftPWMGroup) Channel(i int) *SoftPWM {
+	return g.channels[i]
+}
+
+func (g *SoftPWMGroup) Close() error {
+	var err error
+	for _, ch := range g.channels {
+		if e := ch.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Use hardware PWM if pin provides it or fall back to software one
+func NewDutyWriter(pin PinWriter, freq float64) (DutyWriter, error) {
+	if hw, ok := pin.(PWMPin); ok {
+		// not every pin of a controller is routed to a PWM channel
+		if w, err := hw.PWM(freq); err == nil {
+			return w, nil
+		}
+	}
+	return NewSoftPWM(pin, freq)
+}
+
+// Same as NewDutyWriter but negotiates resolution too. Output is left at zero duty.
+func NewDutyWriterConfig(pin PinWriter, want PWMConfig) (DutyWriter, PWMConfig, error) {
+	w, err := NewDutyWriter(pin, want.Frequency)
+	if err != nil {
+		return nil, PWMConfig{}, err
+	}
+
+	c, ok := w.(PWMConfigurer)
+	if !ok {
+		return w, PWMConfig{Frequency: want.Frequency}, nil
+	}
+
+	got, err := c.ConfigurePWM(want)
+	if err != nil {
+		if cl, ok := w.(io.Closer); ok {
+			cl.Close()
+		}
+		return nil, PWMConfig{}, err
+	}
+
+	return w, got, w.SetDuty(0)
+}
+
+// Arduino style 0-255 duty
+func AnalogWrite(w DutyWriter, value uint8) error {
+	return w.SetDuty(float64(value) / 255)
+}
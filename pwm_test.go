@@ -0,0 +1,73 @@
+package gpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoftPWMClose(t *testing.T) {
+	var pin fakePin
+
+	p, err := NewSoftPWM(&pin, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetDuty(1)
+	time.Sleep(5 * time.Millisecond)
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	if v, _ := pin.Read(); v != 0 {
+		t.Errorf("output left at %d", v)
+	}
+
+	g, err := NewSoftPWMGroup(1000, &pin, &fakePin{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+	if err := g.Close(); err != nil {
+		t.Errorf("second group close: %v", err)
+	}
+}
+
+func TestSoftPWMConfigure(t *testing.T) {
+	tests := []struct {
+		want  PWMConfig
+		got   PWMConfig
+		duty  float64
+		quant float64
+		err   error
+	}{
+		{PWMConfig{Frequency: 1000, Steps: 10}, PWMConfig{Frequency: 1000, Steps: 10}, 0.34, 0.3, nil},
+		{PWMConfig{Frequency: 1000}, PWMConfig{Frequency: 1000, Steps: 100}, 0.345, 0.35, nil},
+		{PWMConfig{Frequency: 1000, Steps: 1000}, PWMConfig{Frequency: 1000, Steps: 100}, 1.5, 1, nil},
+		{PWMConfig{Frequency: 0}, PWMConfig{}, 0, 0, ErrFrequency},
+		{PWMConfig{Frequency: 1e6}, PWMConfig{}, 0, 0, ErrFrequency},
+	}
+
+	tick := SoftPWMTick
+	defer func() { SoftPWMTick = tick }()
+	SoftPWMTick = 10 * time.Microsecond
+
+	for _, tt := range tests {
+		p := &SoftPWM{}
+		got, err := p.ConfigurePWM(tt.want)
+		if err != tt.err || got != tt.got {
+			t.Errorf("%+v: got %+v/%v, want %+v/%v", tt.want, got, err, tt.got, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		p.SetDuty(tt.duty)
+		if d := p.duty - tt.quant; d < -1e-9 || d > 1e-9 {
+			t.Errorf("%+v: duty %v quantized to %v, want %v", tt.want, tt.duty, p.duty, tt.quant)
+		}
+	}
+}
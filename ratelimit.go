@@ -0,0 +1,149 @@
+package gpio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// What to do with writes exceeding the limit
+type RatePolicy int
+
+const (
+	RateError RatePolicy = iota // Return ErrRateLimit
+	RateBlock                   // Sleep until change is allowed
+	RateDefer                   // Return immediately, apply the latest value when allowed
+)
+
+// Output protection settings. Zero values disable corresponding check.
+type RateLimit struct {
+	MaxToggles int           // Max level changes
+	Window     time.Duration // within this period
+	MinDwell   time.Duration // Min time between changes
+	Policy     RatePolicy
+}
+
+var ErrRateLimit = errors.New("Output toggle rate exceeded")
+
+type rateLimiter struct {
+	RateLimit
+	mutex   sync.Mutex
+	value   int
+	valid   bool
+	changes []time.Time
+	pending *time.Timer
+	next    int
+}
+
+// returns the earliest time a change is allowed, must be called with mutex held
+func (rl *rateLimiter) allowedAt(now time.Time) time.Time {
+	at := now
+
+	if n := len(rl.changes); n != 0 && rl.MinDwell > 0 {
+		if t := rl.changes[n-1].Add(rl.MinDwell); t.After(at) {
+			at = t
+		}
+	}
+
+	if rl.MaxToggles > 0 && rl.Window > 0 {
+		// drop changes out of window
+		i := 0
+		for i < len(rl.changes) && now.Sub(rl.changes[i]) >= rl.Window {
+			i++
+		}
+		rl.changes = rl.changes[i:]
+
+		if len(rl.changes) >= rl.MaxToggles {
+			if t := rl.changes[len(rl.changes)-rl.MaxToggles].Add(rl.Window); t.After(at) {
+				at = t
+			}
+		}
+	}
+
+	return at
+}
+
+func (rl *rateLimiter) record(value int, now time.Time) {
+	rl.value = value
+	rl.valid = true
+	rl.changes = append(rl.changes, now)
+	if max := rl.MaxToggles + 1; len(rl.changes) > max {
+		rl.changes = rl.changes[len(rl.changes)-max:]
+	}
+}
+
+func (rl *rateLimiter) write(next WriteFunc, value int) error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.pending != nil {
+		rl.pending.Stop()
+		rl.pending = nil
+	}
+
+	if rl.valid && (value != 0) == (rl.value != 0) {
+		return next(value)
+	}
+
+	now := time.Now()
+	at := now
+	if rl.valid {
+		at = rl.allowedAt(now)
+	}
+
+	if at.After(now) {
+		switch rl.Policy {
+		case RateBlock:
+			rl.mutex.Unlock()
+			time.Sleep(at.Sub(now))
+			rl.mutex.Lock()
+			now = time.Now()
+
+		case RateDefer:
+			rl.next = value
+			rl.pending = time.AfterFunc(at.Sub(now), func() {
+				rl.mutex.Lock()
+				defer rl.mutex.Unlock()
+
+				if rl.pending == nil {
+					return
+				}
+				rl.pending = nil
+				if next(rl.next) == nil {
+					rl.record(rl.next, time.Now())
+				}
+			})
+			return nil
+
+		default:
+			return ErrRateLimit
+		}
+	}
+
+	err := next(value)
+	if err != nil {
+		return err
+	}
+	rl.record(value, now)
+
+	return nil
+}
+
+// Middleware limiting level changes of an output. Writes not changing the level always pass.
+// Each call creates independent limiter state so it must not be shared between pins.
+func RateLimiter(limit RateLimit) Middleware {
+	rl := &rateLimiter{RateLimit: limit}
+
+	return Middleware{
+		Write: func(next WriteFunc) WriteFunc {
+			return func(value int) error {
+				return rl.write(next, value)
+			}
+		},
+	}
+}
+
+// Shorthand for Wrap(pin, RateLimiter(limit))
+func NewRateLimitedWriter(pin PinWriter, limit RateLimit) PinWriter {
+	return Wrap(pin, RateLimiter(limit))
+}
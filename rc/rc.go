@@ -0,0 +1,213 @@
+package rc
+
+import (
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"time"
+)
+
+const (
+	MinPulse = 1000 * time.Microsecond
+	MidPulse = 1500 * time.Microsecond
+	MaxPulse = 2000 * time.Microsecond
+
+	// Pulses outside this range are treated as noise
+	minValidPulse = 800 * time.Microsecond
+	maxValidPulse = 2200 * time.Microsecond
+
+	// CPPM frame gap
+	minSyncGap = 3000 * time.Microsecond
+
+	MaxPPMChannels = 8
+)
+
+// Channel value update
+type ChannelValue struct {
+	Channel   int
+	Pulse     time.Duration
+	Timestamp time.Time
+}
+
+// Pulse width mapped to -1.0..1.0 around center
+func (v ChannelValue) Normalized() float64 {
+	n := float64(v.Pulse-MidPulse) / float64(MaxPulse-MidPulse)
+	if n < -1 {
+		return -1
+	} else if n > 1 {
+		return 1
+	}
+	return n
+}
+
+// Decodes RC servo pulses on one pin per channel or CPPM frame on single pin
+type Receiver struct {
+	triggers []gpio.PinTrigger
+	ch       chan ChannelValue
+	wg       sync.WaitGroup
+
+	mutex  sync.Mutex
+	values []ChannelValue
+}
+
+func newReceiver(channels int) *Receiver {
+	return &Receiver{
+		ch:     make(chan ChannelValue, 64),
+		values: make([]ChannelValue, channels),
+	}
+}
+
+func (r *Receiver) deliver(v ChannelValue) {
+	r.mutex.Lock()
+	r.values[v.Channel] = v
+	r.mutex.Unlock()
+
+	if len(r.ch) != cap(r.ch) {
+		r.ch <- v
+	}
+}
+
+func (r *Receiver) start(pin gpio.PinReadTrigger, decode func(ev gpio.Event)) error {
+	tr, err := pin.Trigger(gpio.EdgeBoth)
+	if err != nil {
+		return err
+	}
+	r.triggers = append(r.triggers, tr)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for ev := range tr.EventCh() {
+			decode(ev)
+		}
+	}()
+
+	return nil
+}
+
+// Pulse width receiver, one input per channel
+func NewPWM(pins ...gpio.PinReadTrigger) (*Receiver, error) {
+	r := newReceiver(len(pins))
+
+	for i, pin := range pins {
+		i := i
+		var rise time.Time
+
+		decode := func(ev gpio.Event) {
+			if ev.Value == 1 {
+				rise = ev.Timestamp
+				return
+			}
+			if rise.IsZero() {
+				return
+			}
+
+			w := ev.Timestamp.Sub(rise)
+			rise = time.Time{}
+			if w >= minValidPulse && w <= maxValidPulse {
+				r.deliver(ChannelValue{Channel: i, Pulse: w, Timestamp: ev.Timestamp})
+			}
+		}
+
+		if err := r.start(pin, decode); err != nil {
+			r.closeTriggers()
+			return nil, err
+		}
+	}
+
+	go r.closeWhenDone()
+	return r, nil
+}
+
+// CPPM receiver. Channel values are intervals between rising edges, frames are separated by a long gap.
+func NewPPM(pin gpio.PinReadTrigger, channels int) (*Receiver, error) {
+	if channels < 1 || channels > MaxPPMChannels {
+		channels = MaxPPMChannels
+	}
+	r := newReceiver(channels)
+
+	var (
+		last    time.Time
+		channel = -1 // waiting for sync
+	)
+
+	decode := func(ev gpio.Event) {
+		if ev.Value != 1 {
+			return
+		}
+
+		prev := last
+		last = ev.Timestamp
+		if prev.IsZero() {
+			return
+		}
+
+		d := ev.Timestamp.Sub(prev)
+		switch {
+		case d >= minSyncGap:
+			channel = 0
+
+		case channel >= 0 && channel < channels && d >= minValidPulse && d <= maxValidPulse:
+			r.deliver(ChannelValue{Channel: channel, Pulse: d, Timestamp: ev.Timestamp})
+			channel++
+
+		default:
+			// glitch, resynchronize
+			channel = -1
+		}
+	}
+
+	if err := r.start(pin, decode); err != nil {
+		return nil, err
+	}
+
+	go r.closeWhenDone()
+	return r, nil
+}
+
+func (r *Receiver) closeWhenDone() {
+	r.wg.Wait()
+	close(r.ch)
+}
+
+func (r *Receiver) Ch() <-chan ChannelValue {
+	return r.ch
+}
+
+// Last value of channel, zero Pulse if nothing has been received yet
+func (r *Receiver) Value(channel int) ChannelValue {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.values[channel]
+}
+
+// Channels not updated within timeout, e.g. for failsafe
+func (r *Receiver) Stale(timeout time.Duration) []int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var res []int
+	now := time.Now()
+	for i, v := range r.values {
+		if now.Sub(v.Timestamp) > timeout {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+func (r *Receiver) Close() error {
+	err := r.closeTriggers()
+	for range r.ch {
+	}
+	return err
+}
+
+func (r *Receiver) closeTriggers() error {
+	var err error
+	for _, tr := range r.triggers {
+		if e := tr.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,242 @@
+package rc
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	sbusFrameLen     = 25
+	sbusHeader       = 0x0f
+	sbusChannels     = 16
+	sbusFlagCh17     = 1 << 0
+	sbusFlagCh18     = 1 << 1
+	sbusFlagLost     = 1 << 2
+	sbusFlagFailsafe = 1 << 3
+
+	ibusFrameLen = 32
+	ibusChannels = 14
+)
+
+var ErrFrame = errors.New("Invalid frame")
+
+// Serial RC frame
+type Frame struct {
+	Channels  []uint16
+	FrameLost bool
+	Failsafe  bool
+	Timestamp time.Time
+}
+
+// SBUS frame (100000 baud, 8E2, inverted line). 16 proportional channels plus 2 digital ones
+// reported as 0 or 2047.
+func DecodeSBUS(buf []byte) (Frame, error) {
+	if len(buf) != sbusFrameLen || buf[0] != sbusHeader {
+		return Frame{}, ErrFrame
+	}
+	// SBUS2 uses 0x04, 0x14, 0x24 and 0x34
+	if end := buf[24] & 0x0f; end != 0 && end != 0x04 {
+		return Frame{}, ErrFrame
+	}
+
+	f := Frame{Channels: make([]uint16, sbusChannels+2)}
+
+	var (
+		acc  uint32
+		bits uint
+		ch   int
+	)
+	for _, b := range buf[1:23] {
+		acc |= uint32(b) << bits
+		bits += 8
+		for bits >= 11 && ch < sbusChannels {
+			f.Channels[ch] = uint16(acc & 0x7ff)
+			acc >>= 11
+			bits -= 11
+			ch++
+		}
+	}
+
+	flags := buf[23]
+	if flags&sbusFlagCh17 != 0 {
+		f.Channels[16] = 2047
+	}
+	if flags&sbusFlagCh18 != 0 {
+		f.Channels[17] = 2047
+	}
+	f.FrameLost = flags&sbusFlagLost != 0
+	f.Failsafe = flags&sbusFlagFailsafe != 0
+
+	return f, nil
+}
+
+// Approximate servo pulse of SBUS channel value
+func SBUSPulse(v uint16) time.Duration {
+	return 880*time.Microsecond + time.Duration(v)*625*time.Nanosecond
+}
+
+// FlySky iBUS frame (115200 baud, 8N1), 14 channels in microseconds
+func DecodeIBUS(buf []byte) (Frame, error) {
+	if len(buf) != ibusFrameLen || buf[0] != 0x20 || buf[1] != 0x40 {
+		return Frame{}, ErrFrame
+	}
+
+	sum := uint16(0xffff)
+	for _, b := range buf[:30] {
+		sum -= uint16(b)
+	}
+	if sum != binary.LittleEndian.Uint16(buf[30:]) {
+		return Frame{}, ErrFrame
+	}
+
+	f := Frame{Channels: make([]uint16, ibusChannels)}
+	for i := range f.Channels {
+		f.Channels[i] = binary.LittleEndian.Uint16(buf[2+2*i:]) & 0x0fff
+	}
+	return f, nil
+}
+
+// Reads frames from UART. An optional GPIO monitoring the same line detects
+// a dead link faster than waiting for the next frame.
+type SerialReceiver struct {
+	port   io.Reader
+	size   int
+	header byte
+	decode func([]byte) (Frame, error)
+	ch     chan Frame
+
+	mutex    sync.Mutex
+	last     time.Time
+	activity time.Time
+	tr       gpio.PinTrigger
+}
+
+func newSerial(port io.Reader, size int, header byte, decode func([]byte) (Frame, error)) *SerialReceiver {
+	r := &SerialReceiver{
+		port:   port,
+		size:   size,
+		header: header,
+		decode: decode,
+		ch:     make(chan Frame, 16),
+	}
+	go r.serve()
+	return r
+}
+
+// Port must be set up for 100000 baud 8E2 with the line inverted in hardware
+func NewSBUS(port io.Reader) *SerialReceiver {
+	return newSerial(port, sbusFrameLen, sbusHeader, DecodeSBUS)
+}
+
+// Port must be set up for 115200 baud 8N1
+func NewIBUS(port io.Reader) *SerialReceiver {
+	return newSerial(port, ibusFrameLen, 0x20, DecodeIBUS)
+}
+
+func (r *SerialReceiver) serve() {
+	defer close(r.ch)
+
+	var (
+		frame []byte
+		buf   [64]byte
+	)
+
+	for {
+		n, err := r.port.Read(buf[:])
+		for _, b := range buf[:n] {
+			if len(frame) == 0 && b != r.header {
+				continue
+			}
+			frame = append(frame, b)
+			if len(frame) < r.size {
+				continue
+			}
+
+			f, e := r.decode(frame)
+			if e != nil {
+				// resynchronize on the next header byte
+				i := 1
+				for i < len(frame) && frame[i] != r.header {
+					i++
+				}
+				frame = append(frame[:0], frame[i:]...)
+				continue
+			}
+			frame = frame[:0]
+
+			f.Timestamp = time.Now()
+			r.mutex.Lock()
+			r.last = f.Timestamp
+			r.mutex.Unlock()
+
+			if len(r.ch) != cap(r.ch) {
+				r.ch <- f
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Watch line activity on a GPIO wired to the receiver output
+func (r *SerialReceiver) SetActivityPin(pin gpio.PinReadTrigger) error {
+	tr, err := pin.Trigger(gpio.EdgeFalling)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	old := r.tr
+	r.tr = tr
+	r.mutex.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	go func() {
+		for ev := range tr.EventCh() {
+			r.mutex.Lock()
+			r.activity = ev.Timestamp
+			r.mutex.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// True if neither a valid frame nor (with activity pin) any line activity was seen within timeout
+func (r *SerialReceiver) Failsafe(timeout time.Duration) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if r.tr != nil && now.Sub(r.activity) > timeout {
+		return true
+	}
+	return now.Sub(r.last) > timeout
+}
+
+// Closed when the port returns an error
+func (r *SerialReceiver) Ch() <-chan Frame {
+	return r.ch
+}
+
+// Stop activity monitoring. The port is owned by the caller, closing it stops the receiver.
+func (r *SerialReceiver) Close() error {
+	r.mutex.Lock()
+	tr := r.tr
+	r.tr = nil
+	r.mutex.Unlock()
+
+	if tr != nil {
+		return tr.Close()
+	}
+	return nil
+}
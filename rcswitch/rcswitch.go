@@ -0,0 +1,253 @@
+package rcswitch
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"time"
+)
+
+// OOK line coding. Sync, Zero and One are (high, low) durations in units of Pulse.
+// Inverted protocols transmit low instead of high and vice versa.
+type Protocol struct {
+	Name     string
+	Pulse    time.Duration
+	Sync     [2]int
+	Zero     [2]int
+	One      [2]int
+	Inverted bool
+}
+
+// Protocols known to the decoder, in detection order
+var Protocols = []Protocol{
+	{"PT2262/EV1527", 350 * time.Microsecond, [2]int{1, 31}, [2]int{1, 3}, [2]int{3, 1}, false},
+	{"2", 650 * time.Microsecond, [2]int{1, 10}, [2]int{1, 2}, [2]int{2, 1}, false},
+	{"3", 100 * time.Microsecond, [2]int{30, 71}, [2]int{4, 11}, [2]int{9, 6}, false},
+	{"4", 380 * time.Microsecond, [2]int{1, 6}, [2]int{1, 3}, [2]int{3, 1}, false},
+	{"5", 500 * time.Microsecond, [2]int{6, 14}, [2]int{1, 2}, [2]int{2, 1}, false},
+	{"HT6P20B", 450 * time.Microsecond, [2]int{23, 1}, [2]int{1, 2}, [2]int{2, 1}, true},
+	{"HS2303-PT", 150 * time.Microsecond, [2]int{2, 62}, [2]int{1, 6}, [2]int{6, 1}, false},
+}
+
+const (
+	DefaultRepeat = 10
+	MaxBits       = 64
+
+	// Gaps longer than this separate frames
+	separationLimit = 4300 * time.Microsecond
+	minChanges      = 8
+	maxChanges      = 2*MaxBits + 2
+	tolerancePct    = 60
+)
+
+var ErrTriState = errors.New("Invalid tri-state code")
+
+// Received code
+type Code struct {
+	Value    uint64
+	Bits     int
+	Protocol int           // index into Protocols
+	Pulse    time.Duration // measured pulse length
+}
+
+// PT2262 style tri-state code ("0", "1" and "F" symbols) to binary, two bits per symbol
+func TriState(s string) (uint64, int, error) {
+	if len(s)*2 > MaxBits {
+		return 0, 0, ErrTriState
+	}
+
+	var code uint64
+	for _, c := range s {
+		code <<= 2
+		switch c {
+		case '0':
+		case '1':
+			code |= 3
+		case 'F', 'f':
+			code |= 1
+		default:
+			return 0, 0, ErrTriState
+		}
+	}
+	return code, len(s) * 2, nil
+}
+
+func (p *Protocol) pair(hl [2]int) []gpio.PulseSpec {
+	high, low := 1, 0
+	if p.Inverted {
+		high, low = 0, 1
+	}
+	return []gpio.PulseSpec{
+		{Level: high, Duration: time.Duration(hl[0]) * p.Pulse},
+		{Level: low, Duration: time.Duration(hl[1]) * p.Pulse},
+	}
+}
+
+// Single frame, most significant bit first followed by sync
+func (p *Protocol) Encode(code uint64, bits int) []gpio.PulseSpec {
+	pulses := make([]gpio.PulseSpec, 0, 2*bits+2)
+	for i := bits - 1; i >= 0; i-- {
+		if code&(1<<uint(i)) != 0 {
+			pulses = append(pulses, p.pair(p.One)...)
+		} else {
+			pulses = append(pulses, p.pair(p.Zero)...)
+		}
+	}
+	return append(pulses, p.pair(p.Sync)...)
+}
+
+// Transmit code repeat times. The pin is left low.
+func Send(pin gpio.PinWriter, p *Protocol, code uint64, bits, repeat int) error {
+	frame := p.Encode(code, bits)
+
+	pulses := make([]gpio.PulseSpec, 0, len(frame)*repeat)
+	for i := 0; i < repeat; i++ {
+		pulses = append(pulses, frame...)
+	}
+
+	if err := gpio.SendPulses(pin, pulses); err != nil {
+		return err
+	}
+	return pin.Write(0)
+}
+
+// Frame decoder fed by durations between consecutive edges
+type Decoder struct {
+	timings []time.Duration
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (dec *Decoder) decode(idx int) (Code, bool) {
+	p := &Protocols[idx]
+	t := dec.timings
+
+	var (
+		sync  int
+		first int
+	)
+	if p.Inverted {
+		sync, first = p.Sync[0], 2
+	} else {
+		sync, first = p.Sync[1], 1
+	}
+
+	delay := t[0] / time.Duration(sync)
+	tol := delay * tolerancePct / 100
+
+	match := func(i int, hl [2]int) bool {
+		return abs(t[i]-delay*time.Duration(hl[0])) < tol && abs(t[i+1]-delay*time.Duration(hl[1])) < tol
+	}
+
+	var code uint64
+	for i := first; i < len(t)-1; i += 2 {
+		code <<= 1
+		if match(i, p.One) {
+			code |= 1
+		} else if !match(i, p.Zero) {
+			return Code{}, false
+		}
+	}
+
+	return Code{
+		Value:    code,
+		Bits:     (len(t) - 1) / 2,
+		Protocol: idx,
+		Pulse:    delay,
+	}, true
+}
+
+// Feed duration of the last level. Returns decoded code once a complete frame has been seen.
+func (dec *Decoder) Feed(d time.Duration) (Code, bool) {
+	if d <= separationLimit {
+		if len(dec.timings) == 0 {
+			// wait for the first gap
+			return Code{}, false
+		}
+		if len(dec.timings) == maxChanges {
+			dec.timings = dec.timings[:0]
+			return Code{}, false
+		}
+		dec.timings = append(dec.timings, d)
+		return Code{}, false
+	}
+
+	var (
+		code Code
+		ok   bool
+	)
+	// repeated frames are preceded by the gaps of the same length
+	if len(dec.timings) >= minChanges && abs(d-dec.timings[0]) < dec.timings[0]/5 {
+		for i := range Protocols {
+			if code, ok = dec.decode(i); ok {
+				break
+			}
+		}
+	}
+
+	dec.timings = append(dec.timings[:0], d)
+	return code, ok
+}
+
+// Decodes codes from a receiver module output
+type Receiver struct {
+	tr gpio.PinTrigger
+	ch chan Code
+}
+
+func NewReceiver(pin gpio.PinReadTrigger) (*Receiver, error) {
+	tr, err := pin.Trigger(gpio.EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		tr: tr,
+		ch: make(chan Code, 16),
+	}
+	go r.serve()
+
+	return r, nil
+}
+
+func (r *Receiver) serve() {
+	defer close(r.ch)
+
+	var (
+		dec  Decoder
+		last time.Time
+		buf  [64]gpio.Event
+	)
+
+	for {
+		n, err := gpio.ReadEvents(r.tr, buf[:])
+		if err != nil {
+			return
+		}
+
+		for _, ev := range buf[:n] {
+			if !last.IsZero() {
+				if code, ok := dec.Feed(ev.Timestamp.Sub(last)); ok && len(r.ch) != cap(r.ch) {
+					r.ch <- code
+				}
+			}
+			last = ev.Timestamp
+		}
+	}
+}
+
+// Each repeated frame is delivered separately
+func (r *Receiver) Ch() <-chan Code {
+	return r.ch
+}
+
+func (r *Receiver) Close() error {
+	err := r.tr.Close()
+	for range r.ch {
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package rcswitch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTriState(t *testing.T) {
+	tests := []struct {
+		s    string
+		code uint64
+		bits int
+		err  error
+	}{
+		{"", 0, 0, nil},
+		{"0", 0, 2, nil},
+		{"1", 3, 2, nil},
+		{"F", 1, 2, nil},
+		{"f", 1, 2, nil},
+		{"0F1", 0x07, 6, nil},
+		{"00000FFF0F0F", 0x001511, 24, nil},
+		{"0000000000000000000000000000000F", 1, 64, nil},
+		{"00000000000000000000000000000000F", 0, 0, ErrTriState},
+		{"01X", 0, 0, ErrTriState},
+	}
+
+	for _, tt := range tests {
+		code, bits, err := TriState(tt.s)
+		if code != tt.code || bits != tt.bits || err != tt.err {
+			t.Errorf("%q: got %#x/%d/%v, want %#x/%d/%v", tt.s, code, bits, err, tt.code, tt.bits, tt.err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	p := &Protocols[0]
+	pulses := p.Encode(0x2, 2)
+
+	want := []struct {
+		level int
+		units int
+	}{
+		{1, 3}, {0, 1}, // 1
+		{1, 1}, {0, 3}, // 0
+		{1, 1}, {0, 31}, // sync
+	}
+	if len(pulses) != len(want) {
+		t.Fatalf("got %d pulses, want %d", len(pulses), len(want))
+	}
+	for i, w := range want {
+		if pulses[i].Level != w.level || pulses[i].Duration != time.Duration(w.units)*p.Pulse {
+			t.Errorf("pulse %d: got %+v, want level %d for %d units", i, pulses[i], w.level, w.units)
+		}
+	}
+
+	inv := &Protocols[5]
+	if pulses := inv.Encode(1, 1); pulses[0].Level != 0 || pulses[1].Level != 1 {
+		t.Errorf("inverted protocol starts with %+v", pulses[0])
+	}
+}
+
+// Edge to edge durations of repeated frames
+func transmission(p *Protocol, pulse time.Duration, code uint64, bits, repeat int) []time.Duration {
+	proto := *p
+	proto.Pulse = pulse
+
+	var d []time.Duration
+	for i := 0; i < repeat; i++ {
+		for _, ps := range proto.Encode(code, bits) {
+			d = append(d, ps.Duration)
+		}
+	}
+	return d
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol int
+		pulse    time.Duration // zero for nominal
+		code     uint64
+		bits     int
+		detected int // protocols with the same ratios are reported as the first one
+	}{
+		{"pt2262 24 bit", 0, 0, 0x5a5a5a, 24, 0},
+		{"pt2262 tri-state", 0, 0, 0x001511, 24, 0},
+		{"pt2262 slow clock", 0, 420 * time.Microsecond, 0x123, 12, 0},
+		{"pt2262 fast clock", 0, 280 * time.Microsecond, 0xabcdef, 24, 0},
+		{"protocol 2", 1, 0, 0x0f0f, 16, 1},
+		{"protocol 3", 2, 0, 0x81, 8, 2},
+		{"protocol 5", 4, 0, 0xdeadbeef, 32, 1},
+		{"ht6p20b inverted", 5, 0, 0x3ff0c8, 24, 5},
+		{"hs2303-pt", 6, 0, 0x55aa, 16, 0},
+		{"64 bit", 0, 0, 0x8000000000000001, 64, 0},
+	}
+
+	for _, tt := range tests {
+		p := &Protocols[tt.protocol]
+		pulse := tt.pulse
+		if pulse == 0 {
+			pulse = p.Pulse
+		}
+
+		var (
+			dec Decoder
+			got []Code
+		)
+		for _, d := range transmission(p, pulse, tt.code, tt.bits, 3) {
+			if c, ok := dec.Feed(d); ok {
+				got = append(got, c)
+			}
+		}
+
+		// the first frame only synchronizes the decoder
+		if len(got) != 2 {
+			t.Errorf("%s: got %d codes, want 2", tt.name, len(got))
+			continue
+		}
+		for _, c := range got {
+			if c.Value != tt.code || c.Bits != tt.bits || c.Protocol != tt.detected {
+				t.Errorf("%s: got %+v, want %#x/%d from protocol %d", tt.name, c, tt.code, tt.bits, tt.detected)
+			}
+			if c.Protocol == tt.protocol && abs(c.Pulse-pulse) > pulse/20 {
+				t.Errorf("%s: got pulse %v, want %v", tt.name, c.Pulse, pulse)
+			}
+		}
+	}
+}
+
+func TestDecoderNoise(t *testing.T) {
+	tests := []struct {
+		name    string
+		timings []time.Duration
+	}{
+		{"short burst", []time.Duration{10 * time.Millisecond, time.Millisecond, time.Millisecond, 10 * time.Millisecond}},
+		{"mismatched gaps", append(append([]time.Duration{10 * time.Millisecond}, transmission(&Protocols[0], 350*time.Microsecond, 0xff, 8, 1)[:16]...), 5*time.Millisecond)},
+		{"bad symbol", append(append([]time.Duration{10850 * time.Microsecond}, make([]time.Duration, 16)...), 10850*time.Microsecond)},
+	}
+
+	for _, tt := range tests {
+		var dec Decoder
+		for _, d := range tt.timings {
+			if c, ok := dec.Feed(d); ok {
+				t.Errorf("%s: unexpected %+v", tt.name, c)
+			}
+		}
+	}
+}
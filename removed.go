@@ -0,0 +1,183 @@
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type PinStatusEvent int
+
+const (
+	PinRemoved    PinStatusEvent = iota // device disappeared, e.g. USB expander unplugged or gpiochip unbound
+	PinReattached                       // pin was reopened after the device came back
+)
+
+// Device presence change of a pin or line
+type PinStatus struct {
+	Pin       fmt.Stringer // *Pin or *Line
+	Event     PinStatusEvent
+	Err       error // error which revealed removal
+	Timestamp time.Time
+}
+
+const reattachInterval = time.Second
+
+var pinStatusCh = make(chan PinStatus, 64)
+
+// Removal and reattach notifications of all pins. Events are dropped if nobody reads them.
+func PinStatusCh() <-chan PinStatus {
+	return pinStatusCh
+}
+
+func notifyPinStatus(pin fmt.Stringer, ev PinStatusEvent, err error) {
+	st := PinStatus{Pin: pin, Event: ev, Err: err, Timestamp: time.Now()}
+	select {
+	case pinStatusCh <- st:
+	default:
+	}
+}
+
+// Reopen pin automatically once its device returns. Triggers keep their channels open
+// meanwhile instead of closing them on removal.
+func (pin *Pin) SetAutoReattach(auto bool) {
+	pin.reattachMutex.Lock()
+	pin.autoReattach = auto
+	pin.reattachMutex.Unlock()
+}
+
+// True after the device has disappeared until the pin is reattached
+func (pin *Pin) Removed() bool {
+	return atomic.LoadInt32(&pin.gone) != 0
+}
+
+// Called from the event loop after the pin was taken out of it
+func (pin *Pin) removed(err error) {
+	atomic.StoreInt32(&pin.gone, 1)
+
+	pin.reattachMutex.Lock()
+	if pin.autoReattach {
+		pin.reattachStop = make(chan struct{})
+		pin.reattachDone = make(chan struct{})
+		go pin.reattachLoop(pin.reattachStop, pin.reattachDone)
+	} else {
+		pin.triggerLost = true
+		pin.feed.close(pin.ch, pin.events)
+	}
+	pin.reattachMutex.Unlock()
+
+	notifyPinStatus(pin, PinRemoved, err)
+}
+
+func (pin *Pin) reattachLoop(stop, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	for pin.Reattach(ctx) != nil {
+		select {
+		case <-stop:
+			return
+		case <-time.After(reattachInterval):
+		}
+	}
+}
+
+// Stop reattach loop, returns true if channels were left open by auto reattach
+func (pin *Pin) stopReattach() bool {
+	pin.reattachMutex.Lock()
+	stop, done := pin.reattachStop, pin.reattachDone
+	pin.reattachStop = nil
+	pin.reattachDone = nil
+	pin.reattachMutex.Unlock()
+
+	if stop == nil {
+		return false
+	}
+
+	close(stop)
+	<-done
+	return pin.Removed()
+}
+
+// Release trigger of removed pin without touching the device. Returns false if pin is present.
+func (pin *Pin) closeRemovedTrigger() bool {
+	if !pin.Removed() {
+		return false
+	}
+
+	if pin.stopReattach() {
+		pin.feed.close(pin.ch, pin.events)
+	} else {
+		pin.reattachMutex.Lock()
+		lost := pin.triggerLost
+		pin.triggerLost = false
+		pin.reattachMutex.Unlock()
+
+		if !lost {
+			return false
+		}
+	}
+
+	pin.ch = nil
+	pin.events = nil
+	return true
+}
+
+// Reopen pin after its device came back restoring direction and active trigger.
+// A trigger whose channels were closed on removal is not restored. Not needed with auto reattach.
+func (pin *Pin) Reattach(ctx context.Context) error {
+	if !pin.Removed() {
+		return nil
+	}
+
+	fd, err := exportPin(ctx, pin.idx)
+	if err != nil {
+		return err
+	}
+
+	pin.fdMutex.Lock()
+	old := pin.fd
+	pin.fd = fd
+	pin.fdMutex.Unlock()
+	old.Close()
+
+	// triggered pins are inputs, emulated open drain/source outputs start released
+	dir := pin.dir
+	if pin.ch != nil || pin.outMode != PushPull {
+		dir = DirIn
+	}
+	if err = openWriteCloseFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx), dirNames[dir]); err != nil {
+		return err
+	}
+
+	pin.reattachMutex.Lock()
+	lost := pin.triggerLost
+	pin.reattachMutex.Unlock()
+
+	if pin.ch != nil && !lost {
+		srv, err := getEpollServer()
+		if err != nil {
+			return err
+		}
+		if err = pin.setEdge(pin.trigger); err != nil {
+			return err
+		}
+		if err = srv.addPin(pin); err != nil {
+			return err
+		}
+	}
+
+	atomic.StoreInt32(&pin.gone, 0)
+	notifyPinStatus(pin, PinReattached, nil)
+	return nil
+}
@@ -0,0 +1,159 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Output state seen by constraints. Pins are map keys so they must be comparable (pointers or
+// plain values like all pin types of this package), custom constraints should use Value.
+type OutputState map[PinWriter]int
+
+// Returned by writes through a guard of a pin that can't be a map key
+var ErrPinNotComparable = errors.New("Pin type is not comparable")
+
+func isComparable(pin PinWriter) bool {
+	return pin != nil && reflect.TypeOf(pin).Comparable()
+}
+
+// Level of pin, low for pins not guarded. Unlike indexing it's safe for any pin type.
+func (s OutputState) Value(pin PinWriter) int {
+	if !isComparable(pin) {
+		return 0
+	}
+	return s[pin]
+}
+
+// Output interdependency rule
+type Constraint interface {
+	Check(state OutputState) error
+}
+
+// Returned when a write would break a constraint
+type RuleViolation struct {
+	Rule string
+}
+
+func (e *RuleViolation) Error() string {
+	return "Rule violation: " + e.Rule
+}
+
+type constraintFunc struct {
+	name  string
+	check func(state OutputState) bool
+}
+
+func (c *constraintFunc) Check(state OutputState) error {
+	if !c.check(state) {
+		return &RuleViolation{Rule: c.name}
+	}
+	return nil
+}
+
+// Custom constraint, check returns false on violation
+func NewConstraint(name string, check func(state OutputState) bool) Constraint {
+	return &constraintFunc{name: name, check: check}
+}
+
+// At most one of pins may be high
+func Exclusive(pins ...PinWriter) Constraint {
+	return NewConstraint(fmt.Sprintf("at most one of %d outputs high", len(pins)), func(state OutputState) bool {
+		n := 0
+		for _, p := range pins {
+			if state.Value(p) != 0 {
+				n++
+			}
+		}
+		return n <= 1
+	})
+}
+
+// y must be low whenever x is high
+func Interlock(x, y PinWriter) Constraint {
+	return NewConstraint("output low while interlocking output is high", func(state OutputState) bool {
+		return state.Value(x) == 0 || state.Value(y) == 0
+	})
+}
+
+// Set of constraints enforced on writes through guarded pins
+type Rules struct {
+	constraints []Constraint
+	state       OutputState
+	mutex       sync.Mutex
+}
+
+type guardedPin struct {
+	rules *Rules
+	pin   PinWriter
+	err   error
+}
+
+func NewRules(constraints ...Constraint) *Rules {
+	return &Rules{
+		constraints: constraints,
+		state:       make(OutputState),
+	}
+}
+
+func (r *Rules) Add(c Constraint) {
+	r.mutex.Lock()
+	r.constraints = append(r.constraints, c)
+	r.mutex.Unlock()
+}
+
+// Returns writer checking constraints before every write. Constraints refer to the original pin.
+// Initial state is read back if possible and assumed low otherwise. Writes through a guard of
+// a non-comparable pin fail with ErrPinNotComparable.
+func (r *Rules) Guard(pin PinWriter) PinWriter {
+	if !isComparable(pin) {
+		return &guardedPin{rules: r, pin: pin, err: ErrPinNotComparable}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.state[pin]; !ok {
+		val := 0
+		if rd, ok := pin.(PinReader); ok {
+			if v, err := rd.Read(); err == nil {
+				val = v
+			}
+		}
+		r.state[pin] = val
+	}
+
+	return &guardedPin{rules: r, pin: pin}
+}
+
+func (g *guardedPin) Write(value int) error {
+	if g.err != nil {
+		return g.err
+	}
+	r := g.rules
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if value != 0 {
+		value = 1
+	}
+
+	old := r.state[g.pin]
+	r.state[g.pin] = value
+
+	for _, c := range r.constraints {
+		if err := c.Check(r.state); err != nil {
+			r.state[g.pin] = old
+			return err
+		}
+	}
+
+	if err := g.pin.Write(value); err != nil {
+		r.state[g.pin] = old
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+)
+
+// Pin type that can't be used as a map key
+type sliceWriter []int
+
+func (w sliceWriter) Write(value int) error {
+	return nil
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w *failingWriter) Write(value int) error {
+	return w.err
+}
+
+func TestRules(t *testing.T) {
+	var a, b, c fakePin
+	b.value = 1 // read back by Guard
+
+	r := NewRules(Exclusive(&a, &b), Interlock(&c, &a))
+	ga, gb, gc := r.Guard(&a), r.Guard(&b), r.Guard(&c)
+
+	tests := []struct {
+		name  string
+		pin   PinWriter
+		value int
+		fail  bool
+	}{
+		{"exclusive with initial high", ga, 1, true},
+		{"release", gb, 0, false},
+		{"exclusive", ga, 1, false},
+		{"exclusive other", gb, 1, true},
+		{"interlock", gc, 1, true},
+		{"release interlock", ga, 0, false},
+		{"interlocked", gc, 1, false},
+		{"interlock reverse", ga, 1, true},
+		{"nonzero is high", gb, 5, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.pin.Write(tt.value)
+		if _, ok := err.(*RuleViolation); ok != tt.fail {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+
+	if a.value != 0 || b.value != 1 || c.value != 1 {
+		t.Errorf("got levels %d %d %d, want 0 1 1", a.value, b.value, c.value)
+	}
+}
+
+func TestRulesWriteError(t *testing.T) {
+	var a fakePin
+	fail := &failingWriter{err: errors.New("bus error")}
+
+	r := NewRules(Exclusive(&a, fail))
+	if err := r.Guard(fail).Write(1); err != fail.err {
+		t.Fatalf("got %v, want %v", err, fail.err)
+	}
+	// failed write doesn't count as high
+	if err := r.Guard(&a).Write(1); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestRulesNotComparable(t *testing.T) {
+	var a fakePin
+	w := sliceWriter{1, 2}
+
+	r := NewRules(Exclusive(&a, w), Interlock(w, &a))
+	if err := r.Guard(w).Write(1); err != ErrPinNotComparable {
+		t.Errorf("got %v, want %v", err, ErrPinNotComparable)
+	}
+	// unguarded pin counts as low
+	if err := r.Guard(&a).Write(1); err != nil {
+		t.Errorf("got %v", err)
+	}
+	if v := (OutputState{}).Value(w); v != 0 {
+		t.Errorf("got %d", v)
+	}
+}
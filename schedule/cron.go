@@ -0,0 +1,193 @@
+package schedule
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source of activation times
+type Spec interface {
+	// First activation strictly after t or zero time if there's none
+	Next(t time.Time) time.Time
+}
+
+// Standard 5 field cron expression: minute hour day-of-month month day-of-week
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+type dailySpec struct {
+	hour, min int
+}
+
+var ErrCron = errors.New("Invalid cron expression")
+
+// Upper bound for Next search
+const cronSearchYears = 5
+
+func parseField(f string, min, max int) (uint64, bool, error) {
+	var bits uint64
+	star := f == "*"
+
+	for _, part := range strings.Split(f, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, false, ErrCron
+			}
+			step = s
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				a, err1 := strconv.Atoi(part[:i])
+				b, err2 := strconv.Atoi(part[i+1:])
+				if err1 != nil || err2 != nil {
+					return 0, false, ErrCron
+				}
+				lo, hi = a, b
+			} else {
+				a, err := strconv.Atoi(part)
+				if err != nil {
+					return 0, false, ErrCron
+				}
+				lo = a
+				if step == 1 {
+					hi = a
+				}
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, false, ErrCron
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, star, nil
+}
+
+func ParseCron(expr string) (*Cron, error) {
+	f := strings.Fields(expr)
+	if len(f) != 5 {
+		return nil, ErrCron
+	}
+
+	c := new(Cron)
+	var err error
+
+	if c.minute, _, err = parseField(f[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, _, err = parseField(f[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, c.domStar, err = parseField(f[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, _, err = parseField(f[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.dow, c.dowStar, err = parseField(f[4], 0, 7); err != nil {
+		return nil, err
+	}
+
+	// 7 is Sunday too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+
+	// if both are restricted either one matches
+	if !c.domStar && !c.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// Every day at given local time
+func Daily(hour, min int) Spec {
+	return &dailySpec{hour: hour, min: min}
+}
+
+func (d *dailySpec) Next(t time.Time) time.Time {
+	n := time.Date(t.Year(), t.Month(), t.Day(), d.hour, d.min, 0, 0, t.Location())
+	if !n.After(t) {
+		n = time.Date(t.Year(), t.Month(), t.Day()+1, d.hour, d.min, 0, 0, t.Location())
+	}
+	return n
+}
+
+func parseClock(s string) (int, int, error) {
+	tm, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return tm.Hour(), tm.Minute(), nil
+}
+
+// Daily window like "06:00", "06:15". Windows crossing midnight are allowed.
+func DailyWindow(start, end string) (Spec, time.Duration, error) {
+	sh, sm, err := parseClock(start)
+	if err != nil {
+		return nil, 0, err
+	}
+	eh, em, err := parseClock(end)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	d := time.Duration((eh*60+em)-(sh*60+sm)) * time.Minute
+	if d <= 0 {
+		d += 24 * time.Hour
+	}
+
+	return Daily(sh, sm), d, nil
+}
@@ -0,0 +1,109 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+	}
+
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err != ErrCron {
+			t.Errorf("%q: got %v, want %v", expr, err, ErrCron)
+		}
+	}
+}
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", date(2024, 9, 1, 10, 7), date(2024, 9, 1, 10, 8)},
+		{"*/15 * * * *", date(2024, 9, 1, 10, 7), date(2024, 9, 1, 10, 15)},
+		{"*/15 * * * *", date(2024, 9, 1, 10, 45), date(2024, 9, 1, 11, 0)},
+		{"0 8-18/5 * * *", date(2024, 9, 1, 13, 0), date(2024, 9, 1, 18, 0)},
+		{"0 8-18/5 * * *", date(2024, 9, 1, 18, 0), date(2024, 9, 2, 8, 0)},
+		{"5,10 6 * * *", date(2024, 9, 1, 6, 5), date(2024, 9, 1, 6, 10)},
+		{"0 0 1 3 *", date(2024, 9, 1, 0, 0), date(2025, 3, 1, 0, 0)},
+		{"0 0 31 * *", date(2024, 9, 1, 0, 0), date(2024, 10, 31, 0, 0)},
+		{"0 0 29 2 *", date(2024, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"0 0 30 2 *", date(2024, 1, 1, 0, 0), time.Time{}},
+		// 7 is Sunday
+		{"30 6 * * 7", date(2024, 9, 2, 0, 0), date(2024, 9, 8, 6, 30)},
+		{"30 6 * * 0", date(2024, 9, 2, 0, 0), date(2024, 9, 8, 6, 30)},
+		{"0 0 * * 1-5", date(2024, 9, 6, 12, 0), date(2024, 9, 9, 0, 0)},
+		// only one day field restricted
+		{"0 0 13 * *", date(2024, 9, 1, 0, 0), date(2024, 9, 13, 0, 0)},
+		{"0 0 * * 5", date(2024, 9, 1, 0, 0), date(2024, 9, 6, 0, 0)},
+		{"0 0 13 * 5", date(2024, 9, 1, 0, 0), date(2024, 9, 6, 0, 0)},
+		// both restricted, either one matches
+		{"0 0 13 * 5", date(2024, 9, 6, 0, 0), date(2024, 9, 13, 0, 0)},
+		{"0 0 13 * 5", date(2024, 9, 13, 0, 0), date(2024, 9, 20, 0, 0)},
+		{"0 0 13 * 1", date(2024, 10, 8, 0, 0), date(2024, 10, 13, 0, 0)},
+		{"0 0 13 * 1", date(2024, 10, 13, 0, 0), date(2024, 10, 14, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := c.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q after %v: got %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestDailyWindow(t *testing.T) {
+	tests := []struct {
+		start, end string
+		from       time.Time
+		next       time.Time
+		duration   time.Duration
+	}{
+		{"06:00", "06:15", date(2024, 9, 1, 5, 0), date(2024, 9, 1, 6, 0), 15 * time.Minute},
+		{"06:00", "06:15", date(2024, 9, 1, 6, 0), date(2024, 9, 2, 6, 0), 15 * time.Minute},
+		{"23:30", "00:30", date(2024, 9, 1, 12, 0), date(2024, 9, 1, 23, 30), time.Hour},
+		{"12:00", "12:00", date(2024, 9, 1, 12, 0), date(2024, 9, 2, 12, 0), 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		spec, d, err := DailyWindow(tt.start, tt.end)
+		if err != nil {
+			t.Errorf("%s-%s: %v", tt.start, tt.end, err)
+			continue
+		}
+		if d != tt.duration {
+			t.Errorf("%s-%s: got duration %v, want %v", tt.start, tt.end, d, tt.duration)
+		}
+		if got := spec.Next(tt.from); !got.Equal(tt.next) {
+			t.Errorf("%s-%s after %v: got %v, want %v", tt.start, tt.end, tt.from, got, tt.next)
+		}
+	}
+
+	if _, _, err := DailyWindow("25:00", "06:00"); err == nil {
+		t.Error("invalid start accepted")
+	}
+}
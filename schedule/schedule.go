@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"context"
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"time"
+)
+
+// What to do when started in the middle of an active window
+type CatchUp int
+
+const (
+	CatchUpResume CatchUp = iota // Turn output on for the rest of the window
+	CatchUpSkip                  // Wait for the next window
+)
+
+// Recheck interval guarding against wall clock jumps
+const maxSleep = time.Minute
+
+// Output driven high for Duration starting at every Start activation
+type Rule struct {
+	Pin      gpio.PinWriter
+	Start    Spec
+	Duration time.Duration
+	CatchUp  CatchUp
+}
+
+type Entry struct {
+	rule      Rule
+	sched     *Scheduler
+	started   bool
+	skipUntil time.Time
+	value     int
+	written   bool
+
+	override      bool
+	overrideValue int
+	overrideUntil time.Time
+}
+
+type Scheduler struct {
+	entries []*Entry
+	mutex   sync.Mutex
+	wakeup  chan struct{}
+	now     func() time.Time
+}
+
+func New() *Scheduler {
+	return &Scheduler{
+		wakeup: make(chan struct{}, 1),
+		now:    time.Now,
+	}
+}
+
+func (s *Scheduler) Add(rule Rule) *Entry {
+	e := &Entry{rule: rule, sched: s}
+
+	s.mutex.Lock()
+	s.entries = append(s.entries, e)
+	s.mutex.Unlock()
+
+	s.wake()
+	return e
+}
+
+func (s *Scheduler) wake() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Start of the window containing t or zero
+func (e *Entry) window(t time.Time) time.Time {
+	st := e.rule.Start.Next(t.Add(-e.rule.Duration))
+	if !st.IsZero() && !st.After(t) {
+		return st
+	}
+	return time.Time{}
+}
+
+// Force output value until given time. Zero time means until the next scheduled transition.
+func (e *Entry) Override(value int, until time.Time) {
+	s := e.sched
+	s.mutex.Lock()
+
+	if until.IsZero() {
+		until = e.nextTransition(s.now())
+	}
+
+	e.override = true
+	e.overrideValue = value
+	e.overrideUntil = until
+	s.mutex.Unlock()
+
+	s.wake()
+}
+
+// Return to schedule
+func (e *Entry) ClearOverride() {
+	s := e.sched
+	s.mutex.Lock()
+	e.override = false
+	s.mutex.Unlock()
+
+	s.wake()
+}
+
+// Scheduled value at t
+func (e *Entry) scheduled(t time.Time) int {
+	st := e.window(t)
+
+	if !e.started {
+		e.started = true
+		if !st.IsZero() && e.rule.CatchUp == CatchUpSkip {
+			e.skipUntil = st.Add(e.rule.Duration)
+		}
+	}
+
+	if st.IsZero() || t.Before(e.skipUntil) {
+		return 0
+	}
+	return 1
+}
+
+func (e *Entry) nextTransition(t time.Time) time.Time {
+	next := e.rule.Start.Next(t)
+	if st := e.window(t); !st.IsZero() {
+		if end := st.Add(e.rule.Duration); next.IsZero() || end.Before(next) {
+			next = end
+		}
+	}
+	return next
+}
+
+// must be called with mutex held
+func (e *Entry) update(now time.Time) (time.Time, error) {
+	value := e.scheduled(now)
+	next := e.nextTransition(now)
+
+	if e.override {
+		if e.overrideUntil.IsZero() || now.Before(e.overrideUntil) {
+			value = e.overrideValue
+			if !e.overrideUntil.IsZero() && (next.IsZero() || e.overrideUntil.Before(next)) {
+				next = e.overrideUntil
+			}
+		} else {
+			e.override = false
+		}
+	}
+
+	if !e.written || value != e.value {
+		if err := e.rule.Pin.Write(value); err != nil {
+			return next, err
+		}
+		e.value = value
+		e.written = true
+	}
+
+	return next, nil
+}
+
+// Drive outputs until context is cancelled. Output errors are returned immediately.
+func (s *Scheduler) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		s.mutex.Lock()
+		now := s.now()
+		wait := maxSleep
+		var err error
+
+		for _, e := range s.entries {
+			next, uerr := e.update(now)
+			if uerr != nil && err == nil {
+				err = uerr
+			}
+			if !next.IsZero() {
+				if d := next.Sub(now); d < wait {
+					wait = d
+				}
+			}
+		}
+		s.mutex.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		case <-s.wakeup:
+		}
+	}
+}
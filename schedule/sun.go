@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"math"
+	"time"
+)
+
+// Astronomical event
+type SunEvent int
+
+const (
+	Sunrise SunEvent = iota
+	Sunset
+	CivilDawn
+	CivilDusk
+)
+
+const (
+	zenithOfficial = 90.833
+	zenithCivil    = 96
+	deg            = math.Pi / 180
+)
+
+type sunSpec struct {
+	event    SunEvent
+	lat, lon float64
+	offset   time.Duration
+}
+
+// Activates at sun event plus offset at given location (degrees, east and north positive).
+// Days without the event (polar day or night) are skipped.
+func Sun(event SunEvent, lat, lon float64, offset time.Duration) Spec {
+	return &sunSpec{
+		event:  event,
+		lat:    lat,
+		lon:    lon,
+		offset: offset,
+	}
+}
+
+func normalize(v, max float64) float64 {
+	v = math.Mod(v, max)
+	if v < 0 {
+		v += max
+	}
+	return v
+}
+
+// Event time in UTC for the given UTC date, see Almanac for Computers, 1990
+func (s *sunSpec) eventTime(day time.Time) (time.Time, bool) {
+	rising := s.event == Sunrise || s.event == CivilDawn
+	zenith := zenithOfficial
+	if s.event == CivilDawn || s.event == CivilDusk {
+		zenith = zenithCivil
+	}
+
+	n := float64(day.YearDay())
+	lngHour := s.lon / 15
+
+	var t float64
+	if rising {
+		t = n + (6-lngHour)/24
+	} else {
+		t = n + (18-lngHour)/24
+	}
+
+	m := 0.9856*t - 3.289
+	l := normalize(m+1.916*math.Sin(m*deg)+0.020*math.Sin(2*m*deg)+282.634, 360)
+
+	ra := normalize(math.Atan(0.91764*math.Tan(l*deg))/deg, 360)
+	ra += (math.Floor(l/90) - math.Floor(ra/90)) * 90
+	ra /= 15
+
+	sinDec := 0.39782 * math.Sin(l*deg)
+	cosDec := math.Cos(math.Asin(sinDec))
+
+	cosH := (math.Cos(zenith*deg) - sinDec*math.Sin(s.lat*deg)) / (cosDec * math.Cos(s.lat*deg))
+	if cosH > 1 || cosH < -1 {
+		return time.Time{}, false
+	}
+
+	var h float64
+	if rising {
+		h = 360 - math.Acos(cosH)/deg
+	} else {
+		h = math.Acos(cosH) / deg
+	}
+	h /= 15
+
+	ut := normalize(h+ra-0.06571*t-6.622-lngHour, 24)
+
+	return day.Add(time.Duration(ut * float64(time.Hour))), true
+}
+
+func (s *sunSpec) Next(t time.Time) time.Time {
+	u := t.UTC()
+	day := time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+
+	// start a day earlier as the event may fall on the previous UTC date
+	for d := -1; d <= 366; d++ {
+		ev, ok := s.eventTime(day.AddDate(0, 0, d))
+		if !ok {
+			continue
+		}
+
+		ev = ev.Add(s.offset)
+		if ev.After(t) {
+			return ev.In(t.Location())
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,46 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    SunEvent
+		lat, lon float64
+		from     time.Time
+		want     time.Time
+	}{
+		{"London sunrise", Sunrise, 51.5074, -0.1278, date(2024, 6, 21, 0, 0), date(2024, 6, 21, 3, 43)},
+		{"London sunset", Sunset, 51.5074, -0.1278, date(2024, 6, 21, 0, 0), date(2024, 6, 21, 20, 21)},
+		{"New York sunrise", Sunrise, 40.7128, -74.0060, date(2024, 12, 21, 0, 0), date(2024, 12, 21, 12, 16)},
+		{"New York sunset", Sunset, 40.7128, -74.0060, date(2024, 12, 21, 0, 0), date(2024, 12, 21, 21, 32)},
+		{"London civil dawn", CivilDawn, 51.5074, -0.1278, date(2024, 3, 20, 0, 0), date(2024, 3, 20, 5, 29)},
+	}
+
+	for _, tt := range tests {
+		got := Sun(tt.event, tt.lat, tt.lon, 0).Next(tt.from)
+		if d := got.Sub(tt.want); d < -3*time.Minute || d > 3*time.Minute {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSunOffset(t *testing.T) {
+	from := date(2024, 6, 21, 0, 0)
+	base := Sun(Sunset, 51.5074, -0.1278, 0).Next(from)
+	got := Sun(Sunset, 51.5074, -0.1278, -30*time.Minute).Next(from)
+	if d := base.Sub(got); d != 30*time.Minute {
+		t.Errorf("got %v before sunset, want 30m", d)
+	}
+}
+
+func TestSunPolar(t *testing.T) {
+	// no sunset in Tromsø until late July
+	got := Sun(Sunset, 69.6492, 18.9553, 0).Next(date(2024, 6, 21, 0, 0))
+	if got.IsZero() || got.Before(date(2024, 7, 15, 0, 0)) || got.After(date(2024, 8, 1, 0, 0)) {
+		t.Errorf("got %v, want late July", got)
+	}
+}
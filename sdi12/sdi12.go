@@ -0,0 +1,356 @@
+package sdi12
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	bitTime    = time.Second / 1200
+	breakTime  = 13 * time.Millisecond // at least 12ms
+	markTime   = 9 * time.Millisecond  // at least 8.33ms
+	charBits   = 10                    // start, 7 data, parity, stop
+	idleTime   = 20 * time.Millisecond // response is over once the line is idle this long
+	maxRetries = 3
+
+	DefaultTimeout = 800 * time.Millisecond
+)
+
+var (
+	ErrTimeout  = errors.New("No response from sensor")
+	ErrParity   = errors.New("Parity error")
+	ErrResponse = errors.New("Malformed response")
+)
+
+// Bidirectional data line
+type Pin interface {
+	gpio.PinReadTrigger
+	gpio.PinWriter
+	SetDirection(dir gpio.Direction) error
+}
+
+// SDI-12 data recorder (bus master)
+type Bus struct {
+	pin Pin
+
+	// Set when an inverting buffer sits between the pin and the bus
+	Inverted bool
+	// Response timeout
+	Timeout time.Duration
+}
+
+func NewBus(pin Pin) *Bus {
+	return &Bus{pin: pin, Timeout: DefaultTimeout}
+}
+
+// line level for spacing (logical 0, high voltage) or marking (logical 1, low voltage)
+func (b *Bus) level(spacing bool) int {
+	if spacing != b.Inverted {
+		return 1
+	}
+	return 0
+}
+
+func parity(c byte) byte {
+	c ^= c >> 4
+	c ^= c >> 2
+	c ^= c >> 1
+	return c & 1
+}
+
+func (b *Bus) encode(cmd string) []gpio.PulseSpec {
+	pulses := []gpio.PulseSpec{
+		{Level: b.level(true), Duration: breakTime},
+		{Level: b.level(false), Duration: markTime},
+	}
+
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i] & 0x7f
+		frame := uint(c) | uint(parity(c))<<7
+
+		pulses = append(pulses, gpio.PulseSpec{Level: b.level(true), Duration: bitTime})
+		for bit := uint(0); bit < 8; bit++ {
+			pulses = append(pulses, gpio.PulseSpec{Level: b.level(frame&(1<<bit) == 0), Duration: bitTime})
+		}
+		pulses = append(pulses, gpio.PulseSpec{Level: b.level(false), Duration: bitTime})
+	}
+
+	return pulses
+}
+
+// Reconstruct characters from edge events. Line is assumed to be marking before the first event.
+func (b *Bus) decode(events []gpio.Event) (string, error) {
+	mark := b.level(false)
+
+	levelAt := func(t time.Time) int {
+		l := mark
+		for _, ev := range events {
+			if ev.Timestamp.After(t) {
+				break
+			}
+			l = ev.Value
+		}
+		return l
+	}
+
+	var (
+		res  []byte
+		next time.Time
+	)
+	for _, ev := range events {
+		if ev.Value == mark || ev.Timestamp.Before(next) {
+			continue
+		}
+
+		// start bit edge
+		start := ev.Timestamp
+		var frame uint
+		for bit := uint(0); bit < 8; bit++ {
+			t := start.Add(bitTime*time.Duration(bit+1) + bitTime/2)
+			if levelAt(t) == mark {
+				frame |= 1 << bit
+			}
+		}
+
+		c := byte(frame & 0x7f)
+		if byte(frame>>7) != parity(c) {
+			return string(res), ErrParity
+		}
+		res = append(res, c)
+
+		// next start bit can't come before the middle of the stop bit
+		next = start.Add(bitTime*(charBits-1) + bitTime/2)
+	}
+
+	return string(res), nil
+}
+
+func (b *Bus) receive(timeout time.Duration) ([]gpio.Event, error) {
+	tr, err := b.pin.Trigger(gpio.EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan gpio.Event, 256)
+	go func() {
+		var buf [64]gpio.Event
+		for {
+			n, err := gpio.ReadEvents(tr, buf[:])
+			if err != nil {
+				close(ch)
+				return
+			}
+			for _, ev := range buf[:n] {
+				if len(ch) != cap(ch) {
+					ch <- ev
+				}
+			}
+		}
+	}()
+
+	var events []gpio.Event
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	idle := time.NewTimer(timeout)
+	defer idle.Stop()
+
+wait:
+	for {
+		select {
+		case ev := <-ch:
+			events = append(events, ev)
+			idle.Reset(idleTime)
+
+		case <-idle.C:
+			break wait
+
+		case <-deadline.C:
+			break wait
+		}
+	}
+
+	err = tr.Close()
+	for range ch {
+	}
+
+	if len(events) == 0 {
+		return nil, ErrTimeout
+	}
+	return events, err
+}
+
+// Send raw command and return response without trailing CR LF. Retried on timeout.
+func (b *Bus) Command(cmd string) (string, error) {
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		var resp string
+		if resp, err = b.command(cmd); err == nil {
+			return resp, nil
+		}
+		if err != ErrTimeout && err != ErrParity {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+func (b *Bus) command(cmd string) (string, error) {
+	if err := b.pin.SetDirection(gpio.DirOut); err != nil {
+		return "", err
+	}
+	if err := gpio.SendPulses(b.pin, b.encode(cmd)); err != nil {
+		return "", err
+	}
+
+	// Trigger releases the line
+	events, err := b.receive(b.Timeout)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := b.decode(events)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(resp, "\r\n") {
+		return "", ErrResponse
+	}
+
+	return strings.TrimSuffix(resp, "\r\n"), nil
+}
+
+// Address of the only sensor on the bus
+func (b *Bus) Query() (byte, error) {
+	resp, err := b.Command("?!")
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) != 1 {
+		return 0, ErrResponse
+	}
+	return resp[0], nil
+}
+
+// Check that sensor responds
+func (b *Bus) Acknowledge(addr byte) error {
+	_, err := b.addressed(addr, "")
+	return err
+}
+
+// Identification string following the address
+func (b *Bus) Identify(addr byte) (string, error) {
+	return b.addressed(addr, "I")
+}
+
+func (b *Bus) ChangeAddress(addr, newAddr byte) error {
+	resp, err := b.Command(string([]byte{addr, 'A', newAddr, '!'}))
+	if err != nil {
+		return err
+	}
+	if resp != string(newAddr) {
+		return ErrResponse
+	}
+	return nil
+}
+
+// Send command prefixed with address and strip address from response
+func (b *Bus) addressed(addr byte, cmd string) (string, error) {
+	resp, err := b.Command(string(addr) + cmd + "!")
+	if err != nil {
+		return "", err
+	}
+	if len(resp) == 0 || resp[0] != addr {
+		return "", ErrResponse
+	}
+	return resp[1:], nil
+}
+
+// Start measurement. Returns time until data is ready and number of values.
+func (b *Bus) Measure(addr byte) (time.Duration, int, error) {
+	resp, err := b.addressed(addr, "M")
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(resp) != 4 {
+		return 0, 0, ErrResponse
+	}
+
+	sec, err := strconv.Atoi(resp[:3])
+	if err != nil {
+		return 0, 0, ErrResponse
+	}
+	n, err := strconv.Atoi(resp[3:])
+	if err != nil {
+		return 0, 0, ErrResponse
+	}
+
+	return time.Duration(sec) * time.Second, n, nil
+}
+
+// Values from data buffer page
+func (b *Bus) Data(addr byte, page int) ([]float64, error) {
+	resp, err := b.addressed(addr, "D"+strconv.Itoa(page))
+	if err != nil {
+		return nil, err
+	}
+	return parseValues(resp)
+}
+
+func parseValues(s string) ([]float64, error) {
+	var res []float64
+	for len(s) != 0 {
+		if s[0] != '+' && s[0] != '-' {
+			return nil, ErrResponse
+		}
+
+		end := strings.IndexAny(s[1:], "+-") + 1
+		if end == 0 {
+			end = len(s)
+		}
+
+		v, err := strconv.ParseFloat(s[:end], 64)
+		if err != nil {
+			return nil, ErrResponse
+		}
+		res = append(res, v)
+		s = s[end:]
+	}
+	return res, nil
+}
+
+// Measure and collect all values. Waits for service request or announced time.
+func (b *Bus) Read(addr byte) ([]float64, error) {
+	wait, n, err := b.Measure(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if wait != 0 {
+		// service request is a bare address, the sensor may skip it
+		if err := b.pin.SetDirection(gpio.DirIn); err != nil {
+			return nil, err
+		}
+		b.receive(wait + b.Timeout)
+	}
+
+	var res []float64
+	for page := 0; len(res) < n && page < 10; page++ {
+		vals, err := b.Data(addr, page)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) == 0 {
+			break
+		}
+		res = append(res, vals...)
+	}
+
+	if len(res) < n {
+		return res, ErrResponse
+	}
+	return res, nil
+}
@@ -0,0 +1,131 @@
+package sdi12
+
+import (
+	"github.com/e-asphyx/gpio"
+	"testing"
+	"time"
+)
+
+func TestParity(t *testing.T) {
+	tests := []struct {
+		c byte
+		p byte
+	}{
+		{0x00, 0},
+		{'0', 0}, // 0x30
+		{'1', 1}, // 0x31
+		{'!', 0}, // 0x21
+		{'M', 0}, // 0x4d
+		{'?', 0}, // 0x3f
+		{0x7f, 1},
+	}
+
+	for _, tt := range tests {
+		if got := parity(tt.c); got != tt.p {
+			t.Errorf("%q: got %d, want %d", tt.c, got, tt.p)
+		}
+	}
+}
+
+// Edges of encoded characters after the break and marking
+func edges(b *Bus, pulses []gpio.PulseSpec, jitter time.Duration) []gpio.Event {
+	var (
+		events []gpio.Event
+		level  = b.level(false)
+		ts     = time.Unix(0, 0)
+	)
+	for i, p := range pulses[2:] {
+		if p.Level != level {
+			t := ts
+			if i%2 == 0 {
+				t = t.Add(jitter)
+			}
+			events = append(events, gpio.Event{Value: p.Level, Timestamp: t})
+			level = p.Level
+		}
+		ts = ts.Add(p.Duration)
+	}
+	return events
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		inverted bool
+		jitter   time.Duration
+	}{
+		{"query", "?!", false, 0},
+		{"measure", "0M!", false, 0},
+		{"response", "0+3.14-2.5+1000\r\n", false, 0},
+		{"inverted", "1D0!", true, 0},
+		{"all zero bits", "\x00\x00", false, 0},
+		{"all one bits", "\x7f\x7f", false, 0},
+		{"late edges", "0I!", false, bitTime / 4},
+		{"early edges", "0I!", true, -bitTime / 4},
+	}
+
+	for _, tt := range tests {
+		b := &Bus{Inverted: tt.inverted}
+		pulses := b.encode(tt.s)
+
+		if len(pulses) != 2+len(tt.s)*charBits {
+			t.Errorf("%s: got %d pulses, want %d", tt.name, len(pulses), 2+len(tt.s)*charBits)
+			continue
+		}
+		if pulses[0].Level != b.level(true) || pulses[0].Duration < 12*time.Millisecond ||
+			pulses[1].Level != b.level(false) || pulses[1].Duration < 8330*time.Microsecond {
+			t.Errorf("%s: bad break %+v", tt.name, pulses[:2])
+		}
+
+		got, err := b.decode(edges(b, pulses, tt.jitter))
+		if err != nil || got != tt.s {
+			t.Errorf("%s: got %q/%v, want %q", tt.name, got, err, tt.s)
+		}
+	}
+}
+
+func TestDecodeParity(t *testing.T) {
+	b := &Bus{}
+	pulses := b.encode("0!")
+
+	// flip the parity bit of the second character
+	p := &pulses[2+charBits+8]
+	p.Level ^= 1
+
+	got, err := b.decode(edges(b, pulses, 0))
+	if err != ErrParity || got != "0" {
+		t.Errorf("got %q/%v, want %q/%v", got, err, "0", ErrParity)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []float64
+		err  error
+	}{
+		{"", nil, nil},
+		{"+1", []float64{1}, nil},
+		{"+3.14-2.5+1000", []float64{3.14, -2.5, 1000}, nil},
+		{"-0.001+0", []float64{-0.001, 0}, nil},
+		{"3.14", nil, ErrResponse},
+		{"+", nil, ErrResponse},
+		{"+1.2.3", nil, ErrResponse},
+		{"+1x", nil, ErrResponse},
+	}
+
+	for _, tt := range tests {
+		got, err := parseValues(tt.s)
+		if err != tt.err || len(got) != len(tt.want) {
+			t.Errorf("%q: got %v/%v, want %v/%v", tt.s, got, err, tt.want, tt.err)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: got %v, want %v", tt.s, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package serialdev
+
+import (
+	"context"
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"io"
+	"time"
+)
+
+const (
+	DefaultPollInterval = time.Millisecond
+	DefaultTimeout      = time.Second
+)
+
+var ErrBusy = errors.New("Device busy")
+
+// Serial module (display, modem) with GPIO side channels. All pins are optional.
+type Device struct {
+	Port io.ReadWriter
+
+	Reset       gpio.PinWriter
+	ResetActive int // level holding device in reset
+
+	Busy       gpio.PinReader
+	BusyActive int // level reported while device is busy
+
+	// Hardware flow control on GPIOs for UARTs without RTS/CTS lines.
+	// Writes are held off while CTS is inactive.
+	RTS       gpio.PinWriter
+	CTS       gpio.PinReader
+	CTSActive int
+
+	PollInterval time.Duration
+	Timeout      time.Duration // for Write waiting on Busy and CTS
+}
+
+func New(port io.ReadWriter) *Device {
+	return &Device{
+		Port:         port,
+		PollInterval: DefaultPollInterval,
+		Timeout:      DefaultTimeout,
+	}
+}
+
+// Hold reset for hold, release it and wait settle. Pending input is not flushed.
+func (d *Device) ResetPulse(hold, settle time.Duration) error {
+	if d.Reset == nil {
+		return gpio.ErrUnsupported
+	}
+
+	if err := d.Reset.Write(d.ResetActive); err != nil {
+		return err
+	}
+	time.Sleep(hold)
+
+	if err := d.Reset.Write(d.ResetActive ^ 1); err != nil {
+		return err
+	}
+	time.Sleep(settle)
+
+	return nil
+}
+
+func (d *Device) interval() time.Duration {
+	if d.PollInterval > 0 {
+		return d.PollInterval
+	}
+	return DefaultPollInterval
+}
+
+// poll until pin reads value
+func (d *Device) waitLevel(ctx context.Context, pin gpio.PinReader, value int) error {
+	ticker := time.NewTicker(d.interval())
+	defer ticker.Stop()
+
+	for {
+		v, err := pin.Read()
+		if err != nil {
+			return err
+		}
+		if v == value {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return ErrBusy
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Wait for Busy pin to become inactive. Returns ErrBusy on deadline.
+func (d *Device) WaitReady(ctx context.Context) error {
+	if d.Busy == nil {
+		return nil
+	}
+	return d.waitLevel(ctx, d.Busy, d.BusyActive^1)
+}
+
+// Signal readiness to receive via RTS pin
+func (d *Device) SetRTS(ready bool) error {
+	if d.RTS == nil {
+		return gpio.ErrUnsupported
+	}
+	return gpio.WriteBool(d.RTS, ready)
+}
+
+func (d *Device) Read(p []byte) (int, error) {
+	return d.Port.Read(p)
+}
+
+// Write once device is ready and CTS allows
+func (d *Device) Write(p []byte) (int, error) {
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := d.WaitReady(ctx); err != nil {
+		return 0, err
+	}
+	if d.CTS != nil {
+		if err := d.waitLevel(ctx, d.CTS, d.CTSActive); err != nil {
+			return 0, err
+		}
+	}
+
+	return d.Port.Write(p)
+}
@@ -0,0 +1,123 @@
+package gpio
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	servoFreq   = 50
+	servoPeriod = time.Second / servoFreq
+
+	DefaultServoMinPulse = 1000 * time.Microsecond
+	DefaultServoMaxPulse = 2000 * time.Microsecond
+	DefaultServoRange    = 180.0
+)
+
+type ServoOption func(s *Servo)
+
+// Pulse widths at both ends of travel, many servos accept 500-2500us
+func ServoPulseRange(min, max time.Duration) ServoOption {
+	return func(s *Servo) {
+		s.minPulse, s.maxPulse = min, max
+	}
+}
+
+// Travel in degrees between min and max pulse
+func ServoAngleRange(deg float64) ServoOption {
+	return func(s *Servo) {
+		s.angleRange = deg
+	}
+}
+
+// Hobby RC servo driven by 50Hz pulses using hardware PWM if pin provides it
+type Servo struct {
+	out        DutyWriter
+	minPulse   time.Duration
+	maxPulse   time.Duration
+	angleRange float64
+
+	mutex sync.Mutex
+	pulse time.Duration
+}
+
+// Servo is left without pulses until a position is set
+func NewServo(pin PinWriter, opts ...ServoOption) (*Servo, error) {
+	s := &Servo{
+		minPulse:   DefaultServoMinPulse,
+		maxPulse:   DefaultServoMaxPulse,
+		angleRange: DefaultServoRange,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	out, err := NewDutyWriter(pin, servoFreq)
+	if err != nil {
+		return nil, err
+	}
+	s.out = out
+
+	return s, nil
+}
+
+// Pulse width is clamped to the configured range
+func (s *Servo) SetPulseWidth(d time.Duration) error {
+	if d < s.minPulse {
+		d = s.minPulse
+	} else if d > s.maxPulse {
+		d = s.maxPulse
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.out.SetDuty(float64(d) / float64(servoPeriod)); err != nil {
+		return err
+	}
+	s.pulse = d
+	return nil
+}
+
+// 0 to angle range
+func (s *Servo) SetAngle(deg float64) error {
+	if deg < 0 {
+		deg = 0
+	} else if deg > s.angleRange {
+		deg = s.angleRange
+	}
+
+	d := s.minPulse + time.Duration(float64(s.maxPulse-s.minPulse)*deg/s.angleRange)
+	return s.SetPulseWidth(d)
+}
+
+// Last commanded angle, zero if released
+func (s *Servo) Angle() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.pulse == 0 {
+		return 0
+	}
+	return float64(s.pulse-s.minPulse) / float64(s.maxPulse-s.minPulse) * s.angleRange
+}
+
+// Stop pulses so the servo stops holding position
+func (s *Servo) Release() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pulse = 0
+	return s.out.SetDuty(0)
+}
+
+func (s *Servo) Close() error {
+	err := s.Release()
+	if c, ok := s.out.(io.Closer); ok {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
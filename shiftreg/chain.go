@@ -0,0 +1,282 @@
+package shiftreg
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"sync"
+)
+
+// Register in a chain
+type ChipConfig struct {
+	Bits int            // register width, 8 for 74HC595 and 74HC165
+	OE   gpio.PinWriter // optional active low output enable
+}
+
+// Cascade of serial-in parallel-out (74HC595) or parallel-in serial-out (74HC165) registers
+// exposed as flat pin space. Chip 0 is the one connected to the host, its bit 0 is pin 0.
+type Chain struct {
+	data    gpio.PinWriter // serial data out, output chains
+	in      gpio.PinReader // serial data in, input chains
+	clock   gpio.PinWriter
+	latch   gpio.PinWriter // storage clock for outputs, parallel load (active low) for inputs
+	chips   []ChipConfig
+	offsets []int
+
+	state     []byte
+	dirty     bool
+	autoFlush bool
+	mutex     sync.Mutex
+}
+
+// Virtual pin of a chain
+type ChainPin struct {
+	chain *Chain
+	idx   int
+}
+
+var (
+	ErrDirection = errors.New("Wrong direction for this chain")
+	ErrConfig    = errors.New("Invalid chain configuration")
+)
+
+func newChain(clock, latch gpio.PinWriter, chips []ChipConfig) (*Chain, error) {
+	c := &Chain{
+		clock:     clock,
+		latch:     latch,
+		chips:     chips,
+		offsets:   make([]int, len(chips)),
+		autoFlush: true,
+	}
+
+	n := 0
+	for i, chip := range chips {
+		if chip.Bits <= 0 {
+			return nil, ErrConfig
+		}
+		c.offsets[i] = n
+		n += chip.Bits
+	}
+	c.state = make([]byte, (n+7)/8)
+
+	return c, nil
+}
+
+// Output chain. Outputs are cleared.
+func NewOutputChain(data, clock, latch gpio.PinWriter, chips []ChipConfig) (*Chain, error) {
+	c, err := newChain(clock, latch, chips)
+	if err != nil {
+		return nil, err
+	}
+	c.data = data
+
+	if err = c.clock.Write(0); err != nil {
+		return nil, err
+	}
+	if err = c.latch.Write(0); err != nil {
+		return nil, err
+	}
+
+	return c, c.shiftOut(c.state)
+}
+
+// Input chain
+func NewInputChain(data gpio.PinReader, clock, load gpio.PinWriter, chips []ChipConfig) (*Chain, error) {
+	c, err := newChain(clock, load, chips)
+	if err != nil {
+		return nil, err
+	}
+	c.in = data
+
+	if err = c.clock.Write(0); err != nil {
+		return nil, err
+	}
+	if err = c.latch.Write(1); err != nil {
+		return nil, err
+	}
+
+	return c, c.refresh()
+}
+
+// Total number of pins
+func (c *Chain) Len() int {
+	n := 0
+	for _, chip := range c.chips {
+		n += chip.Bits
+	}
+	return n
+}
+
+func (c *Chain) Pin(n int) (*ChainPin, error) {
+	if n < 0 || n >= c.Len() {
+		return nil, ErrRange
+	}
+	return &ChainPin{chain: c, idx: n}, nil
+}
+
+func (c *Chain) clockPulse() error {
+	if err := c.clock.Write(1); err != nil {
+		return err
+	}
+	return c.clock.Write(0)
+}
+
+// shift out the whole frame, bit 0 is shifted last
+func (c *Chain) shiftOut(frame []byte) error {
+	for i := c.Len() - 1; i >= 0; i-- {
+		if err := c.data.Write(int(frame[i/8]>>uint(i%8)) & 1); err != nil {
+			return err
+		}
+		if err := c.clockPulse(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.latch.Write(1); err != nil {
+		return err
+	}
+	return c.latch.Write(0)
+}
+
+// must be called with mutex held
+func (c *Chain) refresh() error {
+	if err := c.latch.Write(0); err != nil {
+		return err
+	}
+	if err := c.latch.Write(1); err != nil {
+		return err
+	}
+
+	// highest bit of chip 0 comes first
+	for i, chip := range c.chips {
+		for bit := chip.Bits - 1; bit >= 0; bit-- {
+			v, err := c.in.Read()
+			if err != nil {
+				return err
+			}
+
+			idx := c.offsets[i] + bit
+			if v != 0 {
+				c.state[idx/8] |= 1 << uint(idx%8)
+			} else {
+				c.state[idx/8] &^= 1 << uint(idx%8)
+			}
+
+			if err := c.clockPulse(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Update output chain only when Flush is called
+func (c *Chain) SetAutoFlush(auto bool) {
+	c.mutex.Lock()
+	c.autoFlush = auto
+	c.mutex.Unlock()
+}
+
+// Stage output value
+func (c *Chain) Set(n, value int) error {
+	if c.data == nil {
+		return ErrDirection
+	}
+	if n < 0 || n >= c.Len() {
+		return ErrRange
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	old := c.state[n/8]
+	if value != 0 {
+		c.state[n/8] |= 1 << uint(n%8)
+	} else {
+		c.state[n/8] &^= 1 << uint(n%8)
+	}
+	if c.state[n/8] != old {
+		c.dirty = true
+	}
+
+	if c.autoFlush {
+		return c.flush()
+	}
+	return nil
+}
+
+// must be called with mutex held
+func (c *Chain) flush() error {
+	if !c.dirty {
+		return nil
+	}
+	if err := c.shiftOut(c.state); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Shift staged outputs out if anything has changed
+func (c *Chain) Flush() error {
+	if c.data == nil {
+		return ErrDirection
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.flush()
+}
+
+// Sample inputs
+func (c *Chain) Refresh() error {
+	if c.in == nil {
+		return ErrDirection
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.refresh()
+}
+
+// Last written or sampled value
+func (c *Chain) Get(n int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return int(c.state[n/8]>>uint(n%8)) & 1
+}
+
+// Drive chip output enable
+func (c *Chain) EnableChip(i int, enable bool) error {
+	if i < 0 || i >= len(c.chips) || c.chips[i].OE == nil {
+		return ErrRange
+	}
+	if enable {
+		return c.chips[i].OE.Write(0)
+	}
+	return c.chips[i].OE.Write(1)
+}
+
+// Chip number and bit within it
+func (c *Chain) Locate(n int) (chip, bit int) {
+	for i := len(c.offsets) - 1; i >= 0; i-- {
+		if n >= c.offsets[i] {
+			return i, n - c.offsets[i]
+		}
+	}
+	return -1, -1
+}
+
+func (p *ChainPin) Write(value int) error {
+	return p.chain.Set(p.idx, value)
+}
+
+// Inputs are sampled on every call, outputs return the last written value
+func (p *ChainPin) Read() (int, error) {
+	if p.chain.in != nil {
+		if err := p.chain.Refresh(); err != nil {
+			return 0, err
+		}
+	}
+	return p.chain.Get(p.idx), nil
+}
@@ -0,0 +1,195 @@
+package shiftreg
+
+import (
+	"sync"
+	"testing"
+)
+
+// Chain of 74HC595 (shift on clock rise, copy to outputs on latch rise) or 74HC165
+// (parallel load while latch is low, shift on clock rise) behind three fake pins
+type simChain struct {
+	mutex   sync.Mutex
+	data    int
+	clock   int
+	latch   int
+	shift   []int
+	out     []int
+	in      []int // 74HC165 parallel inputs in shift out order
+	latches int
+	frames  [][]int
+}
+
+type simPin func(value int)
+
+func (p simPin) Write(value int) error {
+	p(value)
+	return nil
+}
+
+func newSimChain(n int) *simChain {
+	return &simChain{shift: make([]int, n), out: make([]int, n), latch: -1}
+}
+
+func (s *simChain) dataPin() simPin {
+	return func(v int) {
+		s.mutex.Lock()
+		s.data = v
+		s.mutex.Unlock()
+	}
+}
+
+func (s *simChain) clockPin() simPin {
+	return func(v int) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.clock == 0 && v != 0 {
+			if len(s.shift) != 0 {
+				copy(s.shift[1:], s.shift)
+				s.shift[0] = s.data
+			}
+			if len(s.in) != 0 {
+				s.in = s.in[1:]
+			}
+		}
+		s.clock = v
+	}
+}
+
+func (s *simChain) latchPin(inputs ...int) simPin {
+	return func(v int) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.latch == 0 && v != 0 {
+			copy(s.out, s.shift)
+			s.latches++
+			s.frames = append(s.frames, append([]int(nil), s.out...))
+		}
+		if v == 0 && inputs != nil {
+			s.in = append([]int(nil), inputs...)
+		}
+		s.latch = v
+	}
+}
+
+// serial output of the last chip in a 74HC165 chain
+func (s *simChain) Read() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.in) == 0 {
+		return 0, nil
+	}
+	return s.in[0], nil
+}
+
+func (s *simChain) outputs() []int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]int(nil), s.out...)
+}
+
+func (s *simChain) latchCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.latches
+}
+
+func checkOutputs(t *testing.T, s *simChain, high ...int) {
+	want := make([]int, len(s.out))
+	for _, n := range high {
+		want[n] = 1
+	}
+	got := s.outputs()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("outputs %v, want %v", got, want)
+			return
+		}
+	}
+}
+
+func TestOutputChain(t *testing.T) {
+	if _, err := NewOutputChain(simPin(func(int) {}), simPin(func(int) {}), simPin(func(int) {}), []ChipConfig{{Bits: 0}}); err != ErrConfig {
+		t.Errorf("zero width chip error %v", err)
+	}
+
+	s := newSimChain(12)
+	var oe []int
+	chips := []ChipConfig{
+		{Bits: 8, OE: simPin(func(v int) { oe = append(oe, v) })},
+		{Bits: 4},
+	}
+	c, err := NewOutputChain(s.dataPin(), s.clockPin(), s.latchPin(), chips)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.latchCount() != 1 {
+		t.Errorf("got %d latches after init", s.latchCount())
+	}
+
+	if err = c.Set(10, 1); err != nil {
+		t.Fatal(err)
+	}
+	c.Set(10, 1)
+	checkOutputs(t, s, 10)
+	if s.latchCount() != 2 {
+		t.Errorf("unchanged output shifted out again, %d latches", s.latchCount())
+	}
+
+	c.SetAutoFlush(false)
+	p, _ := c.Pin(0)
+	p.Write(1)
+	c.Set(11, 1)
+	c.Set(10, 0)
+	if s.latchCount() != 2 {
+		t.Errorf("shifted out without flush")
+	}
+	if v, _ := p.Read(); v != 1 {
+		t.Errorf("staged value %d", v)
+	}
+	if err = c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkOutputs(t, s, 0, 11)
+
+	if chip, bit := c.Locate(9); chip != 1 || bit != 1 {
+		t.Errorf("Locate(9) = %d, %d", chip, bit)
+	}
+	if err = c.EnableChip(0, true); err != nil || len(oe) != 1 || oe[0] != 0 {
+		t.Errorf("EnableChip(0) = %v, OE %v", err, oe)
+	}
+	if err = c.EnableChip(1, true); err != ErrRange {
+		t.Errorf("EnableChip without OE error %v", err)
+	}
+
+	if _, err = c.Pin(12); err != ErrRange {
+		t.Errorf("Pin(12) error %v", err)
+	}
+	if err = c.Refresh(); err != ErrDirection {
+		t.Errorf("Refresh() error %v", err)
+	}
+}
+
+func TestInputChain(t *testing.T) {
+	s := newSimChain(0)
+	// chip 0 bits 7..0, then chip 1 bits 3..0
+	load := s.latchPin(1, 0, 0, 0, 0, 0, 1, 0, 0, 1, 0, 0)
+
+	c, err := NewInputChain(s, s.clockPin(), load, []ChipConfig{{Bits: 8}, {Bits: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n, want := range map[int]int{7: 1, 6: 0, 1: 1, 0: 0, 10: 1, 11: 0} {
+		if v := c.Get(n); v != want {
+			t.Errorf("Get(%d) = %d, want %d", n, v, want)
+		}
+	}
+
+	p, _ := c.Pin(10)
+	if err = p.Write(0); err != ErrDirection {
+		t.Errorf("Write() error %v", err)
+	}
+	if err = c.Flush(); err != ErrDirection {
+		t.Errorf("Flush() error %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package shiftreg
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"sync"
+	"time"
+)
+
+const spinThreshold = time.Millisecond
+
+var (
+	ErrRange  = errors.New("Output number out of range")
+	ErrActive = errors.New("PWM already running")
+)
+
+// Daisy chained 74HC595 serial-in parallel-out registers with bit angle modulation
+type HC595 struct {
+	chain *Chain
+
+	duty  []uint16
+	mutex sync.Mutex
+
+	pwmBits uint
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Virtual output pin
+type Output struct {
+	reg *HC595
+	idx int
+}
+
+func NewHC595(data, clock, latch gpio.PinWriter, chips int) (*HC595, error) {
+	cfg := make([]ChipConfig, chips)
+	for i := range cfg {
+		cfg[i].Bits = 8
+	}
+
+	chain, err := NewOutputChain(data, clock, latch, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &HC595{
+		chain: chain,
+		duty:  make([]uint16, chips*8),
+	}
+
+	return r, nil
+}
+
+// Underlying chain
+func (r *HC595) Chain() *Chain {
+	return r.chain
+}
+
+// Number of outputs
+func (r *HC595) Len() int {
+	return r.chain.Len()
+}
+
+// Set all outputs at once, bit n of the slice is output n
+func (r *HC595) WriteAll(bits []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	c := r.chain
+	c.mutex.Lock()
+	copy(c.state, bits)
+	c.dirty = true
+	c.mutex.Unlock()
+
+	if r.done != nil {
+		r.syncDuty()
+		return nil
+	}
+
+	return c.Flush()
+}
+
+// must be called with mutex held
+func (r *HC595) syncDuty() {
+	for i := range r.duty {
+		r.duty[i] = uint16(r.chain.Get(i)) * r.pwmMax()
+	}
+}
+
+func (r *HC595) Pin(n int) (*Output, error) {
+	if n < 0 || n >= r.Len() {
+		return nil, ErrRange
+	}
+	return &Output{reg: r, idx: n}, nil
+}
+
+func (o *Output) Write(value int) error {
+	r := o.reg
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.done != nil {
+		r.chain.mutex.Lock()
+		r.chain.autoFlush = false
+		r.chain.mutex.Unlock()
+
+		r.chain.Set(o.idx, value)
+		if value != 0 {
+			r.duty[o.idx] = r.pwmMax()
+		} else {
+			r.duty[o.idx] = 0
+		}
+		return nil
+	}
+
+	return r.chain.Set(o.idx, value)
+}
+
+// Set duty cycle in range 0.0 to 1.0. Takes effect only while PWM is running.
+func (o *Output) SetDuty(duty float64) error {
+	r := o.reg
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if duty < 0 {
+		duty = 0
+	} else if duty > 1 {
+		duty = 1
+	}
+
+	r.duty[o.idx] = uint16(duty*float64(r.pwmMax()) + 0.5)
+	return nil
+}
+
+func (r *HC595) pwmMax() uint16 {
+	return uint16(1)<<r.pwmBits - 1
+}
+
+func wait(deadline time.Time) {
+	if d := deadline.Sub(time.Now()); d > spinThreshold {
+		time.Sleep(d - spinThreshold)
+	}
+	for time.Now().Before(deadline) {
+	}
+}
+
+// Start bit angle modulation with given resolution (up to 16 bits). Bit plane n is held for base << n
+// so the full period is base * (2^bits - 1). Shifting time adds to each bit plane so base should be
+// well above the time needed to shift the whole chain out.
+func (r *HC595) StartPWM(bits uint, base time.Duration) error {
+	if bits == 0 || bits > 16 {
+		return ErrRange
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// done stays set until a stopping loop has exited
+	if r.done != nil {
+		return ErrActive
+	}
+
+	r.pwmBits = bits
+	r.syncDuty()
+
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go r.bam(base, r.stop, r.done)
+
+	return nil
+}
+
+func (r *HC595) bam(base time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	frame := make([]byte, len(r.chain.state))
+	next := time.Now()
+
+	for {
+		for plane := uint(0); plane < r.pwmBits; plane++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			r.mutex.Lock()
+			for i := range frame {
+				frame[i] = 0
+			}
+			for i, d := range r.duty {
+				frame[i/8] |= byte((d>>plane)&1) << uint(i%8)
+			}
+			r.chain.mutex.Lock()
+			r.chain.shiftOut(frame)
+			r.chain.mutex.Unlock()
+			r.mutex.Unlock()
+
+			next = next.Add(base << plane)
+			wait(next)
+		}
+
+		// don't try to catch up after being preempted
+		if now := time.Now(); now.Sub(next) > base<<r.pwmBits {
+			next = now
+		}
+	}
+}
+
+// Stop PWM and restore static output state
+func (r *HC595) StopPWM() error {
+	// only one caller gets to close stop
+	r.mutex.Lock()
+	stop, done := r.stop, r.done
+	r.stop = nil
+	r.mutex.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.done = nil
+
+	c := r.chain
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.autoFlush = true
+	c.dirty = true
+	return c.flush()
+}
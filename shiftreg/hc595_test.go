@@ -0,0 +1,112 @@
+package shiftreg
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitLatches(t *testing.T, s *simChain, n int) {
+	deadline := time.Now().Add(time.Second)
+	for s.latchCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d latches, want %d", s.latchCount(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHC595WriteAll(t *testing.T) {
+	s := newSimChain(16)
+	r, err := NewHC595(s.dataPin(), s.clockPin(), s.latchPin(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = r.WriteAll([]byte{0x81, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	checkOutputs(t, s, 0, 7, 9)
+
+	o, _ := r.Pin(9)
+	o.Write(0)
+	checkOutputs(t, s, 0, 7)
+
+	if _, err = r.Pin(16); err != ErrRange {
+		t.Errorf("Pin(16) error %v", err)
+	}
+}
+
+func TestHC595PWM(t *testing.T) {
+	s := newSimChain(16)
+	r, err := NewHC595(s.dataPin(), s.clockPin(), s.latchPin(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.WriteAll([]byte{0x08, 0x00})
+
+	for _, bits := range []uint{0, 17} {
+		if err = r.StartPWM(bits, time.Microsecond); err != ErrRange {
+			t.Errorf("StartPWM(%d) error %v", bits, err)
+		}
+	}
+
+	start := s.latchCount()
+	if err = r.StartPWM(4, 20*time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.StartPWM(4, 20*time.Microsecond); err != ErrActive {
+		t.Errorf("second StartPWM() error %v", err)
+	}
+
+	// half duty is bit plane 3 only, static write is staged until PWM stops
+	o7, _ := r.Pin(7)
+	o7.SetDuty(0.5)
+	o5, _ := r.Pin(5)
+	o5.Write(1)
+	marked := s.latchCount()
+	waitLatches(t, s, marked+16)
+
+	// concurrent stops must not close stop twice
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.StopPWM(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err = r.StopPWM(); err != nil {
+		t.Errorf("StopPWM() on stopped register: %v", err)
+	}
+
+	s.mutex.Lock()
+	frames := s.frames[start : len(s.frames)-1]
+	s.mutex.Unlock()
+
+	var on, off int
+	for i, f := range frames {
+		if f[3] != 1 || f[0] != 0 {
+			t.Fatalf("frame %d: %v", i, f)
+		}
+		if i >= marked-start {
+			if f[7] != 0 {
+				on++
+			} else {
+				off++
+			}
+		}
+	}
+	if on == 0 || off == 0 {
+		t.Errorf("half duty output on in %d frames, off in %d", on, off)
+	}
+
+	checkOutputs(t, s, 3, 5)
+
+	// back to immediate writes
+	o5.Write(0)
+	checkOutputs(t, s, 3)
+}
@@ -0,0 +1,77 @@
+package gpio
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Drive outputs to safe states and close all managed pins on SIGINT or SIGTERM, then exit
+// with status 128+signal. Handling stops when ctx is cancelled.
+func HandleSignals(ctx context.Context, safeStates map[PinWriter]int) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(sig)
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case s := <-sig:
+			for pin, val := range safeStates {
+				if err := pin.Write(val); err != nil {
+					log.Println(err)
+				}
+			}
+
+			if err := CloseAll(); err != nil {
+				log.Println(err)
+			}
+
+			code := 1
+			if n, ok := s.(syscall.Signal); ok {
+				code = 128 + int(n)
+			}
+			os.Exit(code)
+		}
+	}()
+}
+
+// Delivers a signal to another process on every edge, e.g. to a supervised sidecar without GPIO access
+type EdgeSignal struct {
+	tr   PinTrigger
+	done chan struct{}
+}
+
+func SignalOnEdge(pin PinReadTrigger, edge Trigger, proc *os.Process, sig os.Signal) (*EdgeSignal, error) {
+	tr, err := pin.Trigger(edge)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EdgeSignal{
+		tr:   tr,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		for range tr.Ch() {
+			if err := proc.Signal(sig); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+func (s *EdgeSignal) Close() error {
+	err := s.tr.Close()
+	<-s.done
+	return err
+}
@@ -0,0 +1,341 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/e-asphyx/gpio"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Pin event tagged with the name it was watched under
+type Event struct {
+	Pin       string
+	Value     int
+	Timestamp time.Time
+	Seq       uint64
+}
+
+// Event output. Send is called from a single goroutine per sink and must not retain the slice.
+type Sink interface {
+	Send(events []Event) error
+}
+
+// Returned by sinks which delivered part of a batch, only failed events are retried
+type PartialError struct {
+	Failed []int // indices into the batch
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d events failed: %v", len(e.Failed), e.Err)
+}
+
+// Events from i on weren't delivered
+func failedFrom(i, n int, err error) error {
+	pe := &PartialError{Err: err}
+	for ; i < n; i++ {
+		pe.Failed = append(pe.Failed, i)
+	}
+	return pe
+}
+
+// Function adapter
+type Func func(events []Event) error
+
+func (f Func) Send(events []Event) error {
+	return f(events)
+}
+
+// Events passed to a sink. Zero value matches everything.
+type Filter struct {
+	Pins []string     // empty for all
+	Edge gpio.Trigger // EdgeRising or EdgeFalling to match single edge
+}
+
+func (f *Filter) Match(ev *Event) bool {
+	if len(f.Pins) != 0 {
+		found := false
+		for _, p := range f.Pins {
+			if p == ev.Pin {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	switch f.Edge {
+	case gpio.EdgeRising:
+		return ev.Value != 0
+	case gpio.EdgeFalling:
+		return ev.Value == 0
+	}
+	return true
+}
+
+const DefaultQueue = 256
+
+type Options struct {
+	Filter        Filter
+	BatchSize     int           // max events per Send, 1 if zero
+	FlushInterval time.Duration // max delay of incomplete batch, zero sends what is queued immediately
+	Retries       int           // extra attempts after failed Send
+	RetryDelay    time.Duration // doubled after each attempt
+	Queue         int           // pending events, newer ones are dropped when full. DefaultQueue if zero.
+}
+
+type output struct {
+	sink  Sink
+	opts  Options
+	queue chan Event
+}
+
+// Fans out events of watched pins to sinks
+type Dispatcher struct {
+	watcher *gpio.Watcher
+	mutex   sync.Mutex
+	outputs []*output
+	closed  bool
+	wg      sync.WaitGroup
+	done    chan struct{}
+}
+
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{
+		watcher: gpio.NewWatcher(),
+		done:    make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Add active trigger. The dispatcher takes ownership and closes it on Close.
+func (d *Dispatcher) Add(name string, tr gpio.PinTrigger) error {
+	return d.watcher.Add(name, tr)
+}
+
+func (d *Dispatcher) Watch(name string, pin gpio.PinReadTrigger, edge gpio.Trigger) error {
+	return d.watcher.Watch(name, pin, edge)
+}
+
+func (d *Dispatcher) Unwatch(name string) error {
+	return d.watcher.Remove(name)
+}
+
+// Sinks implementing io.Closer are closed on dispatcher Close
+func (d *Dispatcher) AddSink(s Sink, opts Options) {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	if opts.Queue < 1 {
+		opts.Queue = DefaultQueue
+	}
+
+	o := &output{
+		sink:  s,
+		opts:  opts,
+		queue: make(chan Event, opts.Queue),
+	}
+
+	d.mutex.Lock()
+	if d.closed {
+		close(o.queue)
+	} else {
+		d.outputs = append(d.outputs, o)
+	}
+	d.mutex.Unlock()
+
+	d.wg.Add(1)
+	go d.serve(o)
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for {
+		name, gev, err := d.watcher.Next(context.Background())
+		if err != nil {
+			break
+		}
+
+		ev := Event{Pin: name, Value: gev.Value, Timestamp: gev.Timestamp, Seq: gev.Seq}
+
+		d.mutex.Lock()
+		for _, o := range d.outputs {
+			if o.opts.Filter.Match(&ev) && len(o.queue) != cap(o.queue) {
+				o.queue <- ev
+			}
+		}
+		d.mutex.Unlock()
+	}
+
+	d.mutex.Lock()
+	for _, o := range d.outputs {
+		close(o.queue)
+	}
+	d.outputs = nil
+	d.closed = true
+	d.mutex.Unlock()
+}
+
+func (d *Dispatcher) serve(o *output) {
+	defer d.wg.Done()
+
+	var (
+		batch []Event
+		timer *time.Timer
+		flush <-chan time.Time
+	)
+
+	send := func() {
+		if timer != nil {
+			timer.Stop()
+			flush = nil
+		}
+		if len(batch) != 0 {
+			if err := o.send(batch); err != nil {
+				log.Println(err)
+			}
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case ev, ok := <-o.queue:
+			if !ok {
+				send()
+				return
+			}
+
+			batch = append(batch, ev)
+			if len(batch) >= o.opts.BatchSize {
+				send()
+				break
+			}
+
+			if o.opts.FlushInterval <= 0 {
+				// take what is already queued
+				for len(batch) < o.opts.BatchSize && len(o.queue) != 0 {
+					if ev, ok := <-o.queue; ok {
+						batch = append(batch, ev)
+					}
+				}
+				send()
+			} else if flush == nil {
+				if timer == nil {
+					timer = time.NewTimer(o.opts.FlushInterval)
+				} else {
+					timer.Reset(o.opts.FlushInterval)
+				}
+				flush = timer.C
+			}
+
+		case <-flush:
+			flush = nil
+			send()
+		}
+	}
+}
+
+func (o *output) send(batch []Event) error {
+	delay := o.opts.RetryDelay
+	err := o.sink.Send(batch)
+	for i := 0; err != nil && i < o.opts.Retries; i++ {
+		// don't repeat delivered events
+		if pe, ok := err.(*PartialError); ok {
+			rest := make([]Event, 0, len(pe.Failed))
+			for _, j := range pe.Failed {
+				if j >= 0 && j < len(batch) {
+					rest = append(rest, batch[j])
+				}
+			}
+			batch = rest
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+		err = o.sink.Send(batch)
+	}
+	return err
+}
+
+// Stops watching, flushes pending batches and closes sinks
+func (d *Dispatcher) Close() error {
+	d.mutex.Lock()
+	sinks := make([]Sink, len(d.outputs))
+	for i, o := range d.outputs {
+		sinks[i] = o.sink
+	}
+	d.mutex.Unlock()
+
+	err := d.watcher.Close()
+	<-d.done
+	d.wg.Wait()
+
+	for _, s := range sinks {
+		if c, ok := s.(io.Closer); ok {
+			if e := c.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// Writes events as JSON lines
+func Writer(w io.Writer) Sink {
+	enc := json.NewEncoder(w)
+	return Func(func(events []Event) error {
+		for i := range events {
+			if err := enc.Encode(&events[i]); err != nil {
+				return failedFrom(i, len(events), err)
+			}
+		}
+		return nil
+	})
+}
+
+// Runs command per event, up to cmd.MaxConcurrent at once and killed after cmd.Timeout
+func Exec(cmd *gpio.ExecCommand) Sink {
+	return Func(func(events []Event) error {
+		var (
+			wg     sync.WaitGroup
+			mutex  sync.Mutex
+			failed []int
+			first  error
+		)
+
+		for i := range events {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				ev := &events[i]
+				err := cmd.Run(context.Background(), ev.Pin, gpio.Event{Value: ev.Value, Timestamp: ev.Timestamp, Seq: ev.Seq})
+				if err != nil {
+					mutex.Lock()
+					failed = append(failed, i)
+					if first == nil {
+						first = err
+					}
+					mutex.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if failed == nil {
+			return nil
+		}
+		sort.Ints(failed)
+		return &PartialError{Failed: failed, Err: first}
+	})
+}
@@ -0,0 +1,82 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"unix":     func(t time.Time) int64 { return t.Unix() },
+	"unixNano": func(t time.Time) int64 { return t.UnixNano() },
+	"rfc3339":  func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+	"bool":     func(v int) bool { return v != 0 },
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+}
+
+// Payload or topic rendered from text/template over Event, i.e.
+// `{"pin":{{json .Pin}},"on":{{bool .Value}},"ts":{{unix .Timestamp}}}`.
+// Available functions: json, unix, unixNano, rfc3339, bool, lower, upper.
+type Template struct {
+	t *template.Template
+}
+
+func ParseTemplate(text string) (*Template, error) {
+	t, err := template.New("payload").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{t: t}, nil
+}
+
+func (t *Template) Render(ev *Event) (string, error) {
+	var buf bytes.Buffer
+	if err := t.t.Execute(&buf, ev); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Writes rendered events one per line
+func TemplateWriter(w io.Writer, t *Template) Sink {
+	return Func(func(events []Event) error {
+		for i := range events {
+			s, err := t.Render(&events[i])
+			if err != nil {
+				return failedFrom(i, len(events), err)
+			}
+			if _, err = io.WriteString(w, s+"\n"); err != nil {
+				return failedFrom(i, len(events), err)
+			}
+		}
+		return nil
+	})
+}
+
+// Adapts message oriented clients (MQTT, HTTP) by rendering topic and body of each event
+func Publisher(topic, body *Template, publish func(topic, payload string) error) Sink {
+	return Func(func(events []Event) error {
+		for i := range events {
+			tp, err := topic.Render(&events[i])
+			if err != nil {
+				return failedFrom(i, len(events), err)
+			}
+			b, err := body.Render(&events[i])
+			if err != nil {
+				return failedFrom(i, len(events), err)
+			}
+			if err = publish(tp, b); err != nil {
+				return failedFrom(i, len(events), err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,190 @@
+package gpio
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const lineBiasFlags = LinePullUp | LinePullDown | LineBiasDisabled
+
+var biasNames = map[LineFlags]string{
+	0:                "as-is",
+	LinePullUp:       "pull-up",
+	LinePullDown:     "pull-down",
+	LineBiasDisabled: "disabled",
+}
+
+// Configuration of a single line
+type LineState struct {
+	Offset    int    `json:"offset"`
+	Name      string `json:"name,omitempty"`
+	Consumer  string `json:"consumer,omitempty"`
+	Direction string `json:"direction"`
+	Bias      string `json:"bias"`
+	ActiveLow bool   `json:"active_low,omitempty"`
+	// -1 if line is used by someone else and can't be read
+	Value int `json:"value"`
+}
+
+// Configuration of all lines of a chip
+type Snapshot struct {
+	Chip  string      `json:"chip"`
+	Label string      `json:"label"`
+	Lines []LineState `json:"lines"`
+}
+
+// Single difference between snapshots
+type LineDiff struct {
+	Offset int
+	Name   string
+	Field  string
+	Old    string
+	New    string
+}
+
+func (d LineDiff) String() string {
+	return fmt.Sprintf("line %d (%s): %s %s -> %s", d.Offset, d.Name, d.Field, d.Old, d.New)
+}
+
+// Capture state of all lines. Unused lines are briefly requested to read their levels.
+func (chip *Chip) Snapshot() (*Snapshot, error) {
+	lines, err := chip.Lines()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{
+		Chip:  chip.Name,
+		Label: chip.Label,
+		Lines: make([]LineState, len(lines)),
+	}
+
+	for i, li := range lines {
+		st := LineState{
+			Offset:    li.Offset,
+			Name:      li.Name,
+			Consumer:  li.Consumer,
+			Direction: dirNames[li.Direction()],
+			Bias:      biasNames[li.Flags&lineBiasFlags],
+			ActiveLow: li.Flags&LineActiveLow != 0,
+			Value:     -1,
+		}
+
+		if li.Flags&LineUsed == 0 {
+			l, err := chip.RequestLine(li.Offset)
+			if err == nil {
+				if v, err := l.read(); err == nil {
+					st.Value = v
+				}
+				l.Close()
+			}
+		}
+
+		s.Lines[i] = st
+	}
+
+	return s, nil
+}
+
+// Differences from s to other
+func (s *Snapshot) Diff(other *Snapshot) []LineDiff {
+	var diff []LineDiff
+
+	old := make(map[int]*LineState, len(s.Lines))
+	for i := range s.Lines {
+		old[s.Lines[i].Offset] = &s.Lines[i]
+	}
+
+	for i := range other.Lines {
+		n := &other.Lines[i]
+		o, ok := old[n.Offset]
+		if !ok {
+			diff = append(diff, LineDiff{Offset: n.Offset, Name: n.Name, Field: "line", Old: "absent", New: "present"})
+			continue
+		}
+		delete(old, n.Offset)
+
+		add := func(field, ov, nv string) {
+			if ov != nv {
+				diff = append(diff, LineDiff{Offset: n.Offset, Name: n.Name, Field: field, Old: ov, New: nv})
+			}
+		}
+
+		add("consumer", o.Consumer, n.Consumer)
+		add("direction", o.Direction, n.Direction)
+		add("bias", o.Bias, n.Bias)
+		add("active_low", strconv.FormatBool(o.ActiveLow), strconv.FormatBool(n.ActiveLow))
+		if o.Value >= 0 && n.Value >= 0 {
+			add("value", strconv.Itoa(o.Value), strconv.Itoa(n.Value))
+		}
+	}
+
+	for _, o := range old {
+		diff = append(diff, LineDiff{Offset: o.Offset, Name: o.Name, Field: "line", Old: "present", New: "absent"})
+	}
+
+	return diff
+}
+
+// Restore direction, bias and output levels of lines not used by anybody. In dry run mode
+// output levels of all snapshot lines are recorded and the chip is left untouched.
+func (chip *Chip) ApplySnapshot(s *Snapshot) error {
+	if DryRun() {
+		for _, st := range s.Lines {
+			if st.Direction == "out" && st.Value >= 0 {
+				RecordDryRunWrite(fmt.Sprintf("%s:%d", chip.Name, st.Offset), st.Value)
+			}
+		}
+		return nil
+	}
+
+	for _, st := range s.Lines {
+		li, err := chip.LineInfo(st.Offset)
+		if err != nil {
+			return err
+		}
+		if li.Flags&LineUsed != 0 {
+			continue
+		}
+
+		var flags LineFlags
+		for f, name := range biasNames {
+			if name == st.Bias {
+				flags |= f
+			}
+		}
+		if st.ActiveLow {
+			flags |= LineActiveLow
+		}
+		if st.Direction == "out" {
+			flags |= LineOutput
+		} else {
+			flags |= LineInput
+		}
+
+		l, err := chip.RequestLine(st.Offset)
+		if err != nil {
+			return err
+		}
+
+		// values are physical levels while output value is affected by active low flag
+		value := st.Value
+		if value < 0 {
+			value, _ = l.read()
+		}
+		if st.ActiveLow {
+			value ^= 1
+		}
+
+		l.mutex.Lock()
+		err = l.setConfig(flags, 0, value)
+		l.mutex.Unlock()
+
+		l.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package gpio
+
+import (
+	"testing"
+)
+
+func TestApplySnapshotDryRun(t *testing.T) {
+	// no character device behind the chip, any ioctl would panic
+	chip := &Chip{Name: "gpiochip9", NumLines: 4}
+	s := &Snapshot{
+		Chip: "gpiochip9",
+		Lines: []LineState{
+			{Offset: 0, Direction: "out", Value: 1},
+			{Offset: 1, Direction: "in", Value: 0},
+			{Offset: 2, Direction: "out", Value: 0, ActiveLow: true},
+			{Offset: 3, Direction: "out", Value: -1},
+		},
+	}
+
+	ClearDryRunWrites()
+	SetDryRun(true)
+	err := chip.ApplySnapshot(s)
+	SetDryRun(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DryRunRecord{{Pin: "gpiochip9:0", Value: 1}, {Pin: "gpiochip9:2", Value: 0}}
+	got := DryRunWrites()
+	ClearDryRunWrites()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Pin != want[i].Pin || got[i].Value != want[i].Value {
+			t.Errorf("record %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	old := &Snapshot{Lines: []LineState{
+		{Offset: 0, Name: "led", Direction: "out", Bias: "as-is", Value: 0},
+		{Offset: 1, Name: "btn", Direction: "in", Bias: "pull-up", Value: 1},
+	}}
+	cur := &Snapshot{Lines: []LineState{
+		{Offset: 0, Name: "led", Direction: "out", Bias: "as-is", Value: 1},
+		{Offset: 1, Name: "btn", Direction: "in", Bias: "pull-up", Value: 1},
+	}}
+
+	diff := old.Diff(cur)
+	if len(diff) != 1 || diff[0].Offset != 0 || diff[0].Old != "0" || diff[0].New != "1" {
+		t.Errorf("got %v", diff)
+	}
+}
@@ -0,0 +1,295 @@
+package gpio
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio/i2c"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultI2CSpeed     = 100000
+	DefaultStretchLimit = 10 * time.Millisecond
+)
+
+var ErrClockStretch = errors.New("Clock stretching timeout")
+
+// Bit-banged I2C master, implements i2c.Bus. Lines are driven open-drain by switching pins
+// between low output and input, so external (or bcm2708 internal) pull-ups are required.
+// Pins without direction control are assumed to be open-drain outputs already.
+type SoftI2C struct {
+	scl, sda PinReadWriter
+
+	// Longest time a slave may hold SCL low
+	StretchLimit time.Duration
+
+	mutex sync.Mutex
+	half  time.Duration
+}
+
+func NewSoftI2C(scl, sda PinReadWriter) (*SoftI2C, error) {
+	s := &SoftI2C{
+		scl:          scl,
+		sda:          sda,
+		StretchLimit: DefaultStretchLimit,
+	}
+	s.SetSpeed(DefaultI2CSpeed)
+
+	if err := s.release(s.sda); err != nil {
+		return nil, err
+	}
+	if err := s.release(s.scl); err != nil {
+		return nil, err
+	}
+
+	// a slave interrupted mid-byte may still hold SDA low
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SoftI2C) SetSpeed(hz uint32) {
+	if hz == 0 {
+		hz = DefaultI2CSpeed
+	}
+	s.mutex.Lock()
+	s.half = time.Second / time.Duration(2*hz)
+	s.mutex.Unlock()
+}
+
+func (s *SoftI2C) release(pin PinReadWriter) error {
+	if p, ok := pin.(DirectionSetter); ok {
+		return p.SetDirection(DirIn)
+	}
+	return pin.Write(1)
+}
+
+func (s *SoftI2C) low(pin PinReadWriter) error {
+	if p, ok := pin.(DirectionSetter); ok {
+		if err := p.SetDirection(DirOut); err != nil {
+			return err
+		}
+	}
+	return pin.Write(0)
+}
+
+func (s *SoftI2C) delay() {
+	sleepUntil(time.Now().Add(s.half))
+}
+
+// Release SCL and wait for slaves to let it go
+func (s *SoftI2C) sclHigh() error {
+	if err := s.release(s.scl); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(s.StretchLimit)
+	for {
+		v, err := s.scl.Read()
+		if err != nil {
+			return err
+		}
+		if v != 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrClockStretch
+		}
+	}
+}
+
+func (s *SoftI2C) setSDA(v int) error {
+	if v != 0 {
+		return s.release(s.sda)
+	}
+	return s.low(s.sda)
+}
+
+// Clock out up to nine bits until SDA is released, then issue stop
+func (s *SoftI2C) recover() error {
+	for i := 0; i < 9; i++ {
+		v, err := s.sda.Read()
+		if err != nil {
+			return err
+		}
+		if v != 0 {
+			break
+		}
+
+		if err = s.low(s.scl); err != nil {
+			return err
+		}
+		s.delay()
+		if err = s.sclHigh(); err != nil {
+			return err
+		}
+		s.delay()
+	}
+	return s.stop()
+}
+
+// SCL is expected high
+func (s *SoftI2C) start() error {
+	if err := s.setSDA(1); err != nil {
+		return err
+	}
+	if err := s.sclHigh(); err != nil {
+		return err
+	}
+	s.delay()
+	if err := s.setSDA(0); err != nil {
+		return err
+	}
+	s.delay()
+	return s.low(s.scl)
+}
+
+func (s *SoftI2C) stop() error {
+	if err := s.low(s.scl); err != nil {
+		return err
+	}
+	if err := s.setSDA(0); err != nil {
+		return err
+	}
+	s.delay()
+	if err := s.sclHigh(); err != nil {
+		return err
+	}
+	s.delay()
+	if err := s.setSDA(1); err != nil {
+		return err
+	}
+	s.delay()
+	return nil
+}
+
+// Clock one bit, SCL is low before and after
+func (s *SoftI2C) bit(out int) (int, error) {
+	if err := s.setSDA(out); err != nil {
+		return 0, err
+	}
+	s.delay()
+	if err := s.sclHigh(); err != nil {
+		return 0, err
+	}
+	in, err := s.sda.Read()
+	if err != nil {
+		return 0, err
+	}
+	s.delay()
+	return in, s.low(s.scl)
+}
+
+// Returns true if acknowledged
+func (s *SoftI2C) writeByte(b byte) (bool, error) {
+	for i := 7; i >= 0; i-- {
+		if _, err := s.bit(int(b>>uint(i)) & 1); err != nil {
+			return false, err
+		}
+	}
+	nack, err := s.bit(1)
+	return nack == 0, err
+}
+
+func (s *SoftI2C) readByte(ack bool) (byte, error) {
+	var b byte
+	for i := 0; i < 8; i++ {
+		v, err := s.bit(1)
+		if err != nil {
+			return 0, err
+		}
+		b = b<<1 | byte(v&1)
+	}
+
+	a := 1
+	if ack {
+		a = 0
+	}
+	_, err := s.bit(a)
+	return b, err
+}
+
+// Must be called with mutex held
+func (s *SoftI2C) transaction(addr uint16, w, r []byte) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	if len(w) != 0 || len(r) == 0 {
+		ack, err := s.writeByte(byte(addr << 1))
+		if err != nil {
+			return err
+		}
+		if !ack {
+			return i2c.ErrNack
+		}
+
+		for _, b := range w {
+			ack, err := s.writeByte(b)
+			if err != nil {
+				return err
+			}
+			if !ack {
+				return i2c.ErrNack
+			}
+		}
+
+		if len(r) == 0 {
+			return nil
+		}
+
+		// repeated start, SCL is low
+		if err = s.setSDA(1); err != nil {
+			return err
+		}
+		s.delay()
+		if err = s.start(); err != nil {
+			return err
+		}
+	}
+
+	ack, err := s.writeByte(byte(addr<<1 | 1))
+	if err != nil {
+		return err
+	}
+	if !ack {
+		return i2c.ErrNack
+	}
+
+	for i := range r {
+		if r[i], err = s.readByte(i != len(r)-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 7 bit addresses only
+func (s *SoftI2C) WriteRead(addr uint16, w, r []byte) error {
+	if addr > i2c.MaxAddr7 {
+		return i2c.ErrAddress
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.transaction(addr, w, r)
+	if e := s.stop(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// Address only transaction, used by i2c.Scan for probing
+func (s *SoftI2C) WriteQuick(addr uint16, read bool) error {
+	if read {
+		var buf [1]byte
+		return s.WriteRead(addr, nil, buf[:])
+	}
+	return s.WriteRead(addr, nil, nil)
+}
+
+func (s *SoftI2C) Scan() ([]i2c.ScanResult, error) {
+	return i2c.Scan(s)
+}
@@ -0,0 +1,281 @@
+package gpio
+
+import (
+	"github.com/e-asphyx/gpio/i2c"
+	"testing"
+	"time"
+)
+
+// Register file slave (first written byte sets the pointer) reacting to line edges
+type i2cSim struct {
+	addr uint16
+	regs [256]byte
+	ptr  byte
+
+	// master side, true if released
+	sclRel, sdaRel bool
+	// slave side
+	sdaLow  bool
+	stretch int // reads SCL is held low for after each release, -1 for ever
+	held    int
+
+	scl, sda int
+
+	active   bool
+	addrByte bool
+	read     bool
+	bitn     int
+	shift    byte
+	tx       byte
+	ack      bool
+	writes   int
+}
+
+type i2cLine struct {
+	sim   *i2cSim
+	isSCL bool
+}
+
+func newI2CSim(addr uint16) *i2cSim {
+	return &i2cSim{addr: addr, sclRel: true, sdaRel: true, scl: 1, sda: 1}
+}
+
+func (s *i2cSim) lines() (scl, sda *i2cLine) {
+	return &i2cLine{sim: s, isSCL: true}, &i2cLine{sim: s}
+}
+
+func (l *i2cLine) Direction() (Direction, error) {
+	return DirOut, nil
+}
+
+// Writing 1 releases the line
+func (l *i2cLine) Write(value int) error {
+	s := l.sim
+	if l.isSCL {
+		if value != 0 && !s.sclRel {
+			s.held = s.stretch
+		}
+		s.sclRel = value != 0
+	} else {
+		s.sdaRel = value != 0
+	}
+	s.update()
+	return nil
+}
+
+func (l *i2cLine) Read() (int, error) {
+	s := l.sim
+	if l.isSCL {
+		if s.held > 0 {
+			s.held--
+			s.update()
+		}
+		return s.scl, nil
+	}
+	return s.sda, nil
+}
+
+func (s *i2cSim) sdaLevel() int {
+	if s.sdaRel && !s.sdaLow {
+		return 1
+	}
+	return 0
+}
+
+func (s *i2cSim) update() {
+	scl := 0
+	if s.sclRel && s.held == 0 {
+		scl = 1
+	}
+	sda := s.sdaLevel()
+
+	switch {
+	case scl != s.scl:
+		s.scl = scl
+		s.sda = sda
+		if scl != 0 {
+			s.rise()
+		} else {
+			s.fall()
+			s.sda = s.sdaLevel()
+		}
+
+	case sda != s.sda:
+		s.sda = sda
+		if scl == 0 {
+			break
+		}
+		if sda == 0 {
+			// SCL falling after start isn't a bit yet
+			s.active, s.addrByte, s.read = true, true, false
+			s.bitn, s.shift = -1, 0
+		} else {
+			s.active = false
+		}
+		s.sdaLow = false
+	}
+}
+
+func (s *i2cSim) transmitting() bool {
+	return s.read && !s.addrByte
+}
+
+func (s *i2cSim) rise() {
+	if !s.active {
+		return
+	}
+	switch {
+	case s.bitn < 8 && !s.transmitting():
+		s.shift = s.shift<<1 | byte(s.sda)
+	case s.bitn == 8 && s.transmitting():
+		s.ack = s.sda == 0
+	}
+}
+
+func (s *i2cSim) drive(bit uint) {
+	s.sdaLow = s.tx&(1<<bit) == 0
+}
+
+func (s *i2cSim) fall() {
+	if !s.active {
+		return
+	}
+	done := s.bitn
+	s.bitn++
+
+	switch {
+	case done < 7:
+		if s.transmitting() {
+			s.drive(uint(6 - done))
+		}
+
+	case done == 7:
+		switch {
+		case s.addrByte:
+			if uint16(s.shift>>1) != s.addr {
+				s.active = false
+				return
+			}
+			s.read = s.shift&1 != 0
+			s.sdaLow = true
+		case s.read:
+			// master acknowledges
+			s.sdaLow = false
+		default:
+			if s.writes++; s.writes == 1 {
+				s.ptr = s.shift
+			} else {
+				s.regs[s.ptr] = s.shift
+				s.ptr++
+			}
+			s.sdaLow = true
+		}
+
+	default:
+		s.bitn = 0
+		s.sdaLow = false
+		if s.addrByte {
+			s.addrByte = false
+			s.writes = 0
+			s.ack = true
+		}
+		if s.transmitting() {
+			if !s.ack {
+				s.active = false
+				return
+			}
+			s.tx = s.regs[s.ptr]
+			s.ptr++
+			s.drive(7)
+		}
+	}
+}
+
+func newTestSoftI2C(t *testing.T, sim *i2cSim) *SoftI2C {
+	scl, sda := sim.lines()
+	s, err := NewSoftI2C(scl, sda)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetSpeed(1000000)
+	return s
+}
+
+func TestSoftI2CWriteRead(t *testing.T) {
+	sim := newI2CSim(0x50)
+	s := newTestSoftI2C(t, sim)
+	dev := i2c.Device{Bus: s, Addr: 0x50}
+
+	if err := dev.WriteReg(0x10, 0xde, 0xad, 0x01); err != nil {
+		t.Fatal(err)
+	}
+	if sim.regs[0x10] != 0xde || sim.regs[0x11] != 0xad || sim.regs[0x12] != 0x01 {
+		t.Errorf("registers % x", sim.regs[0x10:0x13])
+	}
+
+	var buf [3]byte
+	if err := dev.ReadReg(0x10, buf[:]); err != nil {
+		t.Fatal(err)
+	}
+	if buf != [3]byte{0xde, 0xad, 0x01} {
+		t.Errorf("read % x", buf)
+	}
+	if sim.active {
+		t.Error("no stop condition")
+	}
+
+	if err := s.WriteRead(0x51, []byte{0}, nil); err != i2c.ErrNack {
+		t.Errorf("absent device error %v", err)
+	}
+	if err := s.WriteRead(0x80, nil, nil); err != i2c.ErrAddress {
+		t.Errorf("10 bit address error %v", err)
+	}
+}
+
+func TestSoftI2CScan(t *testing.T) {
+	s := newTestSoftI2C(t, newI2CSim(0x3c))
+
+	res, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Addr != 0x3c {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestSoftI2CClockStretch(t *testing.T) {
+	sim := newI2CSim(0x50)
+	s := newTestSoftI2C(t, sim)
+	sim.regs[0] = 0x42
+
+	sim.stretch = 3
+	v, err := (&i2c.Device{Bus: s, Addr: 0x50}).ReadByteReg(0)
+	if err != nil || v != 0x42 {
+		t.Errorf("ReadByteReg() = %#x, %v", v, err)
+	}
+
+	sim.stretch = -1
+	s.StretchLimit = time.Millisecond
+	if err = s.WriteRead(0x50, []byte{0}, nil); err != ErrClockStretch {
+		t.Errorf("got %v, want ErrClockStretch", err)
+	}
+}
+
+// Slave left mid-read with SDA low must be clocked out on open
+func TestSoftI2CRecover(t *testing.T) {
+	sim := newI2CSim(0x50)
+	sim.active, sim.read = true, true
+	sim.bitn, sim.tx = 3, 0x00
+	sim.sdaLow, sim.sda = true, 0
+
+	s := newTestSoftI2C(t, sim)
+	if sim.sda != 1 || sim.active {
+		t.Fatalf("bus not released, SDA %d", sim.sda)
+	}
+
+	sim.regs[7] = 0x99
+	if v, err := (&i2c.Device{Bus: s, Addr: 0x50}).ReadByteReg(7); err != nil || v != 0x99 {
+		t.Errorf("ReadByteReg() = %#x, %v", v, err)
+	}
+}
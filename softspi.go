@@ -0,0 +1,214 @@
+package gpio
+
+import (
+	"github.com/e-asphyx/gpio/spi"
+	"sync"
+	"time"
+)
+
+// Bit-banged SPI master, implements spi.MessageConn. Chip select is active low.
+type SoftSPI struct {
+	sclk, mosi PinWriter
+	miso       PinReader
+	cs         PinWriter
+
+	mutex    sync.Mutex
+	mode     spi.Mode
+	lsbFirst bool
+	half     time.Duration // half clock period, zero runs as fast as pins allow
+}
+
+// Any pin except sclk may be nil
+func NewSoftSPI(sclk, mosi PinWriter, miso PinReader, cs PinWriter) (*SoftSPI, error) {
+	s := &SoftSPI{
+		sclk: sclk,
+		mosi: mosi,
+		miso: miso,
+		cs:   cs,
+	}
+
+	if err := s.idle(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SoftSPI) cpol() int {
+	if s.mode == spi.Mode2 || s.mode == spi.Mode3 {
+		return 1
+	}
+	return 0
+}
+
+func (s *SoftSPI) cpha() bool {
+	return s.mode == spi.Mode1 || s.mode == spi.Mode3
+}
+
+// Must be called with mutex held
+func (s *SoftSPI) idle() error {
+	if s.cs != nil {
+		if err := s.cs.Write(1); err != nil {
+			return err
+		}
+	}
+	return s.sclk.Write(s.cpol())
+}
+
+func (s *SoftSPI) SetMode(mode spi.Mode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.mode = mode
+	return s.sclk.Write(s.cpol())
+}
+
+func (s *SoftSPI) SetLSBFirst(lsb bool) {
+	s.mutex.Lock()
+	s.lsbFirst = lsb
+	s.mutex.Unlock()
+}
+
+// Upper bound of clock frequency, zero removes the limit
+func (s *SoftSPI) SetSpeed(hz uint32) {
+	s.mutex.Lock()
+	if hz == 0 {
+		s.half = 0
+	} else {
+		s.half = time.Second / time.Duration(2*hz)
+	}
+	s.mutex.Unlock()
+}
+
+func (s *SoftSPI) delay(half time.Duration) {
+	if half > 0 {
+		sleepUntil(time.Now().Add(half))
+	}
+}
+
+// Must be called with mutex held
+func (s *SoftSPI) transferByte(out byte, half time.Duration) (byte, error) {
+	var in byte
+	idle := s.cpol()
+
+	for i := 0; i < 8; i++ {
+		bit := uint(7 - i)
+		if s.lsbFirst {
+			bit = uint(i)
+		}
+		v := int(out>>bit) & 1
+
+		// CPHA=0 samples on the leading edge, CPHA=1 on the trailing one
+		if !s.cpha() && s.mosi != nil {
+			if err := s.mosi.Write(v); err != nil {
+				return 0, err
+			}
+		}
+		s.delay(half)
+		if err := s.sclk.Write(idle ^ 1); err != nil {
+			return 0, err
+		}
+
+		if s.cpha() {
+			if s.mosi != nil {
+				if err := s.mosi.Write(v); err != nil {
+					return 0, err
+				}
+			}
+		} else if s.miso != nil {
+			r, err := s.miso.Read()
+			if err != nil {
+				return 0, err
+			}
+			in |= byte(r&1) << bit
+		}
+
+		s.delay(half)
+		if err := s.sclk.Write(idle); err != nil {
+			return 0, err
+		}
+
+		if s.cpha() && s.miso != nil {
+			r, err := s.miso.Read()
+			if err != nil {
+				return 0, err
+			}
+			in |= byte(r&1) << bit
+		}
+	}
+
+	return in, nil
+}
+
+// Must be called with mutex held
+func (s *SoftSPI) transfer(tx, rx []byte, n int, half time.Duration) error {
+	for i := 0; i < n; i++ {
+		var out byte
+		if i < len(tx) {
+			out = tx[i]
+		}
+		in, err := s.transferByte(out, half)
+		if err != nil {
+			return err
+		}
+		if i < len(rx) {
+			rx[i] = in
+		}
+	}
+	return nil
+}
+
+func (s *SoftSPI) Transfer(data []byte) ([]byte, error) {
+	rx := make([]byte, len(data))
+	err := s.Message([]spi.Transfer{{Tx: data, Rx: rx}})
+	return rx, err
+}
+
+func (s *SoftSPI) Message(xfers []spi.Transfer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	selected := false
+	defer func() {
+		if selected {
+			s.idle()
+		}
+	}()
+
+	for i, x := range xfers {
+		if !selected && s.cs != nil {
+			if err := s.cs.Write(0); err != nil {
+				return err
+			}
+			selected = true
+		}
+
+		half := s.half
+		if x.Speed != 0 {
+			half = time.Second / time.Duration(2*x.Speed)
+		}
+
+		n := len(x.Tx)
+		if len(x.Rx) > n {
+			n = len(x.Rx)
+		}
+		if err := s.transfer(x.Tx, x.Rx, n, half); err != nil {
+			return err
+		}
+
+		if x.Delay > 0 {
+			time.Sleep(x.Delay)
+		}
+
+		last := i == len(xfers)-1
+		if x.CSChange != last && selected {
+			if err := s.cs.Write(1); err != nil {
+				return err
+			}
+			selected = false
+		}
+	}
+
+	// CSChange on the last segment keeps the device selected
+	selected = false
+	return nil
+}
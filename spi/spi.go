@@ -0,0 +1,52 @@
+package spi
+
+import (
+	"os"
+	"time"
+)
+
+// Clock polarity and phase
+type Mode int
+
+const (
+	Mode0 Mode = iota // CPOL=0, CPHA=0
+	Mode1             // CPOL=0, CPHA=1
+	Mode2             // CPOL=1, CPHA=0
+	Mode3             // CPOL=1, CPHA=1
+)
+
+// Single segment of a message
+type Transfer struct {
+	Tx    []byte // may be nil to clock out zeroes
+	Rx    []byte // may be nil if received data is not needed
+	Speed uint32 // Hz, 0 means connection default
+	Delay time.Duration
+	// Deassert chip select after this segment even if it's not the last one,
+	// or keep it asserted after the last segment
+	CSChange bool
+}
+
+// Full duplex SPI connection to a single device. Received data has the same length as transmitted.
+type Conn interface {
+	Transfer(data []byte) ([]byte, error)
+}
+
+// Connection able to execute multi segment messages with chip select held in between
+type MessageConn interface {
+	Conn
+	Message(xfers []Transfer) error
+}
+
+// Opens spidev device or calls fallback (typically bit-bang master) if spidev isn't available
+func OpenWithFallback(bus, cs int, mode Mode, speed uint32, fallback func() (Conn, error)) (Conn, error) {
+	dev, err := Open(bus, cs, mode, speed)
+	if err == nil {
+		return dev, nil
+	}
+
+	if (os.IsNotExist(err) || os.IsPermission(err)) && fallback != nil {
+		return fallback()
+	}
+
+	return nil, err
+}
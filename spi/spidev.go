@@ -0,0 +1,202 @@
+package spi
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+const (
+	spiCPHA     = 0x01
+	spiCPOL     = 0x02
+	spiCSHigh   = 0x04
+	spiLSBFirst = 0x08
+	spiNoCS     = 0x40
+
+	spiIocWrMode        = 0x40016b01
+	spiIocWrBitsPerWord = 0x40016b03
+	spiIocWrMaxSpeedHz  = 0x40046b04
+
+	spiIocTransferSize = 32
+)
+
+// struct spi_ioc_transfer
+type spiIocTransfer struct {
+	txBuf       uint64
+	rxBuf       uint64
+	len         uint32
+	speedHz     uint32
+	delayUsecs  uint16
+	bitsPerWord uint8
+	csChange    uint8
+	txNbits     uint8
+	rxNbits     uint8
+	wordDelay   uint8
+	pad         uint8
+}
+
+var ErrLength = errors.New("Tx and Rx buffers length mismatch")
+
+// Kernel spidev device
+type Dev struct {
+	fd    *os.File
+	mode  uint8
+	speed uint32
+	mutex sync.Mutex
+}
+
+func spiIocMessage(n int) uintptr {
+	return uintptr(0x40006b00 | (n*spiIocTransferSize)<<16)
+}
+
+func (dev *Dev) ioctl(req, arg uintptr) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, dev.fd.Fd(), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Opens /dev/spidev<bus>.<cs>
+func Open(bus, cs int, mode Mode, speed uint32) (*Dev, error) {
+	fd, err := os.OpenFile(fmt.Sprintf("/dev/spidev%d.%d", bus, cs), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dev := &Dev{fd: fd}
+
+	if err = dev.SetMode(mode); err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	var bits uint8 = 8
+	if err = dev.ioctl(spiIocWrBitsPerWord, uintptr(unsafe.Pointer(&bits))); err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if err = dev.SetSpeed(speed); err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	runtime.SetFinalizer(dev, (*Dev).Close)
+	return dev, nil
+}
+
+func (dev *Dev) writeMode() error {
+	return dev.ioctl(spiIocWrMode, uintptr(unsafe.Pointer(&dev.mode)))
+}
+
+func (dev *Dev) SetMode(mode Mode) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	dev.mode = dev.mode&^(spiCPHA|spiCPOL) | uint8(mode)&(spiCPHA|spiCPOL)
+	return dev.writeMode()
+}
+
+func (dev *Dev) SetLSBFirst(lsb bool) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	if lsb {
+		dev.mode |= spiLSBFirst
+	} else {
+		dev.mode &^= spiLSBFirst
+	}
+	return dev.writeMode()
+}
+
+// Active high chip select
+func (dev *Dev) SetCSHigh(high bool) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	if high {
+		dev.mode |= spiCSHigh
+	} else {
+		dev.mode &^= spiCSHigh
+	}
+	return dev.writeMode()
+}
+
+// Disable chip select handling, i.e. when it's driven manually via GPIO
+func (dev *Dev) SetNoCS(nocs bool) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	if nocs {
+		dev.mode |= spiNoCS
+	} else {
+		dev.mode &^= spiNoCS
+	}
+	return dev.writeMode()
+}
+
+func (dev *Dev) SetSpeed(speed uint32) error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	err := dev.ioctl(spiIocWrMaxSpeedHz, uintptr(unsafe.Pointer(&speed)))
+	if err != nil {
+		return err
+	}
+	dev.speed = speed
+	return nil
+}
+
+func (dev *Dev) Transfer(data []byte) ([]byte, error) {
+	rx := make([]byte, len(data))
+	err := dev.Message([]Transfer{{Tx: data, Rx: rx}})
+	if err != nil {
+		return nil, err
+	}
+	return rx, nil
+}
+
+func (dev *Dev) Message(xfers []Transfer) error {
+	if len(xfers) == 0 {
+		return nil
+	}
+
+	msg := make([]spiIocTransfer, len(xfers))
+	for i, x := range xfers {
+		var n int
+		if len(x.Tx) != 0 {
+			n = len(x.Tx)
+			msg[i].txBuf = uint64(uintptr(unsafe.Pointer(&x.Tx[0])))
+		}
+		if len(x.Rx) != 0 {
+			if len(x.Tx) != 0 && len(x.Rx) != n {
+				return ErrLength
+			}
+			n = len(x.Rx)
+			msg[i].rxBuf = uint64(uintptr(unsafe.Pointer(&x.Rx[0])))
+		}
+
+		msg[i].len = uint32(n)
+		msg[i].speedHz = x.Speed
+		msg[i].delayUsecs = uint16(x.Delay.Nanoseconds() / 1000)
+		if x.CSChange {
+			msg[i].csChange = 1
+		}
+	}
+
+	dev.mutex.Lock()
+	err := dev.ioctl(spiIocMessage(len(msg)), uintptr(unsafe.Pointer(&msg[0])))
+	dev.mutex.Unlock()
+
+	runtime.KeepAlive(xfers)
+	return err
+}
+
+func (dev *Dev) Close() error {
+	return dev.fd.Close()
+}
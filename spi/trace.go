@@ -0,0 +1,82 @@
+package spi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrMessage = errors.New("Connection doesn't support messages")
+
+// Transfer or message seen by Traced. Messages are recorded as a whole.
+type TraceRecord struct {
+	Segments []Transfer // copies of Tx and Rx
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+func (r TraceRecord) String() string {
+	s := "spi"
+	for _, x := range r.Segments {
+		s += fmt.Sprintf(" tx[% x] rx[% x]", x.Tx, x.Rx)
+	}
+	s += fmt.Sprintf(" %v", r.Duration)
+	if r.Err != nil {
+		s += ": " + r.Err.Error()
+	}
+	return s
+}
+
+// Connection wrapper passing every transfer to a sink, e.g. func(r TraceRecord) { log.Println(r) }.
+// Message is forwarded only if the wrapped connection supports it.
+type TracedConn struct {
+	Conn Conn
+	Sink func(r TraceRecord)
+}
+
+func Traced(conn Conn, sink func(r TraceRecord)) *TracedConn {
+	return &TracedConn{Conn: conn, Sink: sink}
+}
+
+func copySegment(x Transfer) Transfer {
+	x.Tx = append([]byte(nil), x.Tx...)
+	x.Rx = append([]byte(nil), x.Rx...)
+	return x
+}
+
+func (t *TracedConn) Transfer(data []byte) ([]byte, error) {
+	start := time.Now()
+	rx, err := t.Conn.Transfer(data)
+
+	t.Sink(TraceRecord{
+		Segments: []Transfer{copySegment(Transfer{Tx: data, Rx: rx})},
+		Start:    start,
+		Duration: time.Now().Sub(start),
+		Err:      err,
+	})
+	return rx, err
+}
+
+func (t *TracedConn) Message(xfers []Transfer) error {
+	mc, ok := t.Conn.(MessageConn)
+	if !ok {
+		return ErrMessage
+	}
+
+	start := time.Now()
+	err := mc.Message(xfers)
+
+	rec := TraceRecord{
+		Segments: make([]Transfer, len(xfers)),
+		Start:    start,
+		Duration: time.Now().Sub(start),
+		Err:      err,
+	}
+	for i, x := range xfers {
+		rec.Segments[i] = copySegment(x)
+	}
+
+	t.Sink(rec)
+	return err
+}
@@ -0,0 +1,143 @@
+package gpio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Serializable pin state
+type PinState struct {
+	Num       int    `json:"num"`
+	Name      string `json:"name,omitempty"`
+	Direction string `json:"direction"`
+	Value     int    `json:"value"`
+	Edge      string `json:"edge"`
+	Pull      string `json:"pull"` // "unsupported" if backend can't read it
+}
+
+// Pull value of backends without bias readback
+const PullUnsupported = "unsupported"
+
+var ErrStateDirection = errors.New("Invalid direction in pin state")
+
+var (
+	dirNames  = map[Direction]string{DirIn: "in", DirOut: "out"}
+	edgeNames = map[Trigger]string{EdgeNone: "none", EdgeRising: "rising", EdgeFalling: "falling", EdgeBoth: "both"}
+)
+
+func (pin *Pin) State() (*PinState, error) {
+	dir, err := pin.Direction()
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := pin.read()
+	if err != nil {
+		return nil, err
+	}
+
+	edge := EdgeNone
+	if pin.ch != nil {
+		edge = pin.trigger
+	}
+
+	return &PinState{
+		Num:       pin.idx,
+		Name:      pin.name,
+		Direction: dirNames[dir],
+		Value:     val,
+		Edge:      edgeNames[edge],
+		Pull:      PullUnsupported, // sysfs has no bias control
+	}, nil
+}
+
+// Write state of all managed pins as JSON
+func DumpState(w io.Writer) error {
+	pins := ManagedPins()
+	state := make([]*PinState, len(pins))
+
+	for i, pin := range pins {
+		st, err := pin.State()
+		if err != nil {
+			return err
+		}
+		state[i] = st
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(state)
+}
+
+// Open pins listed in JSON state, restore names, directions and output levels.
+// Edge settings of pins with active triggers are left as is.
+func LoadState(r io.Reader) ([]*Pin, error) {
+	var state []*PinState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, err
+	}
+
+	pins := make([]*Pin, 0, len(state))
+	for _, st := range state {
+		managedMutex.Lock()
+		pin := managed[st.Num]
+		managedMutex.Unlock()
+
+		if pin == nil {
+			var err error
+			pin, err = NewPin(st.Num)
+			if err != nil {
+				return pins, err
+			}
+		}
+
+		pins = append(pins, pin)
+		pin.name = st.Name
+
+		if pin.ch != nil {
+			continue
+		}
+
+		if st.Direction != "in" && st.Direction != "out" {
+			return pins, ErrStateDirection
+		}
+
+		// dry run leaves pins as they are, only output levels are recorded
+		if pin.dryRun || DryRun() {
+			if st.Direction == "out" {
+				RecordDryRunWrite(pin.String(), st.Value)
+			}
+			continue
+		}
+
+		switch st.Direction {
+		case "in":
+			if err := pin.SetDirection(DirIn); err != nil {
+				return pins, err
+			}
+
+			for edge, name := range edgeNames {
+				if name == st.Edge {
+					if err := pin.setEdge(edge); err != nil {
+						return pins, err
+					}
+				}
+			}
+
+		case "out":
+			// "high" and "low" switch to output with given level without glitch
+			dirStr := "low"
+			if st.Value != 0 {
+				dirStr = "high"
+			}
+			if err := openWriteCloseFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin.idx), dirStr); err != nil {
+				return pins, err
+			}
+			pin.dir = DirOut
+		}
+	}
+
+	return pins, nil
+}
@@ -0,0 +1,86 @@
+package gpio
+
+import (
+	"strings"
+	"testing"
+)
+
+// Pins registered as managed without sysfs behind them
+func fakeManaged(nums ...int) func() {
+	managedMutex.Lock()
+	for _, n := range nums {
+		managed[n] = &Pin{idx: n, dir: DirIn}
+	}
+	managedMutex.Unlock()
+
+	return func() {
+		managedMutex.Lock()
+		for _, n := range nums {
+			delete(managed, n)
+		}
+		managedMutex.Unlock()
+	}
+}
+
+func TestLoadStateDryRun(t *testing.T) {
+	defer fakeManaged(900, 901, 902)()
+
+	state := `[
+		{"num": 900, "name": "valve", "direction": "out", "value": 1, "edge": "none"},
+		{"num": 901, "direction": "out", "value": 0, "edge": "none"},
+		{"num": 902, "direction": "in", "value": 0, "edge": "both"}
+	]`
+
+	tests := []struct {
+		name   string
+		global bool
+	}{
+		{"global", true},
+		{"per pin", false},
+	}
+
+	for _, tt := range tests {
+		ClearDryRunWrites()
+		SetDryRun(tt.global)
+		if !tt.global {
+			for _, pin := range ManagedPins() {
+				pin.SetDryRun(true)
+			}
+		}
+
+		// any hardware access fails as there's no sysfs pin
+		pins, err := LoadState(strings.NewReader(state))
+		SetDryRun(false)
+		for _, pin := range ManagedPins() {
+			pin.SetDryRun(false)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(pins) != 3 {
+			t.Errorf("%s: got %d pins, want 3", tt.name, len(pins))
+		}
+
+		w := DryRunWrites()
+		if len(w) != 2 || w[0].Pin != "valve" || w[0].Value != 1 || w[1].Pin != "GPIO901" || w[1].Value != 0 {
+			t.Errorf("%s: got %+v", tt.name, w)
+		}
+		if pins[0].dir != DirIn || pins[2].dir != DirIn {
+			t.Errorf("%s: pin direction changed in dry run", tt.name)
+		}
+	}
+	ClearDryRunWrites()
+}
+
+func TestLoadStateDirection(t *testing.T) {
+	defer fakeManaged(903)()
+
+	SetDryRun(true)
+	defer SetDryRun(false)
+
+	_, err := LoadState(strings.NewReader(`[{"num": 903, "direction": "sideways"}]`))
+	if err != ErrStateDirection {
+		t.Errorf("got %v, want %v", err, ErrStateDirection)
+	}
+}
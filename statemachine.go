@@ -0,0 +1,169 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type outputMachineState struct {
+	values   []int
+	minDwell time.Duration
+}
+
+// Output group driven through named states, e.g. tower lights or signal models.
+// Transitions are unrestricted until Allow is called for the source state.
+type StateMachineOutput struct {
+	pins        []PinWriter
+	states      map[string]outputMachineState
+	transitions map[string]map[string]bool
+
+	mutex   sync.Mutex
+	current string
+	entered time.Time
+}
+
+var (
+	ErrUnknownState = errors.New("Unknown state")
+	ErrTransition   = errors.New("Transition not allowed")
+	ErrDwell        = errors.New("Minimum dwell time not elapsed")
+	ErrStateValues  = errors.New("Number of values doesn't match number of pins")
+)
+
+func NewStateMachineOutput(pins ...PinWriter) *StateMachineOutput {
+	return &StateMachineOutput{
+		pins:        pins,
+		states:      make(map[string]outputMachineState),
+		transitions: make(map[string]map[string]bool),
+	}
+}
+
+// Define state by pin values. The machine stays in the state for at least minDwell.
+func (m *StateMachineOutput) AddState(name string, values []int, minDwell time.Duration) error {
+	if len(values) != len(m.pins) {
+		return ErrStateValues
+	}
+
+	m.mutex.Lock()
+	m.states[name] = outputMachineState{values: values, minDwell: minDwell}
+	m.mutex.Unlock()
+
+	return nil
+}
+
+// Permit transitions from one state to others
+func (m *StateMachineOutput) Allow(from string, to ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	t, ok := m.transitions[from]
+	if !ok {
+		t = make(map[string]bool)
+		m.transitions[from] = t
+	}
+	for _, s := range to {
+		t[s] = true
+	}
+}
+
+// Current state name, empty until the first Set
+func (m *StateMachineOutput) State() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.current
+}
+
+// must be called with mutex held, returns remaining dwell time
+func (m *StateMachineOutput) check(name string) (outputMachineState, time.Duration, error) {
+	st, ok := m.states[name]
+	if !ok {
+		return st, 0, ErrUnknownState
+	}
+
+	if m.current == "" || m.current == name {
+		return st, 0, nil
+	}
+
+	if t, ok := m.transitions[m.current]; ok && !t[name] {
+		return st, 0, ErrTransition
+	}
+
+	remain := m.states[m.current].minDwell - time.Since(m.entered)
+	if remain < 0 {
+		remain = 0
+	}
+	return st, remain, nil
+}
+
+// must be called with mutex held
+func (m *StateMachineOutput) enter(name string, st outputMachineState) error {
+	tx := NewTransaction()
+	for i, p := range m.pins {
+		tx.Write(p, st.values[i])
+	}
+	if err := tx.Apply(); err != nil {
+		return err
+	}
+
+	if m.current != name {
+		m.current = name
+		m.entered = time.Now()
+	}
+	return nil
+}
+
+// Switch state immediately. Fails with ErrDwell if the current state was entered too recently.
+func (m *StateMachineOutput) Set(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	st, remain, err := m.check(name)
+	if err != nil {
+		return err
+	}
+	if remain != 0 {
+		return ErrDwell
+	}
+
+	return m.enter(name, st)
+}
+
+// Switch state waiting for the current state's dwell time to elapse
+func (m *StateMachineOutput) SetWait(ctx context.Context, name string) error {
+	for {
+		m.mutex.Lock()
+		st, remain, err := m.check(name)
+		if err != nil {
+			m.mutex.Unlock()
+			return err
+		}
+		if remain == 0 {
+			err = m.enter(name, st)
+			m.mutex.Unlock()
+			return err
+		}
+		m.mutex.Unlock()
+
+		if err := sleepCtx(ctx, remain); err != nil {
+			return err
+		}
+	}
+}
+
+// UK style traffic light: red, red+amber, green, amber, red
+func TrafficLight(red, amber, green PinWriter) *StateMachineOutput {
+	m := NewStateMachineOutput(red, amber, green)
+
+	m.AddState("red", []int{1, 0, 0}, 5*time.Second)
+	m.AddState("red-amber", []int{1, 1, 0}, 2*time.Second)
+	m.AddState("green", []int{0, 0, 1}, 5*time.Second)
+	m.AddState("amber", []int{0, 1, 0}, 3*time.Second)
+
+	m.Allow("red", "red-amber")
+	m.Allow("red-amber", "green")
+	m.Allow("green", "amber")
+	m.Allow("amber", "red")
+
+	return m
+}
@@ -0,0 +1,82 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// Number of inter-edge interval histogram buckets
+const IntervalBuckets = 16
+
+// Upper bound of the first histogram bucket. Each next bucket doubles it, the last one is unbounded.
+const IntervalBucketBase = 10 * time.Microsecond
+
+// Debounce instrumentation
+type DebounceStats struct {
+	Passed     uint64 // Edges delivered
+	Suppressed uint64 // Edges dropped by debounce
+	Bounces    uint64 // Debounce periods with at least one suppressed edge
+	MaxBounce  time.Duration
+	MeanBounce time.Duration
+	// Histogram of intervals between raw edges
+	Intervals [IntervalBuckets]uint64
+}
+
+// Trigger providing debounce statistics
+type StatsReporter interface {
+	Stats() DebounceStats
+}
+
+type bounceStats struct {
+	stats      DebounceStats
+	bounceTime time.Duration
+	mutex      sync.Mutex
+}
+
+// Upper bound of histogram bucket i, zero for the last one
+func IntervalBucketBound(i int) time.Duration {
+	if i >= IntervalBuckets-1 {
+		return 0
+	}
+	return IntervalBucketBase << uint(i)
+}
+
+func (b *bounceStats) addPassed() {
+	b.mutex.Lock()
+	b.stats.Passed++
+	b.mutex.Unlock()
+}
+
+func (b *bounceStats) addSuppressed() {
+	b.mutex.Lock()
+	b.stats.Suppressed++
+	b.mutex.Unlock()
+}
+
+func (b *bounceStats) addBounce(d time.Duration) {
+	b.mutex.Lock()
+	b.stats.Bounces++
+	b.bounceTime += d
+	b.stats.MeanBounce = b.bounceTime / time.Duration(b.stats.Bounces)
+	if d > b.stats.MaxBounce {
+		b.stats.MaxBounce = d
+	}
+	b.mutex.Unlock()
+}
+
+func (b *bounceStats) addInterval(d time.Duration) {
+	i := 0
+	for i < IntervalBuckets-1 && d >= IntervalBucketBase<<uint(i) {
+		i++
+	}
+
+	b.mutex.Lock()
+	b.stats.Intervals[i]++
+	b.mutex.Unlock()
+}
+
+func (d *gpioDebounce) Stats() DebounceStats {
+	d.stats.mutex.Lock()
+	defer d.stats.mutex.Unlock()
+	return d.stats.stats
+}
@@ -0,0 +1,212 @@
+package gpio
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Brightness held for Duration. Level is 0.0 to 1.0, outputs without PWM are on above 0.5.
+type PatternStep struct {
+	Level    float64
+	Duration time.Duration
+}
+
+type Pattern struct {
+	Steps  []PatternStep
+	Repeat bool
+}
+
+// Steady on
+func Solid() Pattern {
+	return Pattern{Steps: []PatternStep{{1, time.Hour}}, Repeat: true}
+}
+
+// Repeated on/off blinking, e.g. error indication
+func Blink(on, off time.Duration) Pattern {
+	return Pattern{Steps: []PatternStep{{1, on}, {0, off}}, Repeat: true}
+}
+
+// Single short flash, e.g. network activity
+func Flicker() Pattern {
+	return Pattern{Steps: []PatternStep{{1, 30 * time.Millisecond}, {0, 30 * time.Millisecond}}}
+}
+
+// Smooth fade in and out, needs PWM capable output
+func Breathe(period time.Duration) Pattern {
+	const n = 32
+	steps := make([]PatternStep, n)
+	for i := range steps {
+		s := math.Sin(math.Pi * float64(i) / n)
+		steps[i] = PatternStep{Level: s * s, Duration: period / n}
+	}
+	return Pattern{Steps: steps, Repeat: true}
+}
+
+// Arbitrates single LED between subsystems. The highest priority request is shown,
+// the latest one wins among equal priorities. Finite patterns are removed once played.
+type StatusLED struct {
+	pin  PinWriter
+	duty DutyWriter
+
+	mutex    sync.Mutex
+	requests []*StatusRequest
+	notify   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Handle of a pattern request
+type StatusRequest struct {
+	led     *StatusLED
+	prio    int
+	pattern Pattern
+}
+
+// Output must implement DutyWriter or PinWriter
+func NewStatusLED(out interface{}) (*StatusLED, error) {
+	s := &StatusLED{
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if d, ok := out.(DutyWriter); ok {
+		s.duty = d
+	} else if p, ok := out.(PinWriter); ok {
+		s.pin = p
+	} else {
+		return nil, ErrUnsupported
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *StatusLED) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *StatusLED) Request(prio int, p Pattern) *StatusRequest {
+	r := &StatusRequest{led: s, prio: prio, pattern: p}
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, r)
+	s.mutex.Unlock()
+
+	s.wake()
+	return r
+}
+
+// Withdraw request
+func (r *StatusRequest) Cancel() {
+	s := r.led
+	s.mutex.Lock()
+	s.remove(r)
+	s.mutex.Unlock()
+
+	s.wake()
+}
+
+// must be called with mutex held
+func (s *StatusLED) remove(r *StatusRequest) {
+	for i, req := range s.requests {
+		if req == r {
+			s.requests = append(s.requests[:i], s.requests[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *StatusLED) top() *StatusRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var top *StatusRequest
+	for _, r := range s.requests {
+		if top == nil || r.prio >= top.prio {
+			top = r
+		}
+	}
+	return top
+}
+
+func (s *StatusLED) set(level float64) {
+	if s.duty != nil {
+		s.duty.SetDuty(level)
+	} else if level > 0.5 {
+		s.pin.Write(1)
+	} else {
+		s.pin.Write(0)
+	}
+}
+
+// Returns false if stopped
+func (s *StatusLED) play(r *StatusRequest) bool {
+	for {
+		for _, step := range r.pattern.Steps {
+			s.set(step.Level)
+
+			timer := time.NewTimer(step.Duration)
+		wait:
+			for {
+				select {
+				case <-timer.C:
+					break wait
+
+				case <-s.notify:
+					if s.top() != r {
+						timer.Stop()
+						return true
+					}
+
+				case <-s.stop:
+					timer.Stop()
+					return false
+				}
+			}
+		}
+
+		if !r.pattern.Repeat || len(r.pattern.Steps) == 0 {
+			s.mutex.Lock()
+			s.remove(r)
+			s.mutex.Unlock()
+			return true
+		}
+	}
+}
+
+func (s *StatusLED) run() {
+	defer close(s.done)
+
+	for {
+		r := s.top()
+		if r != nil {
+			if !s.play(r) {
+				return
+			}
+			continue
+		}
+
+		s.set(0)
+		select {
+		case <-s.notify:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop and switch LED off
+func (s *StatusLED) Close() error {
+	close(s.stop)
+	<-s.done
+
+	if s.duty != nil {
+		return s.duty.SetDuty(0)
+	}
+	return s.pin.Write(0)
+}
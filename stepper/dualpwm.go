@@ -0,0 +1,122 @@
+package stepper
+
+import (
+	"github.com/e-asphyx/gpio"
+	"math"
+)
+
+// Current decay mode of H-bridge during PWM off time
+type Decay int
+
+const (
+	DecaySlow  Decay = iota // bridge shorts the coil, less ripple, poor current control at high speed
+	DecayFast               // current is driven back to supply, follows the sine closely
+	DecayMixed              // fast then slow within each off time
+)
+
+// Applies decay mode to the driver hardware, e.g. by setting DECAY pin of DRV8812
+// or choosing which bridge input PWM is applied to
+type DecayHook func(mode Decay) error
+
+// One winding of dual H-bridge driver (L298, DRV8812, TB6612 and similar). PWM sets coil
+// current, Phase sets its polarity.
+type Coil struct {
+	PWM   gpio.DutyWriter
+	Phase gpio.PinWriter
+}
+
+// Largest supported microstep divisor
+const MaxMicrostep = 256
+
+// Sine/cosine microstepping of a bipolar motor
+type dualPWM struct {
+	coils   [2]Coil
+	n       int // microsteps per full step
+	phase   int // position within electrical cycle of 4n microsteps
+	current float64
+}
+
+func (d *dualPWM) apply() error {
+	// full steps fall between the coils so both carry the same current like in two coil full stepping
+	theta := (float64(d.phase)/float64(d.n) + 0.5) * math.Pi / 2
+
+	for i, v := range [2]float64{math.Cos(theta), math.Sin(theta)} {
+		c := d.coils[i]
+
+		pol := 0
+		if v < 0 {
+			pol = 1
+		}
+		if err := c.Phase.Write(pol); err != nil {
+			return err
+		}
+		if err := c.PWM.SetDuty(math.Abs(v) * d.current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dualPWM) step(dir int) error {
+	cycle := 4 * d.n
+	d.phase = (d.phase + dir + cycle) % cycle
+	return d.apply()
+}
+
+func (d *dualPWM) release() error {
+	for _, c := range d.coils {
+		if err := c.PWM.SetDuty(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bipolar motor driven by two PWM channels with n microsteps per full step (1 to MaxMicrostep).
+// stepsPerRev counts full steps, positions are in microsteps.
+func NewDualPWM(a, b Coil, n, stepsPerRev int) (*Motor, error) {
+	if n < 1 || n > MaxMicrostep {
+		return nil, ErrMicrostep
+	}
+
+	m := newMotor(&dualPWM{coils: [2]Coil{a, b}, n: n, current: 1}, stepsPerRev)
+	m.microstep = n
+	return m, nil
+}
+
+// Scale coil current of dual PWM driver, e.g. lower it to reduce heat and noise while holding
+func (m *Motor) SetCurrent(scale float64) error {
+	d, ok := m.drv.(*dualPWM)
+	if !ok {
+		return ErrDriver
+	}
+	if scale < 0 {
+		scale = 0
+	} else if scale > 1 {
+		scale = 1
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	d.current = scale
+	return d.apply()
+}
+
+// Hook called by SetDecay
+func (m *Motor) SetDecayHook(hook DecayHook) {
+	m.mutex.Lock()
+	m.decay = hook
+	m.mutex.Unlock()
+}
+
+// Switch current decay mode through the hook
+func (m *Motor) SetDecay(mode Decay) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.decay == nil {
+		return ErrDriver
+	}
+	return m.decay(mode)
+}
@@ -0,0 +1,157 @@
+package stepper
+
+import (
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"math"
+	"testing"
+)
+
+type dutyRecorder struct {
+	duty float64
+}
+
+func (d *dutyRecorder) SetDuty(duty float64) error {
+	d.duty = duty
+	return nil
+}
+
+type pinRecorder struct {
+	value int
+}
+
+func (p *pinRecorder) Write(value int) error {
+	p.value = value
+	return nil
+}
+
+type coilRecorder struct {
+	pwm   dutyRecorder
+	phase pinRecorder
+}
+
+func (c *coilRecorder) coil() Coil {
+	return Coil{PWM: &c.pwm, Phase: &c.phase}
+}
+
+// signed coil current
+func (c *coilRecorder) current() float64 {
+	if c.phase.value != 0 {
+		return -c.pwm.duty
+	}
+	return c.pwm.duty
+}
+
+func TestDualPWMStep(t *testing.T) {
+	h := math.Sqrt2 / 2
+
+	tests := []struct {
+		name  string
+		n     int
+		steps []int // directions
+		a, b  float64
+	}{
+		{"full step 1", 1, []int{1}, -h, h},
+		{"full step 2", 1, []int{1, 1}, -h, -h},
+		{"full step 3", 1, []int{1, 1, 1}, h, -h},
+		{"full step cycle", 1, []int{1, 1, 1, 1}, h, h},
+		{"full step back", 1, []int{-1}, h, -h},
+		{"half step", 2, []int{1}, 0, 1},
+		{"half step back", 2, []int{-1}, 1, 0},
+		{"quarter step", 4, []int{1}, math.Cos(3 * math.Pi / 8), math.Sin(3 * math.Pi / 8)},
+		{"forward and back", 16, []int{1, 1, 1, -1, -1, -1}, h, h},
+		{"whole cycle", 16, repeat(1, 64), h, h},
+	}
+
+	for _, tt := range tests {
+		var a, b coilRecorder
+		d := &dualPWM{coils: [2]Coil{a.coil(), b.coil()}, n: tt.n, current: 1}
+
+		for _, dir := range tt.steps {
+			if err := d.step(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if math.Abs(a.current()-tt.a) > 1e-9 || math.Abs(b.current()-tt.b) > 1e-9 {
+			t.Errorf("%s: got %.3f/%.3f, want %.3f/%.3f", tt.name, a.current(), b.current(), tt.a, tt.b)
+		}
+	}
+}
+
+func repeat(dir, n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = dir
+	}
+	return s
+}
+
+func TestDualPWMConstantTorque(t *testing.T) {
+	var a, b coilRecorder
+	d := &dualPWM{coils: [2]Coil{a.coil(), b.coil()}, n: 8, current: 0.5}
+
+	for i := 0; i < 32; i++ {
+		d.step(1)
+		if s := a.current()*a.current() + b.current()*b.current(); math.Abs(s-0.25) > 1e-9 {
+			t.Fatalf("microstep %d: got current vector %.3f, want 0.5", i, math.Sqrt(s))
+		}
+	}
+
+	d.release()
+	if a.pwm.duty != 0 || b.pwm.duty != 0 {
+		t.Errorf("released coils carry %v/%v", a.pwm.duty, b.pwm.duty)
+	}
+}
+
+func TestNewDualPWM(t *testing.T) {
+	var a, b coilRecorder
+
+	for _, n := range []int{0, -1, MaxMicrostep + 1} {
+		if _, err := NewDualPWM(a.coil(), b.coil(), n, 200); err != ErrMicrostep {
+			t.Errorf("%d microsteps: got %v, want %v", n, err, ErrMicrostep)
+		}
+	}
+
+	m, err := NewDualPWM(a.coil(), b.coil(), 16, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.SetCurrent(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(a.pwm.duty-0.5*math.Sqrt2/2) > 1e-9 {
+		t.Errorf("got duty %v at half current", a.pwm.duty)
+	}
+
+	if err := m.SetDecay(DecayFast); err != ErrDriver {
+		t.Errorf("no hook: got %v, want %v", err, ErrDriver)
+	}
+
+	var modes []Decay
+	hookErr := errors.New("hook")
+	m.SetDecayHook(func(mode Decay) error {
+		modes = append(modes, mode)
+		if mode == DecayMixed {
+			return hookErr
+		}
+		return nil
+	})
+	if err := m.SetDecay(DecaySlow); err != nil {
+		t.Error(err)
+	}
+	if err := m.SetDecay(DecayMixed); err != hookErr {
+		t.Errorf("got %v, want %v", err, hookErr)
+	}
+	if len(modes) != 2 || modes[0] != DecaySlow || modes[1] != DecayMixed {
+		t.Errorf("hook got %v", modes)
+	}
+
+	four := NewFourWire([4]gpio.PinWriter{&a.phase, &a.phase, &b.phase, &b.phase}, FullStep, 2048)
+	defer four.Close()
+	if err := four.SetCurrent(1); err != ErrDriver {
+		t.Errorf("4-wire driver: got %v, want %v", err, ErrDriver)
+	}
+}
@@ -0,0 +1,423 @@
+package stepper
+
+import (
+	"context"
+	"errors"
+	"github.com/e-asphyx/gpio"
+	"math"
+	"sync"
+	"time"
+)
+
+// Coil sequence of 4-wire unipolar drivers
+type Mode int
+
+const (
+	FullStep  Mode = iota // two coils on, full torque
+	HalfStep              // alternates one and two coils, double resolution
+	WaveDrive             // one coil on, less current
+)
+
+const (
+	stepPulse    = 2 * time.Microsecond // A4988 needs 1us, DRV8825 1.9us
+	dirSetup     = time.Microsecond
+	DefaultSpeed = 500 // steps/s
+)
+
+var (
+	ErrMicrostep = errors.New("Unsupported microstep setting")
+	ErrDriver    = errors.New("Operation not supported by driver")
+)
+
+// MS1-MS3 levels per microstep divisor
+var (
+	A4988Microsteps = map[int][3]int{
+		1: {0, 0, 0}, 2: {1, 0, 0}, 4: {0, 1, 0}, 8: {1, 1, 0}, 16: {1, 1, 1},
+	}
+	DRV8825Microsteps = map[int][3]int{
+		1: {0, 0, 0}, 2: {1, 0, 0}, 4: {0, 1, 0}, 8: {1, 1, 0}, 16: {0, 0, 1}, 32: {1, 0, 1},
+	}
+)
+
+var sequences = map[Mode][][4]int{
+	FullStep:  {{1, 1, 0, 0}, {0, 1, 1, 0}, {0, 0, 1, 1}, {1, 0, 0, 1}},
+	HalfStep:  {{1, 0, 0, 0}, {1, 1, 0, 0}, {0, 1, 0, 0}, {0, 1, 1, 0}, {0, 0, 1, 0}, {0, 0, 1, 1}, {0, 0, 0, 1}, {1, 0, 0, 1}},
+	WaveDrive: {{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}},
+}
+
+type driver interface {
+	step(dir int) error
+	release() error
+}
+
+// ULN2003 and similar, pins in coil order IN1-IN4
+type fourWire struct {
+	pins  [4]gpio.PinWriter
+	seq   [][4]int
+	phase int
+}
+
+func (d *fourWire) step(dir int) error {
+	d.phase = (d.phase + dir + len(d.seq)) % len(d.seq)
+	for i, pin := range d.pins {
+		if err := pin.Write(d.seq[d.phase][i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *fourWire) release() error {
+	for _, pin := range d.pins {
+		if err := pin.Write(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A4988, DRV8825 and other step/direction drivers
+type stepDir struct {
+	stepPin   gpio.PinWriter
+	dirPin    gpio.PinWriter
+	dir       int
+	enable    gpio.PinWriter
+	enableLow bool
+}
+
+func (d *stepDir) step(dir int) error {
+	if dir != d.dir {
+		v := 0
+		if dir > 0 {
+			v = 1
+		}
+		if err := d.dirPin.Write(v); err != nil {
+			return err
+		}
+		d.dir = dir
+		time.Sleep(dirSetup)
+	}
+
+	if err := d.stepPin.Write(1); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(stepPulse)
+	for time.Now().Before(deadline) {
+	}
+	return d.stepPin.Write(0)
+}
+
+func (d *stepDir) setEnabled(on bool) error {
+	if d.enable == nil {
+		return nil
+	}
+	if on != d.enableLow {
+		return d.enable.Write(1)
+	}
+	return d.enable.Write(0)
+}
+
+func (d *stepDir) release() error {
+	return d.setEnabled(false)
+}
+
+// Stepper motor moved by a background goroutine with trapezoidal speed profile
+type Motor struct {
+	drv         driver
+	stepsPerRev int
+
+	mutex     sync.Mutex
+	microstep int
+	pos       int
+	target    int
+	speed     float64 // steps/s
+	accel     float64 // steps/s^2, zero for instant start
+	v         float64 // current speed, steps/s
+	dir       int
+	moving    bool
+	err       error
+	decay     DecayHook
+	wake      chan struct{}
+	idle      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func newMotor(drv driver, stepsPerRev int) *Motor {
+	m := &Motor{
+		drv:         drv,
+		stepsPerRev: stepsPerRev,
+		microstep:   1,
+		speed:       DefaultSpeed,
+		wake:        make(chan struct{}, 1),
+		idle:        make(chan struct{}),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	close(m.idle)
+
+	go m.run()
+	return m
+}
+
+// 4-wire driver, stepsPerRev counts steps of given mode, e.g. 2048 full or 4096 half steps for 28BYJ-48
+func NewFourWire(pins [4]gpio.PinWriter, mode Mode, stepsPerRev int) *Motor {
+	seq, ok := sequences[mode]
+	if !ok {
+		seq = sequences[FullStep]
+	}
+	return newMotor(&fourWire{pins: pins, seq: seq, phase: -1}, stepsPerRev)
+}
+
+// Step/direction driver, stepsPerRev counts full steps
+func NewStepDir(step, dir gpio.PinWriter, stepsPerRev int) *Motor {
+	return newMotor(&stepDir{stepPin: step, dirPin: dir}, stepsPerRev)
+}
+
+// Enable input of step/dir driver, usually active low. Driver is enabled right away.
+func (m *Motor) SetEnablePin(pin gpio.PinWriter, activeLow bool) error {
+	d, ok := m.drv.(*stepDir)
+	if !ok {
+		return ErrDriver
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	d.enable, d.enableLow = pin, activeLow
+	return d.setEnabled(true)
+}
+
+// Set microstep divisor through MS pins of step/dir driver. Angles account for it, positions are in microsteps.
+func (m *Motor) SetMicrostep(pins [3]gpio.PinWriter, table map[int][3]int, n int) error {
+	if _, ok := m.drv.(*stepDir); !ok {
+		return ErrDriver
+	}
+
+	levels, ok := table[n]
+	if !ok {
+		return ErrMicrostep
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, pin := range pins {
+		if err := pin.Write(levels[i]); err != nil {
+			return err
+		}
+	}
+	m.microstep = n
+	return nil
+}
+
+// Top speed in steps/s
+func (m *Motor) SetSpeed(stepsPerSec float64) {
+	m.mutex.Lock()
+	m.speed = stepsPerSec
+	m.mutex.Unlock()
+}
+
+// Ramp in steps/s^2, zero starts and stops at full speed
+func (m *Motor) SetAcceleration(stepsPerSec2 float64) {
+	m.mutex.Lock()
+	m.accel = stepsPerSec2
+	m.mutex.Unlock()
+}
+
+// Steps from the starting point
+func (m *Motor) Position() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.pos
+}
+
+// Redefine current position without moving
+func (m *Motor) SetPosition(pos int) {
+	m.mutex.Lock()
+	m.target += pos - m.pos
+	m.pos = pos
+	m.mutex.Unlock()
+}
+
+// Start moving relative to current target and return immediately
+func (m *Motor) Move(steps int) {
+	m.mutex.Lock()
+	m.setTarget(m.target + steps)
+	m.mutex.Unlock()
+}
+
+func (m *Motor) MoveTo(pos int) {
+	m.mutex.Lock()
+	m.setTarget(pos)
+	m.mutex.Unlock()
+}
+
+// Absolute angle in degrees relative to position zero
+func (m *Motor) RotateTo(angle float64) {
+	m.mutex.Lock()
+	steps := float64(m.stepsPerRev*m.microstep) * angle / 360
+	m.setTarget(int(math.Floor(steps + 0.5)))
+	m.mutex.Unlock()
+}
+
+// Must be called with mutex held
+func (m *Motor) setTarget(target int) {
+	m.target = target
+	m.err = nil
+	if !m.moving && target != m.pos {
+		m.moving = true
+		m.idle = make(chan struct{})
+	}
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Decelerate and stop as soon as possible
+func (m *Motor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.dir == 0 {
+		m.target = m.pos
+		return
+	}
+
+	steps := 0
+	if m.accel > 0 {
+		steps = int(math.Ceil(m.v * m.v / (2 * m.accel)))
+	}
+	m.target = m.pos + m.dir*steps
+}
+
+func (m *Motor) Moving() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.moving
+}
+
+// Block until the target is reached, returns driver error which stopped the motion if any
+func (m *Motor) Wait(ctx context.Context) error {
+	m.mutex.Lock()
+	idle := m.idle
+	m.mutex.Unlock()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.err
+}
+
+func (m *Motor) run() {
+	defer close(m.done)
+
+	next := time.Now()
+	for {
+		m.mutex.Lock()
+		remaining := m.target - m.pos
+		speed, accel, v, dir := m.speed, m.accel, m.v, m.dir
+		if (remaining == 0 && v == 0) || m.err != nil {
+			m.v, m.dir = 0, 0
+			if m.moving {
+				m.moving = false
+				close(m.idle)
+			}
+			m.mutex.Unlock()
+
+			select {
+			case <-m.wake:
+				next = time.Now()
+				continue
+			case <-m.stop:
+				return
+			}
+		}
+		m.mutex.Unlock()
+
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+
+		// slowest speed of the ramp, reached after one step from standstill
+		vmin := speed
+		if accel > 0 {
+			vmin = math.Min(math.Sqrt(2*accel), speed)
+		}
+
+		if v == 0 {
+			dir, v = 1, vmin
+			if remaining < 0 {
+				dir = -1
+			}
+		} else {
+			dist := float64(remaining * dir)
+			var stopSteps float64
+			if accel > 0 {
+				stopSteps = v * v / (2 * accel)
+			}
+
+			switch {
+			case dist <= 0:
+				// target is behind, stop before reversing
+				if accel <= 0 || v*v-2*accel < vmin*vmin {
+					m.mutex.Lock()
+					m.v, m.dir = 0, 0
+					m.mutex.Unlock()
+					continue
+				}
+				v = math.Sqrt(v*v - 2*accel)
+			case dist <= stopSteps:
+				v = math.Max(math.Sqrt(math.Max(v*v-2*accel, 0)), vmin)
+			case accel > 0:
+				v = math.Min(math.Sqrt(v*v+2*accel), speed)
+			default:
+				v = speed
+			}
+		}
+
+		next = next.Add(time.Duration(float64(time.Second) / v))
+		if d := next.Sub(time.Now()); d > 0 {
+			time.Sleep(d)
+		} else {
+			next = time.Now()
+		}
+
+		err := m.drv.step(dir)
+
+		m.mutex.Lock()
+		if err != nil {
+			m.err = err
+		} else {
+			m.pos += dir
+			m.v, m.dir = v, dir
+			if m.pos == m.target {
+				m.v, m.dir = 0, 0
+			}
+		}
+		m.mutex.Unlock()
+	}
+}
+
+// De-energize coils or disable driver, holding torque is lost
+func (m *Motor) Release() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.drv.release()
+}
+
+// Stop immediately and release the motor
+func (m *Motor) Close() error {
+	close(m.stop)
+	<-m.done
+	return m.Release()
+}
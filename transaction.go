@@ -0,0 +1,135 @@
+package gpio
+
+// Controller able to change several lines with single register access
+type BankWriter interface {
+	WriteBank(mask, value uint32) error
+}
+
+// Pin belonging to a BankWriter
+type BankPin interface {
+	PinWriter
+	Bank() (bank BankWriter, bit uint)
+}
+
+// Pin with configurable direction
+type DirectionSetter interface {
+	SetDirection(dir Direction) error
+}
+
+// Pin write or direction change, staged or recorded for rollback
+type txStep struct {
+	pin    PinWriter
+	value  int
+	dirPin DirectionSetter
+	dir    Direction
+}
+
+// Staged set of pin writes and direction changes applied in order with rollback
+type Transaction struct {
+	steps []txStep
+}
+
+func NewTransaction() *Transaction {
+	return new(Transaction)
+}
+
+func (tx *Transaction) Write(pin PinWriter, value int) *Transaction {
+	tx.steps = append(tx.steps, txStep{pin: pin, value: value})
+	return tx
+}
+
+func (tx *Transaction) SetDirection(pin DirectionSetter, dir Direction) *Transaction {
+	tx.steps = append(tx.steps, txStep{dirPin: pin, dir: dir})
+	return tx
+}
+
+func undoValue(pin PinWriter) (int, bool) {
+	r, ok := pin.(PinReader)
+	if !ok {
+		return 0, false
+	}
+	val, err := r.Read()
+	return val, err == nil
+}
+
+// Execute staged steps in order. Consecutive writes to pins of the same bank are merged into single
+// register access. On error already applied steps are reverted where previous state can be read back.
+func (tx *Transaction) Apply() error {
+	var undo []txStep
+
+	rollback := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			u := undo[i]
+			if u.dirPin != nil {
+				u.dirPin.SetDirection(u.dir)
+			} else {
+				u.pin.Write(u.value)
+			}
+		}
+		return err
+	}
+
+	for i := 0; i < len(tx.steps); {
+		st := tx.steps[i]
+
+		if st.dirPin != nil {
+			if g, ok := st.dirPin.(interface {
+				Direction() (Direction, error)
+			}); ok {
+				if dir, err := g.Direction(); err == nil {
+					undo = append(undo, txStep{dirPin: st.dirPin, dir: dir})
+				}
+			}
+
+			if err := st.dirPin.SetDirection(st.dir); err != nil {
+				return rollback(err)
+			}
+			i++
+			continue
+		}
+
+		if bp, ok := st.pin.(BankPin); ok {
+			bank, _ := bp.Bank()
+
+			var mask, value uint32
+			j := i
+			for ; j < len(tx.steps); j++ {
+				p, ok := tx.steps[j].pin.(BankPin)
+				if !ok {
+					break
+				}
+				b, bit := p.Bank()
+				if b != bank {
+					break
+				}
+
+				if v, ok := undoValue(p); ok {
+					undo = append(undo, txStep{pin: p, value: v})
+				}
+
+				mask |= 1 << bit
+				if tx.steps[j].value != 0 {
+					value |= 1 << bit
+				} else {
+					value &^= 1 << bit
+				}
+			}
+
+			if err := bank.WriteBank(mask, value); err != nil {
+				return rollback(err)
+			}
+			i = j
+			continue
+		}
+
+		if v, ok := undoValue(st.pin); ok {
+			undo = append(undo, txStep{pin: st.pin, value: v})
+		}
+		if err := st.pin.Write(st.value); err != nil {
+			return rollback(err)
+		}
+		i++
+	}
+
+	return nil
+}
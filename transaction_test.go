@@ -0,0 +1,126 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeBank struct {
+	pins   [32]int
+	writes int
+	err    error
+}
+
+func (b *fakeBank) WriteBank(mask, value uint32) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.writes++
+	for i := range b.pins {
+		if mask&(1<<uint(i)) != 0 {
+			b.pins[i] = int(value>>uint(i)) & 1
+		}
+	}
+	return nil
+}
+
+type fakeBankPin struct {
+	bank *fakeBank
+	bit  uint
+}
+
+func (p fakeBankPin) Read() (int, error) {
+	return p.bank.pins[p.bit], nil
+}
+
+func (p fakeBankPin) Write(value int) error {
+	return p.bank.WriteBank(1<<p.bit, uint32(value&1)<<p.bit)
+}
+
+func (p fakeBankPin) Bank() (BankWriter, uint) {
+	return p.bank, p.bit
+}
+
+type fakeDirPin struct {
+	dir Direction
+	err error
+}
+
+func (p *fakeDirPin) SetDirection(dir Direction) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.dir = dir
+	return nil
+}
+
+func (p *fakeDirPin) Direction() (Direction, error) {
+	return p.dir, nil
+}
+
+func TestTransactionBankMerge(t *testing.T) {
+	var bank fakeBank
+	var other fakePin
+
+	err := NewTransaction().
+		Write(fakeBankPin{&bank, 0}, 1).
+		Write(fakeBankPin{&bank, 3}, 1).
+		Write(fakeBankPin{&bank, 0}, 0).
+		Write(&other, 1).
+		Write(fakeBankPin{&bank, 5}, 1).
+		Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first three writes are merged
+	if bank.writes != 2 {
+		t.Errorf("got %d bank writes, want 2", bank.writes)
+	}
+	if bank.pins[0] != 0 || bank.pins[3] != 1 || bank.pins[5] != 1 || other.value != 1 {
+		t.Errorf("got bank %v, other %d", bank.pins[:6], other.value)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	fail := errors.New("failed")
+
+	tests := []struct {
+		name string
+		last func(tx *Transaction, bank *fakeBank)
+	}{
+		{"direction", func(tx *Transaction, bank *fakeBank) {
+			tx.SetDirection(&fakeDirPin{err: fail}, DirOut)
+		}},
+		{"write", func(tx *Transaction, bank *fakeBank) {
+			tx.Write(&failingWriter{err: fail}, 1)
+		}},
+		{"bank", func(tx *Transaction, bank *fakeBank) {
+			failing := &fakeBank{err: fail}
+			tx.Write(fakeBankPin{failing, 1}, 1)
+		}},
+	}
+
+	for _, tt := range tests {
+		var (
+			bank fakeBank
+			pin  fakePin
+		)
+		dir := &fakeDirPin{dir: DirIn}
+		bank.pins[2] = 1
+
+		tx := NewTransaction().
+			Write(&pin, 1).
+			SetDirection(dir, DirOut).
+			Write(fakeBankPin{&bank, 2}, 0).
+			Write(fakeBankPin{&bank, 4}, 1)
+		tt.last(tx, &bank)
+
+		if err := tx.Apply(); err != fail {
+			t.Errorf("%s: got %v, want %v", tt.name, err, fail)
+		}
+		if pin.value != 0 || dir.dir != DirIn || bank.pins[2] != 1 || bank.pins[4] != 0 {
+			t.Errorf("%s: not rolled back: pin %d, dir %v, bank %v", tt.name, pin.value, dir.dir, bank.pins[:5])
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	ultrasonicTrigPulse = 10 * time.Microsecond
+	ultrasonicMaxEcho   = 38 * time.Millisecond // HC-SR04 ends the pulse after 38ms if nothing was hit
+	ultrasonicCycle     = 60 * time.Millisecond // let previous echoes fade
+
+	// m/s in dry air at 20 degrees Celsius
+	DefaultSpeedOfSound = 343.0
+)
+
+var ErrNoEcho = errors.New("No echo received")
+
+// HC-SR04 and compatible ultrasonic ranging modules
+type Ultrasonic struct {
+	trig PinWriter
+	tr   PinTrigger
+
+	// m/s, adjust for air temperature
+	SpeedOfSound float64
+
+	mutex sync.Mutex
+	last  time.Time
+}
+
+func NewUltrasonic(trigPin PinWriter, echoPin PinReadTrigger) (*Ultrasonic, error) {
+	if err := trigPin.Write(0); err != nil {
+		return nil, err
+	}
+
+	tr, err := echoPin.Trigger(EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ultrasonic{
+		trig:         trigPin,
+		tr:           tr,
+		SpeedOfSound: DefaultSpeedOfSound,
+	}, nil
+}
+
+// Distance in meters
+func (u *Ultrasonic) Measure(ctx context.Context) (float64, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if wait := u.last.Add(ultrasonicCycle).Sub(time.Now()); wait > 0 {
+		if err := sleepCtx(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+	defer func() { u.last = time.Now() }()
+
+	// drop stale edges
+	for len(u.tr.EventCh()) != 0 {
+		<-u.tr.EventCh()
+	}
+
+	if err := u.trig.Write(1); err != nil {
+		return 0, err
+	}
+	sleepUntil(time.Now().Add(ultrasonicTrigPulse))
+	if err := u.trig.Write(0); err != nil {
+		return 0, err
+	}
+
+	// echo rises after the 40kHz burst is sent, well under the margin
+	timeout := time.NewTimer(ultrasonicMaxEcho + ultrasonicCycle/2)
+	defer timeout.Stop()
+
+	var start time.Time
+	for {
+		select {
+		case ev, ok := <-u.tr.EventCh():
+			if !ok {
+				return 0, ErrTrigger
+			}
+
+			if ev.Value != 0 {
+				start = ev.Timestamp
+				continue
+			}
+			if start.IsZero() {
+				continue
+			}
+
+			width := ev.Timestamp.Sub(start)
+			if width >= ultrasonicMaxEcho {
+				return 0, ErrNoEcho
+			}
+			// sound travels there and back
+			return width.Seconds() * u.SpeedOfSound / 2, nil
+
+		case <-timeout.C:
+			return 0, ErrNoEcho
+
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (u *Ultrasonic) Close() error {
+	return u.tr.Close()
+}
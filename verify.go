@@ -0,0 +1,61 @@
+package gpio
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrVerify = errors.New("Output readback mismatch")
+
+// Pin able to read its actual level from hardware bypassing any cache, like expander pins
+type ReadBacker interface {
+	ReadBack() (int, error)
+}
+
+// Pin with cached state, Invalidate forces the next write to reach hardware
+type Invalidator interface {
+	Invalidate()
+}
+
+// Read the pin back after every write and rewrite it up to retries times on mismatch, returning
+// ErrVerify if it never matches. pin is used for readback through ReadBack if it has one or Read otherwise.
+// settle is waited before each readback, e.g. for slow edges on heavily loaded outputs.
+func Verify(pin interface{}, retries int, settle time.Duration) Middleware {
+	var read ReadFunc
+	if rb, ok := pin.(ReadBacker); ok {
+		read = rb.ReadBack
+	} else if r, ok := pin.(PinReader); ok {
+		read = r.Read
+	} else {
+		read = func() (int, error) { return 0, ErrUnsupported }
+	}
+
+	return Middleware{
+		Write: func(next WriteFunc) WriteFunc {
+			return func(value int) error {
+				for i := 0; ; i++ {
+					if err := next(value); err != nil {
+						return err
+					}
+					if settle > 0 {
+						time.Sleep(settle)
+					}
+
+					v, err := read()
+					if err != nil {
+						return err
+					}
+					if (v != 0) == (value != 0) {
+						return nil
+					}
+					if i >= retries {
+						return ErrVerify
+					}
+					if inv, ok := pin.(Invalidator); ok {
+						inv.Invalidate()
+					}
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+type keyedEvent struct {
+	key string
+	ev  Event
+}
+
+type watchEntry struct {
+	tr   PinTrigger
+	stop chan struct{}
+}
+
+// Multiplexes many triggers into a single stream of keyed events
+type Watcher struct {
+	mutex     sync.Mutex
+	entries   map[string]*watchEntry
+	ch        chan keyedEvent
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var (
+	ErrKeyExists = errors.New("Key already watched")
+	ErrNoKey     = errors.New("Key not watched")
+	ErrClosed    = errors.New("Watcher closed")
+)
+
+func NewWatcher() *Watcher {
+	return &Watcher{
+		entries: make(map[string]*watchEntry),
+		ch:      make(chan keyedEvent, 64),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Add active trigger. The watcher takes ownership and closes it on Remove or Close.
+func (w *Watcher) Add(key string, tr PinTrigger) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	select {
+	case <-w.closed:
+		return ErrClosed
+	default:
+	}
+
+	if _, ok := w.entries[key]; ok {
+		return ErrKeyExists
+	}
+
+	e := &watchEntry{tr: tr, stop: make(chan struct{})}
+	w.entries[key] = e
+
+	go w.forward(key, e)
+	return nil
+}
+
+// Start trigger on pin and add it
+func (w *Watcher) Watch(key string, pin PinReadTrigger, edge Trigger) error {
+	tr, err := pin.Trigger(edge)
+	if err != nil {
+		return err
+	}
+
+	if err = w.Add(key, tr); err != nil {
+		tr.Close()
+		return err
+	}
+	return nil
+}
+
+func (w *Watcher) forward(key string, e *watchEntry) {
+	var buf [16]Event
+	for {
+		n, err := ReadEvents(e.tr, buf[:])
+		for i := 0; i < n; i++ {
+			select {
+			case w.ch <- keyedEvent{key: key, ev: buf[i]}:
+			case <-e.stop:
+				return
+			}
+		}
+		if err == io.EOF {
+			return
+		}
+	}
+}
+
+func (w *Watcher) Remove(key string) error {
+	w.mutex.Lock()
+	e, ok := w.entries[key]
+	delete(w.entries, key)
+	w.mutex.Unlock()
+
+	if !ok {
+		return ErrNoKey
+	}
+
+	close(e.stop)
+	return e.tr.Close()
+}
+
+// Next event from any watched trigger
+func (w *Watcher) Next(ctx context.Context) (string, Event, error) {
+	select {
+	case ke := <-w.ch:
+		return ke.key, ke.ev, nil
+	case <-w.closed:
+		return "", Event{}, ErrClosed
+	case <-ctx.Done():
+		return "", Event{}, ctx.Err()
+	}
+}
+
+// Close all triggers. Subsequent calls do nothing.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		w.mutex.Lock()
+		close(w.closed)
+		keys := make([]string, 0, len(w.entries))
+		for k := range w.entries {
+			keys = append(keys, k)
+		}
+		w.mutex.Unlock()
+
+		for _, k := range keys {
+			if e := w.Remove(k); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,54 @@
+package gpio
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	var a, b fakePin
+	w := NewWatcher()
+
+	if err := w.Watch("a", &a, EdgeBoth); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Watch("b", &b, EdgeRising); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Watch("a", &a, EdgeBoth); err != ErrKeyExists {
+		t.Errorf("got %v, want %v", err, ErrKeyExists)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	a.set(1, time.Now())
+	if key, ev, err := w.Next(ctx); err != nil || key != "a" || ev.Value != 1 {
+		t.Errorf("got %s %+v %v", key, ev, err)
+	}
+	b.set(1, time.Now())
+	if key, ev, err := w.Next(ctx); err != nil || key != "b" || ev.Value != 1 {
+		t.Errorf("got %s %+v %v", key, ev, err)
+	}
+
+	if err := w.Remove("b"); err != nil {
+		t.Error(err)
+	}
+	if err := w.Remove("b"); err != ErrNoKey {
+		t.Errorf("got %v, want %v", err, ErrNoKey)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	if err := w.Watch("c", &b, EdgeBoth); err != ErrClosed {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+	if _, _, err := w.Next(ctx); err != ErrClosed {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,94 @@
+package gpio
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Expected wiring: Sense reads Expect while Drive is driven to Level
+type WiringCheck struct {
+	Name   string
+	Drive  PinWriter
+	Level  int
+	Sense  PinReader
+	Expect int
+	Settle time.Duration // wait before reading, DefaultWiringSettle if zero
+}
+
+type WiringResult struct {
+	Name   string
+	Expect int
+	Got    int
+	Err    error
+}
+
+func (r WiringResult) Passed() bool {
+	return r.Err == nil && r.Got == r.Expect
+}
+
+func (r WiringResult) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s: %v", r.Name, r.Err)
+	case r.Got != r.Expect:
+		return fmt.Sprintf("FAIL %s: expected %d, got %d", r.Name, r.Expect, r.Got)
+	}
+	return fmt.Sprintf("PASS %s", r.Name)
+}
+
+const DefaultWiringSettle = 10 * time.Millisecond
+
+// Walks the expectation list in order, i.e. during commissioning before the application starts.
+// Driven pins which can be read are restored afterwards. Returns true if all checks passed.
+func CheckWiring(checks []WiringCheck) ([]WiringResult, bool) {
+	res := make([]WiringResult, len(checks))
+	ok := true
+
+	for i := range checks {
+		res[i] = checkWiring(&checks[i])
+		if !res[i].Passed() {
+			ok = false
+		}
+	}
+	return res, ok
+}
+
+func checkWiring(c *WiringCheck) WiringResult {
+	r := WiringResult{Name: c.Name, Expect: c.Expect}
+
+	if rd, ok := c.Drive.(PinReader); ok {
+		if prev, err := rd.Read(); err == nil {
+			defer c.Drive.Write(prev)
+		}
+	}
+
+	if r.Err = c.Drive.Write(c.Level); r.Err != nil {
+		return r
+	}
+
+	settle := c.Settle
+	if settle == 0 {
+		settle = DefaultWiringSettle
+	}
+	time.Sleep(settle)
+
+	r.Got, r.Err = c.Sense.Read()
+	return r
+}
+
+// Human readable pass/fail report
+func WriteWiringReport(w io.Writer, results []WiringResult) error {
+	failed := 0
+	for _, r := range results {
+		if !r.Passed() {
+			failed++
+		}
+		if _, err := fmt.Fprintln(w, r); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%d checks, %d failed\n", len(results), failed)
+	return err
+}
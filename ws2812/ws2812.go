@@ -0,0 +1,159 @@
+package ws2812
+
+import (
+	"github.com/e-asphyx/gpio/spi"
+	"io"
+	"sync"
+)
+
+const (
+	// Three SPI bits per LED bit: 1 is sent as 110, 0 as 100, i.e. 417ns units
+	SPISpeed = 2400000
+
+	resetBytes = 90 // 300us low latches data on WS2812B
+)
+
+// Byte order on the wire
+type ColorOrder int
+
+const (
+	GRB ColorOrder = iota // WS2812, WS2812B, SK6812
+	RGB                   // WS2811 and some clones
+	BRG
+)
+
+// LED strip driven by SPI MOSI (GPIO10 on Raspberry Pi). Plain GPIO writes can't meet the 800kHz timing.
+// spidev limits transfer size to 4096 bytes by default, i.e. about 440 LEDs; raise spidev.bufsiz for longer strips.
+type Strip struct {
+	conn spi.Conn
+
+	mutex      sync.Mutex
+	order      ColorOrder
+	brightness uint8
+	pixels     [][3]uint8 // r, g, b
+	buf        []byte
+}
+
+// Wrap SPI connection running at SPISpeed in mode 0
+func New(conn spi.Conn, n int) *Strip {
+	return &Strip{
+		conn:       conn,
+		brightness: 255,
+		pixels:     make([][3]uint8, n),
+		buf:        make([]byte, n*9+resetBytes),
+	}
+}
+
+// Open /dev/spidev<bus>.<cs> with suitable settings
+func Open(bus, cs, n int) (*Strip, error) {
+	dev, err := spi.Open(bus, cs, spi.Mode0, SPISpeed)
+	if err != nil {
+		return nil, err
+	}
+	return New(dev, n), nil
+}
+
+func (s *Strip) Len() int {
+	return len(s.pixels)
+}
+
+func (s *Strip) SetOrder(order ColorOrder) {
+	s.mutex.Lock()
+	s.order = order
+	s.mutex.Unlock()
+}
+
+// Global scale applied on Show
+func (s *Strip) SetBrightness(b uint8) {
+	s.mutex.Lock()
+	s.brightness = b
+	s.mutex.Unlock()
+}
+
+// Out of range indexes are ignored
+func (s *Strip) SetPixel(i int, r, g, b uint8) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if i >= 0 && i < len(s.pixels) {
+		s.pixels[i] = [3]uint8{r, g, b}
+	}
+}
+
+func (s *Strip) Pixel(i int) (r, g, b uint8) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if i < 0 || i >= len(s.pixels) {
+		return 0, 0, 0
+	}
+	p := s.pixels[i]
+	return p[0], p[1], p[2]
+}
+
+func (s *Strip) Fill(r, g, b uint8) {
+	s.mutex.Lock()
+	for i := range s.pixels {
+		s.pixels[i] = [3]uint8{r, g, b}
+	}
+	s.mutex.Unlock()
+}
+
+func (s *Strip) Clear() {
+	s.Fill(0, 0, 0)
+}
+
+// Expand byte into 24 SPI bits
+func encodeByte(dst []byte, v uint8) {
+	var bits uint32
+	for i := 7; i >= 0; i-- {
+		bits <<= 3
+		if v&(1<<uint(i)) != 0 {
+			bits |= 6 // 110
+		} else {
+			bits |= 4 // 100
+		}
+	}
+	dst[0] = byte(bits >> 16)
+	dst[1] = byte(bits >> 8)
+	dst[2] = byte(bits)
+}
+
+// Send framebuffer to the strip
+func (s *Strip) Show() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, p := range s.pixels {
+		var wire [3]uint8
+		switch s.order {
+		case RGB:
+			wire = p
+		case BRG:
+			wire = [3]uint8{p[2], p[0], p[1]}
+		default:
+			wire = [3]uint8{p[1], p[0], p[2]}
+		}
+
+		for j, v := range wire {
+			v = uint8(uint(v) * (uint(s.brightness) + 1) >> 8)
+			encodeByte(s.buf[i*9+j*3:], v)
+		}
+	}
+
+	// tail stays zero and works as reset
+	_, err := s.conn.Transfer(s.buf)
+	return err
+}
+
+// Switch LEDs off and close SPI connection if possible
+func (s *Strip) Close() error {
+	s.Clear()
+	err := s.Show()
+	if c, ok := s.conn.(io.Closer); ok {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}